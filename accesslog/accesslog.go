@@ -0,0 +1,431 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package accesslog provides a connect.Interceptor that writes one structured
+// log record per RPC.
+//
+// Records are written to a Handler, which mirrors the shape of the standard
+// library's log/slog.Handler. Adapting a slog.Handler (or any other structured
+// logger) takes only a few lines:
+//
+//   type slogHandler struct{ handler slog.Handler }
+//
+//   func (h slogHandler) Enabled(ctx context.Context, level accesslog.Level) bool {
+//     return h.handler.Enabled(ctx, slog.Level(level))
+//   }
+//
+//   func (h slogHandler) Handle(ctx context.Context, record accesslog.Record) error {
+//     r := slog.NewRecord(record.Time, slog.Level(record.Level), record.Message, 0)
+//     for _, attr := range record.Attrs {
+//       r.AddAttrs(slog.Any(attr.Key, attr.Value))
+//     }
+//     return h.handler.Handle(ctx, r)
+//   }
+package accesslog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// A Level is the importance of a log record. The levels have the same values
+// as log/slog's levels.
+type Level int
+
+const (
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+)
+
+// An Attr is a key-value pair.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// A Record describes a single RPC.
+type Record struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	Attrs   []Attr
+}
+
+// A Handler writes log records. Handlers must be safe to call concurrently.
+type Handler interface {
+	// Enabled reports whether the handler writes records at the given level.
+	// If it returns false, the interceptor skips building the record.
+	Enabled(context.Context, Level) bool
+	// Handle writes the record.
+	Handle(context.Context, Record) error
+}
+
+// The keys used for the attributes of each record. Selected headers use
+// the lower-cased header name prefixed with KeyHeaderPrefix, and payloads use
+// KeyRequest and KeyResponse.
+const (
+	KeyProcedure                 = "procedure"
+	KeyStreamType                = "stream_type"
+	KeySide                      = "side"
+	KeyProtocol                  = "protocol"
+	KeyPeer                      = "peer"
+	KeyCode                      = "code"
+	KeyError                     = "error"
+	KeyDuration                  = "duration"
+	KeyMessagesReceived          = "messages_received"
+	KeyMessagesSent              = "messages_sent"
+	KeyBytesReceived             = "bytes_received"
+	KeyBytesSent                 = "bytes_sent"
+	KeyUncompressedBytesReceived = "uncompressed_bytes_received"
+	KeyUncompressedBytesSent     = "uncompressed_bytes_sent"
+	KeyHeaderPrefix              = "header."
+	KeyRequest                   = "request"
+	KeyResponse                  = "response"
+)
+
+// DefaultLevel is the default mapping from RPC outcomes to levels. Successful
+// RPCs are logged at LevelInfo. Errors that are typically the client's fault,
+// like connect.CodeInvalidArgument and connect.CodeNotFound, are logged at
+// LevelWarn, and all other errors are logged at LevelError.
+func DefaultLevel(err error) Level {
+	if err == nil {
+		return LevelInfo
+	}
+	switch connect.CodeOf(err) {
+	case connect.CodeCanceled,
+		connect.CodeInvalidArgument,
+		connect.CodeNotFound,
+		connect.CodeAlreadyExists,
+		connect.CodePermissionDenied,
+		connect.CodeResourceExhausted,
+		connect.CodeFailedPrecondition,
+		connect.CodeAborted,
+		connect.CodeOutOfRange,
+		connect.CodeUnauthenticated:
+		return LevelWarn
+	default:
+		return LevelError
+	}
+}
+
+// NewInterceptor returns a connect.Interceptor that logs one record per RPC to
+// the supplied Handler. Unary RPCs are logged when they complete. Handlers
+// log streaming RPCs when the handler function returns. Clients log streaming
+// RPCs as soon as the response ends: when Receive returns an error (including
+// io.EOF), Send fails, CloseResponse is called, or the context is done.
+// Clients that abandon a stream without doing any of these leak it, and it's
+// never logged.
+//
+// Message counts and sizes come from connect.StatsFromContext. Byte counts are
+// on the wire, after compression and including framing; uncompressed byte
+// counts are the size of the marshaled messages. Successful unary handlers log
+// before the response is written, so their records don't include the sent
+// message count or sizes.
+func NewInterceptor(handler Handler, options ...Option) connect.Interceptor {
+	interceptor := &interceptor{
+		handler: handler,
+		level:   DefaultLevel,
+		message: "rpc",
+	}
+	for _, opt := range options {
+		opt.apply(interceptor)
+	}
+	if interceptor.logPayloads {
+		codec := interceptor.payloadCodec
+		if codec == nil {
+			codec = connect.NewProtoJSONCodec(protojson.MarshalOptions{}, protojson.UnmarshalOptions{})
+		}
+		interceptor.payloads = &payloadRenderer{
+			codec:  codec,
+			redact: interceptor.redact,
+		}
+	}
+	return interceptor
+}
+
+type interceptor struct {
+	handler      Handler
+	level        func(error) Level
+	sample       func(connect.Spec, error) bool
+	headers      []string
+	logPayloads  bool
+	redact       map[string]struct{}
+	payloadCodec connect.Codec
+	payloads     *payloadRenderer
+	message      string
+}
+
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
+		start := time.Now()
+		response, err := next(ctx, request)
+		call := &callInfo{
+			start:  start,
+			spec:   request.Spec(),
+			peer:   request.Peer(),
+			header: request.Header(),
+		}
+		call.stats, _ = connect.StatsFromContext(ctx)
+		// The response to a successful call hasn't been written yet, so the
+		// handler's Stats only cover the request.
+		call.responsePending = !call.spec.IsClient && err == nil
+		var responseMsg any
+		if err == nil {
+			responseMsg = response.Any()
+		}
+		i.log(ctx, call, err, func(attrs []Attr) []Attr {
+			if i.payloads == nil {
+				return attrs
+			}
+			if rendered, ok := i.payloads.render(request.Any()); ok {
+				attrs = append(attrs, Attr{Key: KeyRequest, Value: rendered})
+			}
+			if rendered, ok := i.payloads.render(responseMsg); ok && err == nil {
+				attrs = append(attrs, Attr{Key: KeyResponse, Value: rendered})
+			}
+			return attrs
+		})
+		return response, err
+	}
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		start := time.Now()
+		conn := &clientConn{
+			StreamingClientConn: next(ctx, spec),
+			ctx:                 ctx,
+			interceptor:         i,
+			call: &callInfo{
+				start: start,
+				spec:  spec,
+			},
+			done: make(chan struct{}),
+		}
+		if ctx.Done() != nil {
+			go conn.watch()
+		}
+		return conn
+	}
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		call := &callInfo{
+			start:  time.Now(),
+			spec:   conn.Spec(),
+			peer:   connect.PeerOf(conn),
+			header: conn.RequestHeader(),
+		}
+		err := next(ctx, conn)
+		call.stats, _ = connect.StatsFromContext(ctx)
+		i.log(ctx, call, err, nil)
+		return err
+	}
+}
+
+// log builds and writes a record, unless it's disabled or sampled out. The
+// optional extra function appends attributes to the record.
+func (i *interceptor) log(ctx context.Context, call *callInfo, err error, extra func([]Attr) []Attr) {
+	if i.sample != nil && !i.sample(call.spec, err) {
+		return
+	}
+	level := i.level(err)
+	if !i.handler.Enabled(ctx, level) {
+		return
+	}
+	code := "ok"
+	if err != nil {
+		code = connect.CodeOf(err).String()
+	}
+	side := "server"
+	if call.spec.IsClient {
+		side = "client"
+	}
+	attrs := make([]Attr, 0, 16+len(i.headers))
+	attrs = append(
+		attrs,
+		Attr{Key: KeyProcedure, Value: call.spec.Procedure},
+		Attr{Key: KeyStreamType, Value: streamTypeName(call.spec.StreamType)},
+		Attr{Key: KeySide, Value: side},
+		Attr{Key: KeyProtocol, Value: call.peer.Protocol},
+		Attr{Key: KeyPeer, Value: call.peer.Addr},
+		Attr{Key: KeyCode, Value: code},
+	)
+	if err != nil {
+		attrs = append(attrs, Attr{Key: KeyError, Value: err.Error()})
+	}
+	attrs = append(
+		attrs,
+		Attr{Key: KeyDuration, Value: time.Since(call.start)},
+		Attr{Key: KeyMessagesReceived, Value: call.stats.MessagesReceived},
+		Attr{Key: KeyBytesReceived, Value: call.stats.BytesReceived},
+		Attr{Key: KeyUncompressedBytesReceived, Value: call.stats.UncompressedBytesReceived},
+	)
+	if !call.responsePending {
+		attrs = append(
+			attrs,
+			Attr{Key: KeyMessagesSent, Value: call.stats.MessagesSent},
+			Attr{Key: KeyBytesSent, Value: call.stats.BytesSent},
+			Attr{Key: KeyUncompressedBytesSent, Value: call.stats.UncompressedBytesSent},
+		)
+	}
+	for _, name := range i.headers {
+		if values := call.header.Values(name); len(values) > 0 {
+			attrs = append(attrs, Attr{
+				Key:   KeyHeaderPrefix + strings.ToLower(name),
+				Value: strings.Join(values, ", "),
+			})
+		}
+	}
+	if extra != nil {
+		attrs = extra(attrs)
+	}
+	_ = i.handler.Handle(ctx, Record{
+		Time:    time.Now(),
+		Level:   level,
+		Message: i.message,
+		Attrs:   attrs,
+	})
+}
+
+// callInfo describes a single RPC.
+type callInfo struct {
+	start  time.Time
+	spec   connect.Spec
+	peer   connect.Peer
+	header http.Header
+	stats  connect.Stats
+	// responsePending is set for successful unary handlers, which log before
+	// the response is written.
+	responsePending bool
+}
+
+type clientConn struct {
+	connect.StreamingClientConn
+
+	ctx         context.Context // nolint:containedctx
+	interceptor *interceptor
+	call        *callInfo
+	done        chan struct{}
+
+	mu         sync.Mutex
+	header     http.Header // snapshot taken when the headers are sent
+	sentHeader bool
+	logged     bool
+}
+
+// Peer implements connect.PeerReporter.
+func (cc *clientConn) Peer() connect.Peer {
+	return connect.PeerOf(cc.StreamingClientConn)
+}
+
+func (cc *clientConn) Send(msg any) error {
+	cc.snapshotHeader()
+	err := cc.StreamingClientConn.Send(msg)
+	// Send returns io.EOF when the server has ended the RPC; the error itself
+	// is returned from Receive.
+	if err != nil && !errors.Is(err, io.EOF) {
+		cc.finish(err)
+	}
+	return err
+}
+
+func (cc *clientConn) CloseRequest() error {
+	cc.snapshotHeader()
+	return cc.StreamingClientConn.CloseRequest()
+}
+
+func (cc *clientConn) Receive(msg any) error {
+	err := cc.StreamingClientConn.Receive(msg)
+	if errors.Is(err, io.EOF) {
+		cc.finish(nil)
+	} else if err != nil {
+		cc.finish(err)
+	}
+	return err
+}
+
+func (cc *clientConn) CloseResponse() error {
+	err := cc.StreamingClientConn.CloseResponse()
+	cc.finish(err)
+	return err
+}
+
+// snapshotHeader copies the request headers before they're sent, since
+// RequestHeader may not be called concurrently with Send.
+func (cc *clientConn) snapshotHeader() {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.sentHeader {
+		return
+	}
+	cc.sentHeader = true
+	cc.header = cc.RequestHeader().Clone()
+}
+
+// watch logs the RPC if the context is done before the response ends.
+func (cc *clientConn) watch() {
+	select {
+	case <-cc.ctx.Done():
+		err := cc.ctx.Err()
+		code := connect.CodeCanceled
+		if errors.Is(err, context.DeadlineExceeded) {
+			code = connect.CodeDeadlineExceeded
+		}
+		cc.finish(connect.NewError(code, err))
+	case <-cc.done:
+	}
+}
+
+// finish logs the RPC the first time it's called.
+func (cc *clientConn) finish(err error) {
+	cc.mu.Lock()
+	if cc.logged {
+		cc.mu.Unlock()
+		return
+	}
+	cc.logged = true
+	close(cc.done)
+	cc.call.header = cc.header
+	cc.mu.Unlock()
+	cc.call.peer = cc.Peer()
+	cc.call.stats, _ = connect.StatsFromContext(cc.ctx)
+	cc.interceptor.log(cc.ctx, cc.call, err, nil)
+}
+
+func streamTypeName(streamType connect.StreamType) string {
+	switch streamType {
+	case connect.StreamTypeUnary:
+		return "unary"
+	case connect.StreamTypeClient:
+		return "client"
+	case connect.StreamTypeServer:
+		return "server"
+	case connect.StreamTypeBidi:
+		return "bidi"
+	default:
+		return "unknown"
+	}
+}
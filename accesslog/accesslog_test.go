@@ -0,0 +1,262 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accesslog_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/bufbuild/connect-go/accesslog"
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connect"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+type pingServer struct {
+	pingv1connect.UnimplementedPingServiceHandler
+}
+
+func (pingServer) Ping(_ context.Context, request *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+	return connect.NewResponse(&pingv1.PingResponse{
+		Number: request.Msg.Number,
+		Text:   request.Msg.Text,
+	}), nil
+}
+
+func (pingServer) Fail(context.Context, *connect.Request[pingv1.FailRequest]) (*connect.Response[pingv1.FailResponse], error) {
+	return nil, connect.NewError(connect.CodeNotFound, errors.New("not found"))
+}
+
+func (pingServer) Sum(_ context.Context, stream *connect.ClientStream[pingv1.SumRequest]) (*connect.Response[pingv1.SumResponse], error) {
+	var sum int64
+	for stream.Receive() {
+		sum += stream.Msg().Number
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&pingv1.SumResponse{Sum: sum}), nil
+}
+
+// recordingHandler collects records in memory.
+type recordingHandler struct {
+	mu      sync.Mutex
+	records []accesslog.Record
+}
+
+func (h *recordingHandler) Enabled(context.Context, accesslog.Level) bool {
+	return true
+}
+
+func (h *recordingHandler) Handle(_ context.Context, record accesslog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, record)
+	return nil
+}
+
+// take returns the only record and resets the handler.
+func (h *recordingHandler) take(tb testing.TB) map[string]any {
+	tb.Helper()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	assert.Equal(tb, len(h.records), 1)
+	attrs := make(map[string]any)
+	if len(h.records) == 0 {
+		return attrs
+	}
+	attrs["level"] = h.records[0].Level
+	for _, attr := range h.records[0].Attrs {
+		attrs[attr.Key] = attr.Value
+	}
+	h.records = nil
+	return attrs
+}
+
+// wait returns the only record, waiting for it to be written.
+func (h *recordingHandler) wait(tb testing.TB) map[string]any {
+	tb.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		h.mu.Lock()
+		written := len(h.records) > 0
+		h.mu.Unlock()
+		if written {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return h.take(tb)
+}
+
+func TestInterceptor(t *testing.T) {
+	t.Parallel()
+	const secret = "hunter2"
+	handlerLog, clientLog := &recordingHandler{}, &recordingHandler{}
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		pingServer{},
+		connect.WithInterceptors(accesslog.NewInterceptor(
+			handlerLog,
+			accesslog.WithHeaders("X-Request-Id"),
+			accesslog.WithPayloads("text"),
+		)),
+	))
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	client := pingv1connect.NewPingServiceClient(
+		server.Client(),
+		server.URL,
+		connect.WithInterceptors(accesslog.NewInterceptor(clientLog)),
+	)
+
+	t.Run("unary", func(t *testing.T) {
+		request := connect.NewRequest(&pingv1.PingRequest{Number: 42, Text: secret})
+		request.Header().Set("X-Request-Id", "abc")
+		response, err := client.Ping(context.Background(), request)
+		assert.Nil(t, err)
+
+		attrs := handlerLog.take(t)
+		assert.Equal(t, attrs[accesslog.KeyProcedure], any("/connect.ping.v1.PingService/Ping"))
+		assert.Equal(t, attrs[accesslog.KeyStreamType], any("unary"))
+		assert.Equal(t, attrs[accesslog.KeySide], any("server"))
+		assert.Equal(t, attrs[accesslog.KeyProtocol], any(connect.ProtocolConnect))
+		assert.Equal(t, attrs[accesslog.KeyCode], any("ok"))
+		assert.Equal(t, attrs["level"], any(accesslog.LevelInfo))
+		assert.Equal(t, attrs[accesslog.KeyMessagesReceived], any(int64(1)))
+		assert.Equal(t, attrs[accesslog.KeyUncompressedBytesReceived], any(int64(proto.Size(request.Msg))))
+		assert.NotZero(t, attrs[accesslog.KeyBytesReceived])
+		// The response hasn't been written when unary handlers log.
+		for _, key := range []string{accesslog.KeyMessagesSent, accesslog.KeyBytesSent, accesslog.KeyUncompressedBytesSent} {
+			_, ok := attrs[key]
+			assert.False(t, ok, assert.Sprintf("unexpected %s", key))
+		}
+		assert.Equal(t, attrs[accesslog.KeyHeaderPrefix+"x-request-id"], any("abc"))
+		var payload map[string]any
+		assert.Nil(t, json.Unmarshal(attrs[accesslog.KeyRequest].(json.RawMessage), &payload))
+		assert.Equal(t, payload, map[string]any{"number": "42"})
+
+		attrs = clientLog.take(t)
+		assert.Equal(t, attrs[accesslog.KeySide], any("client"))
+		assert.Equal(t, attrs[accesslog.KeyMessagesSent], any(int64(1)))
+		assert.Equal(t, attrs[accesslog.KeyBytesSent], any(response.Stats().BytesSent))
+		assert.Equal(t, attrs[accesslog.KeyBytesReceived], any(response.Stats().BytesReceived))
+		assert.Equal(t, attrs[accesslog.KeyUncompressedBytesSent], any(int64(proto.Size(request.Msg))))
+		assert.Equal(t, attrs[accesslog.KeyUncompressedBytesReceived], any(int64(proto.Size(response.Msg))))
+		assert.Equal(t, attrs[accesslog.KeyRequest], nil)
+	})
+	t.Run("error", func(t *testing.T) {
+		_, err := client.Fail(context.Background(), connect.NewRequest(&pingv1.FailRequest{}))
+		assert.NotNil(t, err)
+		attrs := handlerLog.take(t)
+		assert.Equal(t, attrs[accesslog.KeyCode], any("not_found"))
+		assert.Equal(t, attrs[accesslog.KeyError], any("not_found: not found"))
+		assert.Equal(t, attrs["level"], any(accesslog.LevelWarn))
+		assert.Equal(t, attrs[accesslog.KeyMessagesSent], any(int64(0)))
+		assert.Equal(t, attrs[accesslog.KeyBytesSent], any(int64(0)))
+		attrs = clientLog.take(t)
+		assert.Equal(t, attrs[accesslog.KeyCode], any("not_found"))
+	})
+	t.Run("stream", func(t *testing.T) {
+		stream := client.Sum(context.Background())
+		for i := int64(1); i <= 3; i++ {
+			assert.Nil(t, stream.Send(&pingv1.SumRequest{Number: i}))
+		}
+		response, err := stream.CloseAndReceive()
+		assert.Nil(t, err)
+		assert.Equal(t, response.Msg.Sum, int64(6))
+
+		attrs := handlerLog.take(t)
+		assert.Equal(t, attrs[accesslog.KeyStreamType], any("client"))
+		assert.Equal(t, attrs[accesslog.KeyMessagesReceived], any(int64(3)))
+		assert.Equal(t, attrs[accesslog.KeyMessagesSent], any(int64(1)))
+		assert.Equal(t, attrs[accesslog.KeyCode], any("ok"))
+
+		attrs = clientLog.take(t)
+		assert.Equal(t, attrs[accesslog.KeySide], any("client"))
+		assert.Equal(t, attrs[accesslog.KeyMessagesSent], any(int64(3)))
+		assert.Equal(t, attrs[accesslog.KeyMessagesReceived], any(int64(1)))
+		assert.Equal(t, attrs[accesslog.KeyProtocol], any(connect.ProtocolConnect))
+		assert.Equal(t, attrs[accesslog.KeyCode], any("ok"))
+		assert.Equal(t, attrs[accesslog.KeyBytesSent], any(response.Stats().BytesSent))
+	})
+	t.Run("stream_failed_early", func(t *testing.T) {
+		stream, err := client.CountUp(context.Background(), connect.NewRequest(&pingv1.CountUpRequest{}))
+		assert.Nil(t, err)
+		assert.False(t, stream.Receive())
+		assert.Equal(t, connect.CodeOf(stream.Err()), connect.CodeUnimplemented)
+		// The stream is logged as soon as it fails, even if it's never closed.
+		attrs := clientLog.take(t)
+		assert.Equal(t, attrs[accesslog.KeyCode], any("unimplemented"))
+		assert.Equal(t, attrs[accesslog.KeyMessagesSent], any(int64(1)))
+		handlerLog.take(t)
+		assert.Nil(t, stream.Close())
+		assert.Equal(t, len(clientLog.records), 0)
+	})
+	t.Run("stream_abandoned", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := client.Sum(ctx)
+		assert.Nil(t, stream.Send(&pingv1.SumRequest{Number: 1}))
+		cancel()
+		attrs := clientLog.wait(t)
+		assert.Equal(t, attrs[accesslog.KeyCode], any("canceled"))
+		assert.Equal(t, attrs[accesslog.KeyMessagesSent], any(int64(1)))
+		handlerLog.wait(t)
+	})
+}
+
+func TestPayloadCodec(t *testing.T) {
+	t.Parallel()
+	handlerLog := &recordingHandler{}
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		pingServer{},
+		connect.WithInterceptors(accesslog.NewInterceptor(
+			handlerLog,
+			accesslog.WithPayloads(),
+			accesslog.WithPayloadCodec(connect.NewProtoJSONCodec(
+				protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+				protojson.UnmarshalOptions{},
+			)),
+		)),
+	))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	client := pingv1connect.NewPingServiceClient(server.Client(), server.URL)
+	_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{Number: 1}))
+	assert.Nil(t, err)
+	attrs := handlerLog.take(t)
+	var payload map[string]any
+	assert.Nil(t, json.Unmarshal(attrs[accesslog.KeyResponse].(json.RawMessage), &payload))
+	assert.Equal(t, payload, map[string]any{"number": "1", "text": ""})
+}
+
+func TestSampler(t *testing.T) {
+	t.Parallel()
+	sample := accesslog.RateSampler(0)
+	assert.False(t, sample(connect.Spec{}, nil))
+	assert.True(t, sample(connect.Spec{}, errors.New("oops")))
+}
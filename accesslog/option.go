@@ -0,0 +1,207 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accesslog
+
+import (
+	"encoding/json"
+	"math/rand"
+
+	"github.com/bufbuild/connect-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// An Option configures the interceptor returned by NewInterceptor.
+type Option interface {
+	apply(*interceptor)
+}
+
+// WithLevel configures the function used to choose the level of each record
+// from the RPC's error, which is nil for successful RPCs. Use connect.CodeOf
+// to select levels by Code.
+//
+// By default, the interceptor uses DefaultLevel.
+func WithLevel(level func(err error) Level) Option {
+	return &levelOption{level: level}
+}
+
+// WithSampler configures a function that decides whether to log each RPC.
+// RPCs are logged only if the function returns true.
+//
+// By default, the interceptor logs every RPC.
+func WithSampler(sample func(spec connect.Spec, err error) bool) Option {
+	return &samplerOption{sample: sample}
+}
+
+// RateSampler returns a sampling function, suitable for WithSampler, that
+// logs the given fraction of successful RPCs and every failed RPC.
+func RateSampler(rate float64) func(connect.Spec, error) bool {
+	return func(_ connect.Spec, err error) bool {
+		return err != nil || rand.Float64() < rate // nolint:gosec
+	}
+}
+
+// WithHeaders configures the interceptor to include the values of the named
+// request headers in each record.
+//
+// By default, no headers are logged.
+func WithHeaders(names ...string) Option {
+	return &headersOption{names: names}
+}
+
+// WithPayloads configures the interceptor to include the JSON representation
+// of unary request and response messages in each record. Fields of Protobuf
+// messages with any of the supplied names, at any depth, are cleared before
+// rendering. Names may be either the Protobuf or the JSON field name.
+//
+// Streaming records aggregate many messages, so they never include payloads.
+// Payloads are rendered with the codec configured by WithPayloadCodec. By
+// default, payloads aren't logged.
+func WithPayloads(redactFields ...string) Option {
+	redact := make(map[string]struct{}, len(redactFields))
+	for _, name := range redactFields {
+		redact[name] = struct{}{}
+	}
+	return &payloadsOption{redact: redact}
+}
+
+// WithPayloadCodec configures the codec used to render payloads, which must
+// produce JSON. Interceptors can't see the codecs registered with clients and
+// handlers, so pass the same JSON codec given to connect.WithCodec to log
+// payloads with the same mapping used on the wire.
+//
+// By default, the interceptor uses connect.NewProtoJSONCodec with the default
+// options.
+func WithPayloadCodec(codec connect.Codec) Option {
+	return &payloadCodecOption{codec: codec}
+}
+
+// WithMessage configures the message of each record.
+//
+// By default, the message is "rpc".
+func WithMessage(message string) Option {
+	return &messageOption{message: message}
+}
+
+type levelOption struct {
+	level func(error) Level
+}
+
+func (o *levelOption) apply(i *interceptor) {
+	i.level = o.level
+}
+
+type samplerOption struct {
+	sample func(connect.Spec, error) bool
+}
+
+func (o *samplerOption) apply(i *interceptor) {
+	i.sample = o.sample
+}
+
+type headersOption struct {
+	names []string
+}
+
+func (o *headersOption) apply(i *interceptor) {
+	i.headers = append(i.headers, o.names...)
+}
+
+type payloadsOption struct {
+	redact map[string]struct{}
+}
+
+func (o *payloadsOption) apply(i *interceptor) {
+	i.logPayloads = true
+	i.redact = o.redact
+}
+
+type payloadCodecOption struct {
+	codec connect.Codec
+}
+
+func (o *payloadCodecOption) apply(i *interceptor) {
+	i.payloadCodec = o.codec
+}
+
+type messageOption struct {
+	message string
+}
+
+func (o *messageOption) apply(i *interceptor) {
+	i.message = o.message
+}
+
+type payloadRenderer struct {
+	codec  connect.Codec
+	redact map[string]struct{}
+}
+
+// render returns the redacted JSON representation of a message.
+func (r *payloadRenderer) render(msg any) (json.RawMessage, bool) {
+	if msg == nil {
+		return nil, false
+	}
+	if protoMsg, ok := msg.(proto.Message); ok && len(r.redact) > 0 {
+		redacted := proto.Clone(protoMsg)
+		r.clear(redacted.ProtoReflect())
+		msg = redacted
+	}
+	data, err := r.codec.Marshal(msg)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (r *payloadRenderer) clear(msg protoreflect.Message) {
+	var redacted []protoreflect.FieldDescriptor
+	msg.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		if r.redacted(field) {
+			redacted = append(redacted, field)
+			return true
+		}
+		switch {
+		case field.IsMap():
+			if field.MapValue().Message() != nil {
+				value.Map().Range(func(_ protoreflect.MapKey, value protoreflect.Value) bool {
+					r.clear(value.Message())
+					return true
+				})
+			}
+		case field.IsList():
+			if field.Message() != nil {
+				list := value.List()
+				for i := 0; i < list.Len(); i++ {
+					r.clear(list.Get(i).Message())
+				}
+			}
+		case field.Message() != nil:
+			r.clear(value.Message())
+		}
+		return true
+	})
+	for _, field := range redacted {
+		msg.Clear(field)
+	}
+}
+
+func (r *payloadRenderer) redacted(field protoreflect.FieldDescriptor) bool {
+	if _, ok := r.redact[string(field.Name())]; ok {
+		return true
+	}
+	_, ok := r.redact[field.JSONName()]
+	return ok
+}
@@ -0,0 +1,144 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package batch sends bursts of Connect unary calls as a single HTTP request.
+// Clients opt in by wrapping their connect.HTTPClient with NewHTTPClient,
+// which queues unary requests for a short window and sends them to a batch
+// endpoint. Servers expose the endpoint with NewHandler, which dispatches each
+// call to the usual handlers and returns every call's status, headers, and
+// body.
+//
+// Because batching happens below the Connect protocol, every call keeps its
+// own interceptors, headers, trailers, errors, and compression. Only Connect
+// unary calls are batched; streaming calls and the gRPC and gRPC-Web
+// protocols always bypass the batch endpoint.
+//
+// Wire format
+//
+// A batch is a POST to the batch endpoint with the Content-Type
+// "application/json". The request body is a JSON object with a single "calls"
+// array. Each call is an object with the procedure's URL path, the call's
+// HTTP request headers, and the base64-encoded request body, exactly as they'd
+// be sent in a standalone Connect unary request:
+//
+//   {"calls": [{"procedure": "/acme.foo.v1.FooService/Bar", "header": {"Content-Type": ["application/proto"]}, "body": "CgNmb28="}]}
+//
+// Each call's deadline is sent in its Connect-Timeout-Ms header, recomputed
+// just before the batch is sent so that time spent queueing counts against
+// the deadline.
+//
+// A successful batch response has status 200, Content-Type
+// "application/json", and a body with a "results" array. Results are in the
+// same order as the calls, and each has the HTTP status code, headers, and
+// base64-encoded body of the call's standalone Connect unary response:
+//
+//   {"results": [{"status": 200, "header": {"Content-Type": ["application/proto"]}, "body": "CgNmb28="}]}
+//
+// If the batch itself is malformed, the handler responds with a non-200
+// status and every call in the batch fails.
+package batch
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// DefaultPath is the URL path used for the batch endpoint by NewHandler.
+const DefaultPath = "/connect.batch.v1/Batch"
+
+const (
+	headerContentType = "Content-Type"
+	headerTimeout     = "Connect-Timeout-Ms"
+
+	contentTypeJSON = "application/json"
+	// Connect unary content types are "application/" followed by the codec
+	// name. Streaming content types start with "application/connect+", and
+	// gRPC content types start with "application/grpc".
+	contentTypePrefix          = "application/"
+	streamingContentTypePrefix = "application/connect+"
+	grpcContentTypePrefix      = "application/grpc"
+)
+
+type wireRequest struct {
+	Calls []wireCall `json:"calls"`
+}
+
+type wireCall struct {
+	Procedure string      `json:"procedure"`
+	Header    http.Header `json:"header,omitempty"`
+	Body      []byte      `json:"body,omitempty"`
+}
+
+type wireResponse struct {
+	Results []wireResult `json:"results"`
+}
+
+type wireResult struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// isConnectUnary reports whether the Content-Type is used for Connect unary
+// calls.
+func isConnectUnary(contentType string) bool {
+	return len(contentType) > len(contentTypePrefix) &&
+		strings.HasPrefix(contentType, contentTypePrefix) &&
+		!strings.HasPrefix(contentType, streamingContentTypePrefix) &&
+		!strings.HasPrefix(contentType, grpcContentTypePrefix)
+}
+
+// responseRecorder buffers a handler's response in memory.
+type responseRecorder struct {
+	header      http.Header
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{header: make(http.Header)}
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	r.status = status
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(data)
+}
+
+func (r *responseRecorder) Flush() {}
+
+func (r *responseRecorder) Result() wireResult {
+	status := r.status
+	if !r.wroteHeader {
+		status = http.StatusOK
+	}
+	return wireResult{
+		Status: status,
+		Header: r.header,
+		Body:   r.body.Bytes(),
+	}
+}
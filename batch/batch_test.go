@@ -0,0 +1,299 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/bufbuild/connect-go/batch"
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connect"
+)
+
+type pingServer struct {
+	pingv1connect.UnimplementedPingServiceHandler
+}
+
+func (pingServer) Ping(ctx context.Context, request *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+	response := connect.NewResponse(&pingv1.PingResponse{Number: request.Msg.Number})
+	response.Header().Set("Ping-Number", strconv.FormatInt(request.Msg.Number, 10))
+	_, hasDeadline := ctx.Deadline()
+	response.Header().Set("Ping-Deadline", strconv.FormatBool(hasDeadline))
+	response.Header().Set("Ping-Authorization", request.Header().Get("Authorization"))
+	response.Trailer().Set("Ping-Trailer", "trailer")
+	return response, nil
+}
+
+func (pingServer) Fail(_ context.Context, request *connect.Request[pingv1.FailRequest]) (*connect.Response[pingv1.FailResponse], error) {
+	return nil, connect.NewError(connect.Code(request.Msg.Code), errors.New("oops"))
+}
+
+func (pingServer) CountUp(
+	_ context.Context,
+	request *connect.Request[pingv1.CountUpRequest],
+	stream *connect.ServerStream[pingv1.CountUpResponse],
+) error {
+	for i := int64(1); i <= request.Msg.Number; i++ {
+		if err := stream.Send(&pingv1.CountUpResponse{Number: i}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestBatch(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(pingServer{}))
+	mux.Handle(batch.NewHandler(mux, batch.WithMaxCalls(8)))
+	var requests, batches int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		if r.URL.Path == batch.DefaultPath {
+			atomic.AddInt64(&batches, 1)
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	httpClient := batch.NewHTTPClient(
+		server.Client(),
+		server.URL+batch.DefaultPath,
+		batch.WithWindow(time.Second),
+		batch.WithMaxCalls(8),
+	)
+	client := pingv1connect.NewPingServiceClient(httpClient, server.URL)
+
+	t.Run("unary", func(t *testing.T) {
+		startRequests, startBatches := atomic.LoadInt64(&requests), atomic.LoadInt64(&batches)
+		var wg sync.WaitGroup
+		for i := int64(0); i < 7; i++ {
+			wg.Add(1)
+			go func(i int64) {
+				defer wg.Done()
+				ctx := context.Background()
+				if i%2 == 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, time.Minute)
+					defer cancel()
+				}
+				response, err := client.Ping(ctx, connect.NewRequest(&pingv1.PingRequest{Number: i}))
+				assert.Nil(t, err)
+				assert.Equal(t, response.Msg.Number, i)
+				assert.Equal(t, response.Header().Get("Ping-Number"), strconv.FormatInt(i, 10))
+				assert.Equal(t, response.Header().Get("Ping-Deadline"), strconv.FormatBool(i%2 == 0))
+				assert.Equal(t, response.Trailer().Get("Ping-Trailer"), "trailer")
+			}(i)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Fail(
+				context.Background(),
+				connect.NewRequest(&pingv1.FailRequest{Code: int32(connect.CodeResourceExhausted)}),
+			)
+			assert.Equal(t, connect.CodeOf(err), connect.CodeResourceExhausted)
+		}()
+		// The batch is full, so it's sent without waiting for the window.
+		wg.Wait()
+		assert.Equal(t, atomic.LoadInt64(&requests)-startRequests, int64(1))
+		assert.Equal(t, atomic.LoadInt64(&batches)-startBatches, int64(1))
+	})
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := client.Ping(ctx, connect.NewRequest(&pingv1.PingRequest{}))
+		assert.Equal(t, connect.CodeOf(err), connect.CodeCanceled)
+	})
+	t.Run("streaming_bypasses_batch", func(t *testing.T) {
+		startBatches := atomic.LoadInt64(&batches)
+		stream, err := client.CountUp(context.Background(), connect.NewRequest(&pingv1.CountUpRequest{Number: 2}))
+		assert.Nil(t, err)
+		for stream.Receive() {
+		}
+		assert.Nil(t, stream.Err())
+		assert.Nil(t, stream.Close())
+		assert.Equal(t, atomic.LoadInt64(&batches), startBatches)
+	})
+}
+
+// authorizingTransport adds an Authorization header to every request, like
+// the transports of many authenticated HTTP clients.
+type authorizingTransport struct {
+	next http.RoundTripper
+}
+
+func (t *authorizingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	request.Header.Set("Authorization", "Bearer batch")
+	return t.next.RoundTrip(request)
+}
+
+func TestBatchHeaders(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(pingServer{}))
+	mux.Handle(batch.NewHandler(mux))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	httpClient := batch.NewHTTPClient(
+		&http.Client{Transport: &authorizingTransport{next: server.Client().Transport}},
+		server.URL+batch.DefaultPath,
+		batch.WithMaxCalls(2),
+	)
+	client := pingv1connect.NewPingServiceClient(httpClient, server.URL)
+	var wg sync.WaitGroup
+	for _, authorization := range []string{"", "Bearer call"} {
+		wg.Add(1)
+		go func(authorization string) {
+			defer wg.Done()
+			request := connect.NewRequest(&pingv1.PingRequest{})
+			want := "Bearer batch"
+			if authorization != "" {
+				request.Header().Set("Authorization", authorization)
+				want = authorization
+			}
+			response, err := client.Ping(context.Background(), request)
+			assert.Nil(t, err)
+			// Calls inherit the batch request's headers, but their own headers
+			// take precedence.
+			assert.Equal(t, response.Header().Get("Ping-Authorization"), want)
+		}(authorization)
+	}
+	wg.Wait()
+}
+
+func TestClientMaxBytes(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(pingServer{}))
+	mux.Handle(batch.NewHandler(mux))
+	var batches int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == batch.DefaultPath {
+			atomic.AddInt64(&batches, 1)
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	// Each call's body is more than half the limit, so no two calls fit in the
+	// same batch.
+	httpClient := batch.NewHTTPClient(
+		server.Client(),
+		server.URL+batch.DefaultPath,
+		batch.WithWindow(50*time.Millisecond),
+		batch.WithMaxBytes(2048),
+	)
+	client := pingv1connect.NewPingServiceClient(httpClient, server.URL)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			text := strings.Repeat("a", 1024)
+			response, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{Text: text}))
+			assert.Nil(t, err)
+			assert.NotNil(t, response)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, atomic.LoadInt64(&batches), int64(3))
+}
+
+func TestHandlerRejectsInvalidBatches(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(pingServer{}))
+	path, handler := batch.NewHandler(mux, batch.WithMaxCalls(1))
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	post := func(body string) int {
+		response, err := server.Client().Post(server.URL+path, "application/json", strings.NewReader(body))
+		assert.Nil(t, err)
+		_ = response.Body.Close()
+		return response.StatusCode
+	}
+	assert.Equal(t, post(`{`), http.StatusBadRequest)
+	assert.Equal(t, post(`{"calls": [{}, {}]}`), http.StatusRequestEntityTooLarge)
+	assert.Equal(t, post(`{"calls": [{"procedure": "/connect.ping.v1.PingService/Ping"}]}`), http.StatusOK)
+}
+
+func TestHandlerRejectsNestedBatches(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(pingServer{}))
+	path, handler := batch.NewHandler(mux)
+	mux.Handle(path, handler)
+	const otherPath = "/other.v1.BatchService/Batch"
+	mux.Handle(batch.NewHandler(mux, batch.WithPath(otherPath)))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	for _, procedure := range []string{
+		path,
+		path + "?nested=true",
+		"/./" + path[1:],
+		otherPath,
+		"http://other.test" + path,
+		"connect.ping.v1.PingService/Ping",
+	} {
+		procedure := procedure
+		t.Run(procedure, func(t *testing.T) {
+			t.Parallel()
+			// The nested batch itself is valid, so it'd succeed if it were
+			// dispatched.
+			nested, err := json.Marshal(map[string]any{"calls": []any{}})
+			assert.Nil(t, err)
+			body, err := json.Marshal(map[string]any{
+				"calls": []any{map[string]any{
+					"procedure": procedure,
+					"header":    http.Header{"Content-Type": []string{"application/json"}},
+					"body":      nested,
+				}},
+			})
+			assert.Nil(t, err)
+			response, err := server.Client().Post(server.URL+path, "application/json", bytes.NewReader(body))
+			assert.Nil(t, err)
+			defer response.Body.Close()
+			assert.Equal(t, response.StatusCode, http.StatusOK)
+			var batchResponse struct {
+				Results []struct {
+					Status int    `json:"status"`
+					Body   []byte `json:"body"`
+				} `json:"results"`
+			}
+			assert.Nil(t, json.NewDecoder(response.Body).Decode(&batchResponse))
+			assert.Equal(t, len(batchResponse.Results), 1)
+			assert.Equal(t, batchResponse.Results[0].Status, http.StatusBadRequest)
+			var wireErr struct {
+				Code string `json:"code"`
+			}
+			assert.Nil(t, json.Unmarshal(batchResponse.Results[0].Body, &wireErr))
+			assert.Equal(t, wireErr.Code, connect.CodeInvalidArgument.String())
+		})
+	}
+}
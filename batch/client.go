@@ -0,0 +1,306 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+)
+
+const defaultWindow = 10 * time.Millisecond
+
+// HTTPClient is a connect.HTTPClient that batches Connect unary requests.
+// Requests for the same scheme and host as the batch endpoint are queued for
+// a short window and sent together; all other requests, including streaming
+// and gRPC requests, are sent immediately with the wrapped client.
+//
+// Each caller's context is respected separately: a caller whose context ends
+// stops waiting for the batch, and calls whose contexts have already ended
+// when the batch is sent are left out of it.
+type HTTPClient struct {
+	client   connect.HTTPClient
+	batchURL string
+	scheme   string
+	host     string
+	path     string
+	window   time.Duration
+	maxCalls int
+	maxBytes int64
+
+	mu         sync.Mutex
+	queue      []*pendingCall
+	queueBytes int64
+	timer      *time.Timer
+	generation uint64
+}
+
+var _ connect.HTTPClient = (*HTTPClient)(nil)
+
+// NewHTTPClient wraps client to batch Connect unary requests, sending them to
+// the batch endpoint at batchURL. Typically, the batch URL is the server's
+// base URL joined with DefaultPath:
+//
+//   httpClient := batch.NewHTTPClient(http.DefaultClient, "https://api.acme.com"+batch.DefaultPath)
+//   client := pingv1connect.NewPingServiceClient(httpClient, "https://api.acme.com")
+func NewHTTPClient(client connect.HTTPClient, batchURL string, options ...ClientOption) *HTTPClient {
+	httpClient := &HTTPClient{
+		client:   client,
+		batchURL: batchURL,
+		window:   defaultWindow,
+		maxCalls: defaultMaxCalls,
+		maxBytes: defaultReadMaxBytes,
+	}
+	if parsed, err := url.Parse(batchURL); err == nil {
+		httpClient.scheme = parsed.Scheme
+		httpClient.host = parsed.Host
+		httpClient.path = parsed.Path
+	}
+	for _, opt := range options {
+		opt.applyToClient(httpClient)
+	}
+	return httpClient
+}
+
+// Do sends the request, batching it if it's a Connect unary request for the
+// batch endpoint's server.
+func (c *HTTPClient) Do(request *http.Request) (*http.Response, error) {
+	if !c.batchable(request) {
+		return c.client.Do(request)
+	}
+	body, err := io.ReadAll(request.Body)
+	_ = request.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	call := &pendingCall{
+		request: request,
+		body:    body,
+		size:    wireSize(request, body),
+		done:    make(chan struct{}),
+	}
+	c.enqueue(call)
+	select {
+	case <-call.done:
+		return call.response, call.err
+	case <-request.Context().Done():
+		return nil, request.Context().Err()
+	}
+}
+
+func (c *HTTPClient) batchable(request *http.Request) bool {
+	return request.Method == http.MethodPost &&
+		request.Body != nil &&
+		request.URL.Scheme == c.scheme &&
+		request.URL.Host == c.host &&
+		request.URL.Path != c.path &&
+		isConnectUnary(request.Header.Get(headerContentType))
+}
+
+func (c *HTTPClient) enqueue(call *pendingCall) {
+	c.mu.Lock()
+	var batches [][]*pendingCall
+	if c.maxBytes > 0 && len(c.queue) > 0 && c.queueBytes+call.size > c.maxBytes {
+		// The call doesn't fit, so send the queued calls without it.
+		batches = append(batches, c.takeLocked())
+	}
+	c.queue = append(c.queue, call)
+	c.queueBytes += call.size
+	if c.maxCalls > 0 && len(c.queue) >= c.maxCalls {
+		batches = append(batches, c.takeLocked())
+	} else if len(c.queue) == 1 {
+		generation := c.generation
+		c.timer = time.AfterFunc(c.window, func() {
+			c.flush(generation)
+		})
+	}
+	c.mu.Unlock()
+	for _, batch := range batches {
+		go c.send(batch)
+	}
+}
+
+// flush sends the queued calls, unless they've already been sent because the
+// batch filled up.
+func (c *HTTPClient) flush(generation uint64) {
+	c.mu.Lock()
+	if generation != c.generation {
+		c.mu.Unlock()
+		return
+	}
+	batch := c.takeLocked()
+	c.mu.Unlock()
+	c.send(batch)
+}
+
+func (c *HTTPClient) takeLocked() []*pendingCall {
+	batch := c.queue
+	c.queue = nil
+	c.queueBytes = 0
+	c.generation++
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	return batch
+}
+
+func (c *HTTPClient) send(batch []*pendingCall) {
+	var (
+		calls         []*pendingCall
+		wire          wireRequest
+		latest        time.Time
+		haveDeadlines = true
+	)
+	for _, call := range batch {
+		ctx := call.request.Context()
+		if err := ctx.Err(); err != nil {
+			call.finish(nil, err)
+			continue
+		}
+		header := call.request.Header.Clone()
+		if deadline, ok := ctx.Deadline(); ok {
+			// Time spent in the queue counts against the deadline.
+			millis := int64(time.Until(deadline) / time.Millisecond)
+			if millis <= 0 {
+				call.finish(nil, context.DeadlineExceeded)
+				continue
+			}
+			header.Set(headerTimeout, strconv.FormatInt(millis, 10 /* base */))
+			if deadline.After(latest) {
+				latest = deadline
+			}
+		} else {
+			haveDeadlines = false
+		}
+		calls = append(calls, call)
+		wire.Calls = append(wire.Calls, wireCall{
+			Procedure: call.request.URL.RequestURI(),
+			Header:    header,
+			Body:      call.body,
+		})
+	}
+	if len(calls) == 0 {
+		return
+	}
+	// The batch shouldn't be canceled when any single caller gives up, but
+	// there's no point in waiting longer than the last deadline.
+	ctx := context.Background()
+	if haveDeadlines {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, latest)
+		defer cancel()
+	}
+	results, err := c.roundTrip(ctx, &wire)
+	if err == nil && len(results) != len(calls) {
+		err = fmt.Errorf("batch response has %d results for %d calls", len(results), len(calls))
+	}
+	for i, call := range calls {
+		if err != nil {
+			call.finish(nil, err)
+			continue
+		}
+		call.finish(newResponse(call.request, results[i]), nil)
+	}
+}
+
+func (c *HTTPClient) roundTrip(ctx context.Context, wire *wireRequest) ([]wireResult, error) {
+	data, err := json.Marshal(wire)
+	if err != nil {
+		return nil, fmt.Errorf("marshal batch: %w", err)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.batchURL, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("construct batch request: %w", err)
+	}
+	request.Header.Set(headerContentType, contentTypeJSON)
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return nil, fmt.Errorf("batch failed with HTTP status %d: %s", response.StatusCode, bytes.TrimSpace(message))
+	}
+	var batchResponse wireResponse
+	if err := json.NewDecoder(response.Body).Decode(&batchResponse); err != nil {
+		return nil, fmt.Errorf("unmarshal batch response: %w", err)
+	}
+	return batchResponse.Results, nil
+}
+
+// newResponse builds the standalone HTTP response for one call.
+func newResponse(request *http.Request, result wireResult) *http.Response {
+	header := result.Header
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", result.Status, http.StatusText(result.Status)),
+		StatusCode:    result.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Trailer:       make(http.Header),
+		Body:          io.NopCloser(bytes.NewReader(result.Body)),
+		ContentLength: int64(len(result.Body)),
+		Request:       request,
+	}
+}
+
+// wireSize estimates the size of a call's JSON encoding in a batch, including
+// the Connect-Timeout-Ms header added when the batch is sent.
+func wireSize(request *http.Request, body []byte) int64 {
+	const overhead = len(`{"procedure":"","header":{},"body":""},`) + len(`"Connect-Timeout-Ms":["9999999999"],`)
+	size := overhead + len(request.URL.RequestURI()) + base64.StdEncoding.EncodedLen(len(body))
+	for key, values := range request.Header {
+		size += len(key) + len(`"":[],`)
+		for _, value := range values {
+			size += len(value) + len(`"",`)
+		}
+	}
+	return int64(size)
+}
+
+type pendingCall struct {
+	request *http.Request
+	body    []byte
+	size    int64
+
+	once     sync.Once
+	done     chan struct{}
+	response *http.Response
+	err      error
+}
+
+func (c *pendingCall) finish(response *http.Response, err error) {
+	c.once.Do(func() {
+		c.response = response
+		c.err = err
+		close(c.done)
+	})
+}
@@ -0,0 +1,222 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+)
+
+const (
+	defaultMaxCalls     = 64
+	defaultReadMaxBytes = 4 * 1024 * 1024 // 4MiB
+)
+
+// NewHandler returns the path of the batch endpoint and an http.Handler that
+// serves it, ready to register on the same mux as your Connect handlers:
+//
+//   mux := http.NewServeMux()
+//   mux.Handle(pingv1connect.NewPingServiceHandler(pingServer))
+//   mux.Handle(batch.NewHandler(mux))
+//
+// The handler dispatches each call in the batch to next, concurrently, as a
+// standalone Connect unary request. The calls inherit the batch request's
+// context, remote address, TLS state, and host, so interceptors and handlers
+// see them as ordinary RPCs. They also inherit the batch request's headers,
+// like Authorization and Cookie, except for those that describe the batch's
+// own body or connection. Headers sent with a call take precedence over the
+// batch request's. Calls that aren't Connect unary requests fail with
+// CodeInvalidArgument, and so do calls to batch endpoints: batches can't be
+// nested.
+//
+// HTTP middleware wrapped around the mux only sees the batch request, not the
+// calls in it. Middleware that must see every call, like authentication or
+// rate limiting, must also wrap next:
+//
+//   mux := http.NewServeMux()
+//   mux.Handle(pingv1connect.NewPingServiceHandler(pingServer))
+//   mux.Handle(batch.NewHandler(authenticate(mux)))
+//   server := &http.Server{Handler: authenticate(mux)}
+func NewHandler(next http.Handler, options ...HandlerOption) (string, http.Handler) {
+	handler := &handler{
+		next:         next,
+		path:         DefaultPath,
+		maxCalls:     defaultMaxCalls,
+		readMaxBytes: defaultReadMaxBytes,
+	}
+	for _, opt := range options {
+		opt.applyToHandler(handler)
+	}
+	return handler.path, handler
+}
+
+type handler struct {
+	next         http.Handler
+	path         string
+	maxCalls     int
+	readMaxBytes int64
+}
+
+// nestedContextKey marks the contexts of calls dispatched from a batch.
+type nestedContextKey struct{}
+
+func (h *handler) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	if request.Context().Value(nestedContextKey{}) != nil {
+		// The call was dispatched from another batch, perhaps one served at a
+		// different path.
+		errorResult("nested batch calls aren't supported").write(responseWriter)
+		return
+	}
+	if request.Method != http.MethodPost {
+		responseWriter.Header().Set("Allow", http.MethodPost)
+		http.Error(responseWriter, "batch requests must use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if request.Header.Get(headerContentType) != contentTypeJSON {
+		http.Error(responseWriter, "batch requests must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(request.Body, h.readMaxBytes+1))
+	if err != nil {
+		http.Error(responseWriter, fmt.Sprintf("read batch: %v", err), http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > h.readMaxBytes {
+		http.Error(responseWriter, "batch is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var batch wireRequest
+	if err := json.Unmarshal(body, &batch); err != nil {
+		http.Error(responseWriter, fmt.Sprintf("invalid batch: %v", err), http.StatusBadRequest)
+		return
+	}
+	if h.maxCalls > 0 && len(batch.Calls) > h.maxCalls {
+		http.Error(
+			responseWriter,
+			fmt.Sprintf("batch has %d calls, more than the maximum of %d", len(batch.Calls), h.maxCalls),
+			http.StatusRequestEntityTooLarge,
+		)
+		return
+	}
+	results := make([]wireResult, len(batch.Calls))
+	var wg sync.WaitGroup
+	for i, call := range batch.Calls {
+		wg.Add(1)
+		go func(i int, call wireCall) {
+			defer wg.Done()
+			results[i] = h.dispatch(request, call)
+		}(i, call)
+	}
+	wg.Wait()
+	responseWriter.Header().Set(headerContentType, contentTypeJSON)
+	_ = json.NewEncoder(responseWriter).Encode(&wireResponse{Results: results})
+}
+
+// dispatch serves a single call from the batch.
+func (h *handler) dispatch(batchRequest *http.Request, call wireCall) wireResult {
+	procedure, err := url.Parse(call.Procedure)
+	if err != nil {
+		return errorResult("invalid procedure %q: %v", call.Procedure, err)
+	}
+	if procedure.Scheme != "" || procedure.Host != "" || procedure.User != nil || procedure.Opaque != "" ||
+		len(procedure.Path) == 0 || procedure.Path[0] != '/' {
+		return errorResult("invalid procedure %q: must be an absolute path", call.Procedure)
+	}
+	if path.Clean(procedure.Path) == h.path {
+		return errorResult("nested batch calls aren't supported")
+	}
+	if contentType := call.Header.Get(headerContentType); !isConnectUnary(contentType) {
+		return errorResult("content type %q isn't a Connect unary content type", contentType)
+	}
+	request, err := http.NewRequestWithContext(
+		context.WithValue(batchRequest.Context(), nestedContextKey{}, struct{}{}),
+		http.MethodPost,
+		call.Procedure,
+		bytes.NewReader(call.Body),
+	)
+	if err != nil {
+		return errorResult("invalid procedure %q: %v", call.Procedure, err)
+	}
+	request.Header = callHeader(batchRequest.Header, call.Header)
+	request.ContentLength = int64(len(call.Body))
+	request.Host = batchRequest.Host
+	request.RemoteAddr = batchRequest.RemoteAddr
+	request.TLS = batchRequest.TLS
+	request.Proto = batchRequest.Proto
+	request.ProtoMajor = batchRequest.ProtoMajor
+	request.ProtoMinor = batchRequest.ProtoMinor
+	recorder := newResponseRecorder()
+	h.next.ServeHTTP(recorder, request)
+	return recorder.Result()
+}
+
+// callHeader returns the headers for a call: the call's own headers, plus
+// any of the batch request's headers that the call doesn't set.
+func callHeader(batchHeader, header http.Header) http.Header {
+	merged := make(http.Header, len(batchHeader)+len(header))
+	for key, values := range header {
+		key = http.CanonicalHeaderKey(key)
+		merged[key] = append(merged[key], values...)
+	}
+	for key, values := range batchHeader {
+		if _, ok := merged[key]; ok || isBatchOnlyHeader(key) {
+			continue
+		}
+		merged[key] = append([]string(nil), values...)
+	}
+	return merged
+}
+
+// isBatchOnlyHeader reports whether the canonical header key describes the
+// batch request's body or connection, rather than the calls in it.
+func isBatchOnlyHeader(key string) bool {
+	switch key {
+	case "Accept-Encoding", "Content-Encoding", "Content-Length", headerContentType,
+		"Connection", "Keep-Alive", "Te", "Trailer", "Transfer-Encoding", "Upgrade":
+		return true
+	default:
+		return false
+	}
+}
+
+// write copies the result to the response writer.
+func (r wireResult) write(responseWriter http.ResponseWriter) {
+	for key, values := range r.Header {
+		responseWriter.Header()[key] = values
+	}
+	responseWriter.WriteHeader(r.Status)
+	_, _ = responseWriter.Write(r.Body)
+}
+
+// errorResult returns a result with a Connect unary error, so clients see it
+// as a coded error from the individual call.
+func errorResult(template string, args ...any) wireResult {
+	body, _ := json.Marshal(map[string]string{
+		"code":    "invalid_argument",
+		"message": fmt.Sprintf(template, args...),
+	})
+	return wireResult{
+		Status: http.StatusBadRequest,
+		Header: http.Header{headerContentType: []string{contentTypeJSON}},
+		Body:   body,
+	}
+}
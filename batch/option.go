@@ -0,0 +1,125 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"time"
+)
+
+// A ClientOption configures the HTTPClient returned by NewHTTPClient.
+type ClientOption interface {
+	applyToClient(*HTTPClient)
+}
+
+// A HandlerOption configures the handler returned by NewHandler.
+type HandlerOption interface {
+	applyToHandler(*handler)
+}
+
+// An Option configures both clients and handlers.
+type Option interface {
+	ClientOption
+	HandlerOption
+}
+
+// WithWindow configures how long clients wait for more calls before sending a
+// batch. Longer windows produce larger batches, at the cost of added latency
+// for the first call in each batch.
+//
+// By default, clients wait 10 milliseconds.
+func WithWindow(window time.Duration) ClientOption {
+	return &windowOption{window: window}
+}
+
+// WithMaxCalls limits the number of calls in a batch. Clients send a batch as
+// soon as it's full, and handlers reject larger batches.
+//
+// By default, batches may contain up to 64 calls.
+func WithMaxCalls(max int) Option {
+	return &maxCallsOption{max: max}
+}
+
+// WithMaxBytes limits the size of the batch request bodies that clients send.
+// Before queueing a call that would push the batch past the limit, clients
+// send the calls already queued. A call that's larger than the limit on its
+// own is sent in a batch by itself. Sizes are estimated from each call's
+// procedure, headers, and body, so leave some headroom below the handler's
+// limit.
+//
+// By default, clients limit batches to 4MiB, the handler's default
+// WithReadMaxBytes. Set max to zero or less to remove the limit.
+func WithMaxBytes(max int64) ClientOption {
+	return &maxBytesOption{max: max}
+}
+
+// WithPath configures the URL path of the batch endpoint returned by
+// NewHandler.
+//
+// By default, handlers use DefaultPath.
+func WithPath(path string) HandlerOption {
+	return &pathOption{path: path}
+}
+
+// WithReadMaxBytes limits the size of the batch request body that handlers
+// accept. Larger batches are rejected with HTTP status 413.
+//
+// By default, batches may be up to 4MiB.
+func WithReadMaxBytes(max int64) HandlerOption {
+	return &readMaxBytesOption{max: max}
+}
+
+type windowOption struct {
+	window time.Duration
+}
+
+func (o *windowOption) applyToClient(client *HTTPClient) {
+	client.window = o.window
+}
+
+type maxCallsOption struct {
+	max int
+}
+
+func (o *maxCallsOption) applyToClient(client *HTTPClient) {
+	client.maxCalls = o.max
+}
+
+func (o *maxCallsOption) applyToHandler(handler *handler) {
+	handler.maxCalls = o.max
+}
+
+type maxBytesOption struct {
+	max int64
+}
+
+func (o *maxBytesOption) applyToClient(client *HTTPClient) {
+	client.maxBytes = o.max
+}
+
+type pathOption struct {
+	path string
+}
+
+func (o *pathOption) applyToHandler(handler *handler) {
+	handler.path = o.path
+}
+
+type readMaxBytesOption struct {
+	max int64
+}
+
+func (o *readMaxBytesOption) applyToHandler(handler *handler) {
+	handler.readMaxBytes = o.max
+}
@@ -54,10 +54,15 @@ func BenchmarkConnect(b *testing.B) {
 		connect.WithGRPC(),
 		connect.WithSendGzip(),
 	)
+	uncompressedClient := pingv1connect.NewPingServiceClient(
+		httpClient,
+		server.URL,
+	)
 	twoMiB := strings.Repeat("a", 2*1024*1024)
 	b.ResetTimer()
 
 	b.Run("unary", func(b *testing.B) {
+		b.ReportAllocs()
 		b.RunParallel(func(pb *testing.PB) {
 			for pb.Next() {
 				_, _ = client.Ping(
@@ -67,6 +72,17 @@ func BenchmarkConnect(b *testing.B) {
 			}
 		})
 	})
+	b.Run("unary_uncompressed", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_, _ = uncompressedClient.Ping(
+					context.Background(),
+					connect.NewRequest(&pingv1.PingRequest{Text: twoMiB}),
+				)
+			}
+		})
+	})
 }
 
 type ping struct {
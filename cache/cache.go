@@ -0,0 +1,453 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cache provides a client-side connect.Interceptor that caches the
+// responses to idempotent unary RPCs. It follows the familiar HTTP caching
+// model: handlers control freshness with the Cache-Control response header,
+// and responses with an ETag header can be cheaply revalidated once they're
+// stale.
+//
+// Revalidation requests carry an If-None-Match header. If the handler's
+// current ETag matches, it should return the response produced by
+// NotModified, and the client serves the cached response instead. Handlers
+// that don't support revalidation can ignore the header and return a full
+// response.
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	headerAuthorization = "Authorization"
+	headerCacheControl  = "Cache-Control"
+	headerETag          = "Etag"
+	headerIfNoneMatch   = "If-None-Match"
+	headerNotModified   = "Not-Modified"
+	headerVary          = "Vary"
+)
+
+// An Entry is a cached response.
+type Entry struct {
+	// Message is the deterministic binary Protobuf encoding of the response
+	// message.
+	Message []byte
+	Header  http.Header
+	Trailer http.Header
+	// ETag is the value of the response's ETag header, if any.
+	ETag string
+	// Vary holds the values of the request headers named by the response's
+	// Vary header. The entry is only used for requests with the same values.
+	Vary http.Header
+	// Expires is the time after which the entry must be revalidated before
+	// it's used. Entries stored with "Cache-Control: no-cache" expire
+	// immediately.
+	Expires time.Time
+}
+
+// Size estimates the memory used by the entry, in bytes.
+func (e *Entry) Size() int {
+	size := len(e.Message) + len(e.ETag)
+	for _, header := range []http.Header{e.Header, e.Trailer, e.Vary} {
+		for key, values := range header {
+			size += len(key)
+			for _, value := range values {
+				size += len(value)
+			}
+		}
+	}
+	return size
+}
+
+// A Store holds cached responses. Keys are opaque strings derived from the
+// protocol, the URL, and the request message. Stores must be safe to call
+// concurrently, and they may evict entries at any time.
+//
+// Entries returned from Get are never modified by the interceptor, so Stores
+// may return the same *Entry repeatedly.
+type Store interface {
+	Get(key string) (*Entry, bool)
+	Set(key string, entry *Entry)
+	Delete(key string)
+}
+
+// NotModified returns a response for handlers to return when the request's
+// If-None-Match header matches the ETag of the current response. The response
+// has an empty message and a Not-Modified header with the ETag, which tells
+// the client to serve its cached copy instead. The cached copy is refreshed
+// with any Cache-Control header set on the returned response.
+//
+// Clients only send If-None-Match when revalidating, so clients without a
+// cache never see this response.
+func NotModified[T any](etag string) *connect.Response[T] {
+	response := connect.NewResponse(new(T))
+	response.Header().Set(headerNotModified, etag)
+	return response
+}
+
+// IsNotModified reports whether a request's If-None-Match header matches the
+// supplied ETag, in which case handlers should return NotModified.
+func IsNotModified(requestHeader http.Header, etag string) bool {
+	for _, candidate := range strings.Split(requestHeader.Get(headerIfNoneMatch), ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// NewInterceptor returns a client-side connect.Interceptor that caches unary
+// responses in the supplied Store. Responses are keyed by protocol, URL, and
+// the deterministic binary encoding of the request message, so only Protobuf
+// messages are cached. Request headers aren't part of the key, but responses
+// with a Vary header are only served to requests with the same values for the
+// listed headers.
+//
+// The interceptor behaves like a shared HTTP cache, since a Store is often
+// shared by all the callers in a process. Responses marked
+// "Cache-Control: private" aren't stored, and neither are responses to
+// requests with an Authorization header unless they're marked
+// "Cache-Control: public". Data that varies by caller in any other way, like
+// by the identity in a TLS client certificate or the context, must not be
+// cached unless the response lists the distinguishing headers in Vary.
+//
+// Only cacheable procedures are cached: those declared with
+// "option idempotency_level = NO_SIDE_EFFECTS" in the global Protobuf registry
+// and those listed with WithProcedures. Responses are stored according to
+// their Cache-Control header: max-age sets the freshness lifetime, no-cache
+// stores the response but revalidates it before every use, and no-store
+// prevents caching entirely. Responses without max-age are stored only if they
+// have an ETag, and are revalidated before every use. Callers can bypass fresh
+// entries by sending "Cache-Control: no-cache", or skip the cache entirely
+// with "Cache-Control: no-store".
+//
+// Cached responses are returned with the headers and trailers of the original
+// response. Each call gets its own copy of the message. Calls that already
+// carry an If-None-Match header are managing revalidation themselves, so they
+// bypass the cache. The interceptor has no effect on handlers or streaming
+// RPCs.
+func NewInterceptor(store Store, options ...Option) connect.Interceptor {
+	interceptor := &interceptor{
+		store:      store,
+		procedures: make(map[string]struct{}),
+		now:        time.Now,
+	}
+	for _, opt := range options {
+		opt.apply(interceptor)
+	}
+	return interceptor
+}
+
+type interceptor struct {
+	store      Store
+	procedures map[string]struct{}
+	now        func() time.Time
+}
+
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
+		spec := request.Spec()
+		if !spec.IsClient || !i.cacheable(spec.Procedure) || request.Header().Get(headerIfNoneMatch) != "" {
+			return next(ctx, request)
+		}
+		directives := parseCacheControl(request.Header().Get(headerCacheControl))
+		if _, ok := directives["no-store"]; ok {
+			return next(ctx, request)
+		}
+		key, ok := cacheKey(request)
+		if !ok {
+			return next(ctx, request)
+		}
+		entry, hit := i.store.Get(key)
+		if hit && varyMatches(entry, request.Header()) {
+			_, noCache := directives["no-cache"]
+			if !noCache && i.now().Before(entry.Expires) {
+				return newCachedResponse(request, entry)
+			}
+			if entry.ETag != "" {
+				return i.revalidate(ctx, next, request, key, entry)
+			}
+		}
+		response, err := next(ctx, request)
+		if err != nil {
+			return response, err
+		}
+		if entry := i.newEntry(request, response); entry != nil {
+			i.store.Set(key, entry)
+		}
+		return response, nil
+	}
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+// revalidate sends a conditional request for a stale entry.
+func (i *interceptor) revalidate(
+	ctx context.Context,
+	next connect.UnaryFunc,
+	request connect.AnyRequest,
+	key string,
+	entry *Entry,
+) (connect.AnyResponse, error) {
+	// Send the conditional header on a copy, so the caller's headers aren't
+	// modified.
+	conditional := connect.CloneRequest(request)
+	conditional.Header().Set(headerIfNoneMatch, entry.ETag)
+	response, err := next(ctx, conditional)
+	if err != nil {
+		return response, err
+	}
+	notModified := response.Header().Get(headerNotModified)
+	if notModified == "" {
+		if entry := i.newEntry(request, response); entry != nil {
+			i.store.Set(key, entry)
+		} else {
+			i.store.Delete(key)
+		}
+		return response, nil
+	}
+	if notModified != entry.ETag {
+		i.store.Delete(key)
+		return nil, connect.NewError(
+			connect.CodeInternal,
+			fmt.Errorf("handler confirmed ETag %s, but the cached response has ETag %s", notModified, entry.ETag),
+		)
+	}
+	// The cached response is still valid. Refresh its lifetime, preferring
+	// caching directives sent with the revalidation.
+	refreshed := *entry
+	cacheControl := response.Header().Get(headerCacheControl)
+	if cacheControl == "" {
+		cacheControl = entry.Header.Get(headerCacheControl)
+	}
+	expires, store := i.expires(cacheControl, entry.ETag)
+	if !store {
+		i.store.Delete(key)
+	} else {
+		refreshed.Expires = expires
+		i.store.Set(key, &refreshed)
+	}
+	return newCachedResponse(request, &refreshed)
+}
+
+// newEntry returns the entry to store for the response, or nil if the
+// response shouldn't be cached.
+func (i *interceptor) newEntry(request connect.AnyRequest, response connect.AnyResponse) *Entry {
+	cacheControl := response.Header().Get(headerCacheControl)
+	directives := parseCacheControl(cacheControl)
+	if _, ok := directives["private"]; ok {
+		return nil
+	}
+	if _, ok := directives["public"]; !ok && request.Header().Get(headerAuthorization) != "" {
+		return nil
+	}
+	vary, ok := varyValues(response.Header(), request.Header())
+	if !ok {
+		return nil
+	}
+	etag := response.Header().Get(headerETag)
+	expires, store := i.expires(cacheControl, etag)
+	if !store {
+		return nil
+	}
+	message, ok := response.Any().(proto.Message)
+	if !ok {
+		return nil
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		return nil
+	}
+	return &Entry{
+		Message: data,
+		Header:  response.Header().Clone(),
+		Trailer: response.Trailer().Clone(),
+		ETag:    etag,
+		Vary:    vary,
+		Expires: expires,
+	}
+}
+
+// varyValues returns the request headers named by the response's Vary header.
+// It returns false if the response varies on something other than request
+// headers.
+func varyValues(responseHeader, requestHeader http.Header) (http.Header, bool) {
+	var vary http.Header
+	for _, value := range responseHeader.Values(headerVary) {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name == "*" {
+				return nil, false
+			}
+			if vary == nil {
+				vary = make(http.Header)
+			}
+			name = http.CanonicalHeaderKey(name)
+			vary[name] = append([]string{}, requestHeader.Values(name)...)
+		}
+	}
+	return vary, true
+}
+
+// varyMatches reports whether the request has the same values as the entry
+// for the headers named by the response's Vary header.
+func varyMatches(entry *Entry, requestHeader http.Header) bool {
+	for name, values := range entry.Vary {
+		current := requestHeader.Values(name)
+		if len(current) != len(values) {
+			return false
+		}
+		for i := range values {
+			if current[i] != values[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// expires returns the expiration time for a response with the given
+// Cache-Control header and ETag, and whether the response may be stored.
+func (i *interceptor) expires(cacheControl, etag string) (time.Time, bool) {
+	directives := parseCacheControl(cacheControl)
+	if _, ok := directives["no-store"]; ok {
+		return time.Time{}, false
+	}
+	now := i.now()
+	if _, ok := directives["no-cache"]; ok {
+		return now, etag != ""
+	}
+	if maxAge, ok := directives["max-age"]; ok {
+		seconds, err := strconv.ParseInt(maxAge, 10 /* base */, 64 /* bitsize */)
+		if err == nil && seconds > 0 {
+			return now.Add(time.Duration(seconds) * time.Second), true
+		}
+	}
+	return now, etag != ""
+}
+
+func (i *interceptor) cacheable(procedure string) bool {
+	if _, ok := i.procedures[procedure]; ok {
+		return true
+	}
+	return hasNoSideEffects(procedure)
+}
+
+// hasNoSideEffects looks up the procedure's method descriptor in the global
+// registry and checks its idempotency level.
+func hasNoSideEffects(procedure string) bool {
+	name := protoreflect.FullName(strings.ReplaceAll(strings.TrimPrefix(procedure, "/"), "/", "."))
+	descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err != nil {
+		return false
+	}
+	method, ok := descriptor.(protoreflect.MethodDescriptor)
+	if !ok {
+		return false
+	}
+	options, ok := method.Options().(*descriptorpb.MethodOptions)
+	return ok && options.GetIdempotencyLevel() == descriptorpb.MethodOptions_NO_SIDE_EFFECTS
+}
+
+// cacheKey derives a Store key from the protocol, the URL, and the request
+// message. The URL includes the procedure, so responses from different
+// servers or for different procedures never collide.
+func cacheKey(request connect.AnyRequest) (string, bool) {
+	protoMessage, ok := request.Any().(proto.Message)
+	if !ok {
+		return "", false
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(protoMessage)
+	if err != nil {
+		return "", false
+	}
+	peer := request.Peer()
+	var key strings.Builder
+	key.WriteString(peer.Protocol)
+	key.WriteByte(0)
+	if peer.URL != nil {
+		key.WriteString(peer.URL.String())
+	} else {
+		key.WriteString(peer.Addr)
+		key.WriteString(request.Spec().Procedure)
+	}
+	key.WriteByte(0)
+	key.Write(data)
+	return key.String(), true
+}
+
+// parseCacheControl parses a Cache-Control header into a map of lower-cased
+// directives to their (possibly empty) values.
+func parseCacheControl(header string) map[string]string {
+	if header == "" {
+		return nil
+	}
+	directives := make(map[string]string)
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(directive, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return directives
+}
+
+// newCachedResponse builds a response from the entry, with the type expected
+// by the client sending the request.
+func newCachedResponse(request connect.AnyRequest, entry *Entry) (connect.AnyResponse, error) {
+	response, ok := connect.NewClientResponse(request)
+	if !ok {
+		return nil, connect.NewError(connect.CodeInternal, errors.New("cached response requested outside a client"))
+	}
+	protoMessage, ok := response.Any().(proto.Message)
+	if !ok {
+		return nil, connect.NewError(connect.CodeInternal, errors.New("cached response isn't a Protobuf message"))
+	}
+	if err := proto.Unmarshal(entry.Message, protoMessage); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	mergeHeaders(response.Header(), entry.Header)
+	mergeHeaders(response.Trailer(), entry.Trailer)
+	return response, nil
+}
+
+func mergeHeaders(into, from http.Header) {
+	for key, values := range from {
+		into[key] = append(into[key], values...)
+	}
+}
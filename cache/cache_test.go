@@ -0,0 +1,295 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/bufbuild/connect-go/cache"
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connect"
+)
+
+const pingProcedure = "/connect.ping.v1.PingService/Ping"
+
+// pingServer sets caching headers based on the request text.
+type pingServer struct {
+	pingv1connect.UnimplementedPingServiceHandler
+
+	calls int64
+}
+
+func (p *pingServer) Ping(_ context.Context, request *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+	calls := atomic.AddInt64(&p.calls, 1)
+	const etag = `"v1"`
+	if request.Msg.Text == "etag" && cache.IsNotModified(request.Header(), etag) {
+		return cache.NotModified[pingv1.PingResponse](etag), nil
+	}
+	response := connect.NewResponse(&pingv1.PingResponse{Number: calls, Text: request.Msg.Text})
+	switch request.Msg.Text {
+	case "max-age":
+		response.Header().Set("Cache-Control", "max-age=60")
+	case "etag":
+		response.Header().Set("Cache-Control", "no-cache")
+		response.Header().Set("Etag", etag)
+	case "no-store":
+		response.Header().Set("Cache-Control", "no-store, max-age=60")
+	case "private":
+		response.Header().Set("Cache-Control", "private, max-age=60")
+	case "public":
+		response.Header().Set("Cache-Control", "public, max-age=60")
+	case "vary":
+		response.Header().Set("Cache-Control", "max-age=60")
+		response.Header().Set("Vary", "X-Tenant")
+	}
+	response.Trailer().Set("Ping-Trailer", "trailer")
+	return response, nil
+}
+
+func (p *pingServer) Calls() int64 {
+	return atomic.LoadInt64(&p.calls)
+}
+
+func TestInterceptor(t *testing.T) {
+	t.Parallel()
+	newServer := func(t *testing.T) (*pingServer, *httptest.Server) {
+		t.Helper()
+		server := &pingServer{}
+		mux := http.NewServeMux()
+		mux.Handle(pingv1connect.NewPingServiceHandler(server))
+		httpServer := httptest.NewServer(mux)
+		t.Cleanup(httpServer.Close)
+		return server, httpServer
+	}
+	newClientWithStore := func(
+		t *testing.T,
+		httpServer *httptest.Server,
+		store cache.Store,
+		options ...cache.Option,
+	) (pingv1connect.PingServiceClient, *fakeClock) {
+		t.Helper()
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		options = append(options, cache.WithClock(clock.Now))
+		client := pingv1connect.NewPingServiceClient(
+			httpServer.Client(),
+			httpServer.URL,
+			connect.WithInterceptors(cache.NewInterceptor(store, options...)),
+		)
+		return client, clock
+	}
+	newClient := func(t *testing.T, options ...cache.Option) (pingv1connect.PingServiceClient, *pingServer, *fakeClock) {
+		t.Helper()
+		server, httpServer := newServer(t)
+		client, clock := newClientWithStore(t, httpServer, cache.NewLRUStore(100, 0), options...)
+		return client, server, clock
+	}
+	pingWithHeader := func(t *testing.T, client pingv1connect.PingServiceClient, text, key, value string) *connect.Response[pingv1.PingResponse] {
+		t.Helper()
+		request := connect.NewRequest(&pingv1.PingRequest{Text: text})
+		request.Header().Set(key, value)
+		response, err := client.Ping(context.Background(), request)
+		assert.Nil(t, err)
+		return response
+	}
+	ping := func(t *testing.T, client pingv1connect.PingServiceClient, text string) *connect.Response[pingv1.PingResponse] {
+		t.Helper()
+		response, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{Text: text}))
+		assert.Nil(t, err)
+		return response
+	}
+
+	t.Run("max_age", func(t *testing.T) {
+		t.Parallel()
+		client, server, clock := newClient(t, cache.WithProcedures(pingProcedure))
+		first := ping(t, client, "max-age")
+		second := ping(t, client, "max-age")
+		assert.Equal(t, server.Calls(), int64(1))
+		assert.Equal(t, second.Msg.Number, first.Msg.Number)
+		assert.Equal(t, second.Header().Get("Cache-Control"), "max-age=60")
+		assert.Equal(t, second.Trailer().Get("Ping-Trailer"), "trailer")
+		// Cached responses are independent copies.
+		second.Msg.Number = 42
+		assert.Equal(t, ping(t, client, "max-age").Msg.Number, first.Msg.Number)
+		// Different requests have different keys.
+		ping(t, client, "other")
+		assert.Equal(t, server.Calls(), int64(2))
+		// Expired entries without an ETag are refetched.
+		clock.Advance(time.Minute)
+		assert.Equal(t, ping(t, client, "max-age").Msg.Number, int64(3))
+	})
+	t.Run("revalidate", func(t *testing.T) {
+		t.Parallel()
+		client, server, _ := newClient(t, cache.WithProcedures(pingProcedure))
+		first := ping(t, client, "etag")
+		second := ping(t, client, "etag")
+		// The handler saw both requests, but the second response came from the
+		// cache.
+		assert.Equal(t, server.Calls(), int64(2))
+		assert.Equal(t, second.Msg.Number, first.Msg.Number)
+		assert.Equal(t, second.Header().Get("Etag"), `"v1"`)
+		assert.Equal(t, second.Header().Get("Not-Modified"), "")
+		// Revalidation doesn't modify the caller's headers.
+		request := connect.NewRequest(&pingv1.PingRequest{Text: "etag"})
+		_, err := client.Ping(context.Background(), request)
+		assert.Nil(t, err)
+		assert.Equal(t, len(request.Header().Values("If-None-Match")), 0)
+		// Callers sending their own If-None-Match bypass the cache.
+		request = connect.NewRequest(&pingv1.PingRequest{Text: "etag"})
+		request.Header().Set("If-None-Match", `"v0"`)
+		_, err = client.Ping(context.Background(), request)
+		assert.Nil(t, err)
+		assert.Equal(t, request.Header().Get("If-None-Match"), `"v0"`)
+	})
+	t.Run("persistent_store", func(t *testing.T) {
+		t.Parallel()
+		store := cache.NewLRUStore(100, 0)
+		server, httpServer := newServer(t)
+		client, _ := newClientWithStore(t, httpServer, store, cache.WithProcedures(pingProcedure))
+		first := ping(t, client, "max-age")
+		// A new client, like one created after a restart, is served from entries
+		// stored earlier.
+		restarted, _ := newClientWithStore(t, httpServer, store, cache.WithProcedures(pingProcedure))
+		second := ping(t, restarted, "max-age")
+		assert.Equal(t, server.Calls(), int64(1))
+		assert.Equal(t, second.Msg.Number, first.Msg.Number)
+		// Entries aren't shared between servers or protocols.
+		otherServer, otherHTTPServer := newServer(t)
+		other, _ := newClientWithStore(t, otherHTTPServer, store, cache.WithProcedures(pingProcedure))
+		ping(t, other, "max-age")
+		assert.Equal(t, otherServer.Calls(), int64(1))
+		grpcWebClient := pingv1connect.NewPingServiceClient(
+			httpServer.Client(),
+			httpServer.URL,
+			connect.WithGRPCWeb(),
+			connect.WithInterceptors(cache.NewInterceptor(store, cache.WithProcedures(pingProcedure))),
+		)
+		_, err := grpcWebClient.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{Text: "max-age"}))
+		assert.Nil(t, err)
+		assert.Equal(t, server.Calls(), int64(2))
+	})
+	t.Run("private", func(t *testing.T) {
+		t.Parallel()
+		client, server, _ := newClient(t, cache.WithProcedures(pingProcedure))
+		ping(t, client, "private")
+		ping(t, client, "private")
+		assert.Equal(t, server.Calls(), int64(2))
+	})
+	t.Run("authorization", func(t *testing.T) {
+		t.Parallel()
+		client, server, _ := newClient(t, cache.WithProcedures(pingProcedure))
+		pingWithHeader(t, client, "max-age", "Authorization", "Bearer alice")
+		pingWithHeader(t, client, "max-age", "Authorization", "Bearer bob")
+		assert.Equal(t, server.Calls(), int64(2))
+		// Public responses are cached even for authorized requests.
+		pingWithHeader(t, client, "public", "Authorization", "Bearer alice")
+		pingWithHeader(t, client, "public", "Authorization", "Bearer bob")
+		assert.Equal(t, server.Calls(), int64(3))
+	})
+	t.Run("vary", func(t *testing.T) {
+		t.Parallel()
+		client, server, _ := newClient(t, cache.WithProcedures(pingProcedure))
+		acme := pingWithHeader(t, client, "vary", "X-Tenant", "acme")
+		assert.Equal(t, pingWithHeader(t, client, "vary", "X-Tenant", "acme").Msg.Number, acme.Msg.Number)
+		assert.Equal(t, server.Calls(), int64(1))
+		other := pingWithHeader(t, client, "vary", "X-Tenant", "other")
+		assert.NotEqual(t, other.Msg.Number, acme.Msg.Number)
+		assert.Equal(t, server.Calls(), int64(2))
+	})
+	t.Run("request_no_cache", func(t *testing.T) {
+		t.Parallel()
+		client, server, _ := newClient(t, cache.WithProcedures(pingProcedure))
+		ping(t, client, "max-age")
+		request := connect.NewRequest(&pingv1.PingRequest{Text: "max-age"})
+		request.Header().Set("Cache-Control", "no-cache")
+		_, err := client.Ping(context.Background(), request)
+		assert.Nil(t, err)
+		assert.Equal(t, server.Calls(), int64(2))
+	})
+	t.Run("no_store", func(t *testing.T) {
+		t.Parallel()
+		client, server, _ := newClient(t, cache.WithProcedures(pingProcedure))
+		ping(t, client, "no-store")
+		ping(t, client, "no-store")
+		assert.Equal(t, server.Calls(), int64(2))
+	})
+	t.Run("not_cacheable", func(t *testing.T) {
+		t.Parallel()
+		client, server, _ := newClient(t)
+		ping(t, client, "max-age")
+		ping(t, client, "max-age")
+		assert.Equal(t, server.Calls(), int64(2))
+	})
+}
+
+func TestLRUStore(t *testing.T) {
+	t.Parallel()
+	entry := func(size int) *cache.Entry {
+		return &cache.Entry{Message: make([]byte, size)}
+	}
+	t.Run("max_entries", func(t *testing.T) {
+		t.Parallel()
+		store := cache.NewLRUStore(2, 0)
+		store.Set("a", entry(1))
+		store.Set("b", entry(1))
+		_, ok := store.Get("a") // b is now least recently used
+		assert.True(t, ok)
+		store.Set("c", entry(1))
+		assert.Equal(t, store.Len(), 2)
+		_, ok = store.Get("b")
+		assert.False(t, ok)
+		_, ok = store.Get("a")
+		assert.True(t, ok)
+	})
+	t.Run("max_bytes", func(t *testing.T) {
+		t.Parallel()
+		store := cache.NewLRUStore(0, 10)
+		store.Set("a", entry(4)) // 5 bytes, including the key
+		store.Set("b", entry(4))
+		store.Set("c", entry(4))
+		assert.Equal(t, store.Len(), 2)
+		_, ok := store.Get("a")
+		assert.False(t, ok)
+		store.Set("d", entry(100))
+		_, ok = store.Get("d")
+		assert.False(t, ok)
+		store.Delete("b")
+		assert.Equal(t, store.Len(), 1)
+	})
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
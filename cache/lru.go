@@ -0,0 +1,107 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRUStore is an in-memory Store that evicts the least recently used entries
+// once it exceeds its size limits.
+type LRUStore struct {
+	maxEntries int
+	maxBytes   int
+
+	mu    sync.Mutex
+	bytes int
+	order *list.List // front is most recently used
+	items map[string]*list.Element
+}
+
+var _ Store = (*LRUStore)(nil)
+
+type lruItem struct {
+	key   string
+	entry *Entry
+	size  int
+}
+
+// NewLRUStore constructs an LRUStore that holds at most maxEntries entries
+// and maxBytes bytes, as measured by Entry.Size plus the length of each key.
+// A limit of zero or less disables that limit. Entries larger than maxBytes
+// are never stored.
+func NewLRUStore(maxEntries, maxBytes int) *LRUStore {
+	return &LRUStore{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get returns the entry stored under the key and marks it as recently used.
+func (s *LRUStore) Get(key string) (*Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	element, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	s.order.MoveToFront(element)
+	return element.Value.(*lruItem).entry, true // nolint:forcetypeassert
+}
+
+// Set stores the entry, evicting the least recently used entries as
+// necessary.
+func (s *LRUStore) Set(key string, entry *Entry) {
+	size := len(key) + entry.Size()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(key)
+	if s.maxBytes > 0 && size > s.maxBytes {
+		return
+	}
+	s.items[key] = s.order.PushFront(&lruItem{key: key, entry: entry, size: size})
+	s.bytes += size
+	for (s.maxEntries > 0 && s.order.Len() > s.maxEntries) ||
+		(s.maxBytes > 0 && s.bytes > s.maxBytes) {
+		s.remove(s.order.Back().Value.(*lruItem).key) // nolint:forcetypeassert
+	}
+}
+
+// Delete removes the entry stored under the key, if any.
+func (s *LRUStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(key)
+}
+
+// Len returns the number of stored entries.
+func (s *LRUStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *LRUStore) remove(key string) {
+	element, ok := s.items[key]
+	if !ok {
+		return
+	}
+	s.order.Remove(element)
+	delete(s.items, key)
+	s.bytes -= element.Value.(*lruItem).size // nolint:forcetypeassert
+}
@@ -0,0 +1,57 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"time"
+)
+
+// An Option configures the interceptor returned by NewInterceptor.
+type Option interface {
+	apply(*interceptor)
+}
+
+// WithProcedures marks additional procedures as cacheable, for example
+// "/acme.foo.v1.FooService/Bar". Use it for procedures whose schemas aren't
+// in the global Protobuf registry or don't declare an idempotency level.
+func WithProcedures(procedures ...string) Option {
+	return &proceduresOption{procedures: procedures}
+}
+
+// WithClock configures the function used to get the current time, which is
+// useful in tests.
+//
+// By default, the interceptor uses time.Now.
+func WithClock(now func() time.Time) Option {
+	return &clockOption{now: now}
+}
+
+type proceduresOption struct {
+	procedures []string
+}
+
+func (o *proceduresOption) apply(i *interceptor) {
+	for _, procedure := range o.procedures {
+		i.procedures[procedure] = struct{}{}
+	}
+}
+
+type clockOption struct {
+	now func() time.Time
+}
+
+func (o *clockOption) apply(i *interceptor) {
+	i.now = o.now
+}
@@ -0,0 +1,123 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect
+
+import (
+	"context"
+	"net/http"
+)
+
+// CallInfo carries the metadata for a unary RPC made or handled with bare
+// messages, rather than Requests and Responses. Handlers written with
+// SimpleUnaryHandler find it in their context with CallInfoFromHandlerContext.
+// Clients calling CallUnarySimple attach one to their context with
+// NewClientContext.
+type CallInfo struct {
+	spec            Spec
+	peer            Peer
+	requestHeader   http.Header
+	responseHeader  http.Header
+	responseTrailer http.Header
+}
+
+type clientCallInfoContextKey struct{}
+
+type handlerCallInfoContextKey struct{}
+
+// NewClientContext returns a copy of ctx that carries a new CallInfo. Set
+// request headers on the CallInfo before passing the context to
+// CallUnarySimple; after the call returns, the CallInfo holds the call's
+// specification, peer, and response headers and trailers.
+//
+// Each CallInfo should be used for a single call.
+func NewClientContext(ctx context.Context) (context.Context, *CallInfo) {
+	info := &CallInfo{
+		requestHeader:   make(http.Header),
+		responseHeader:  make(http.Header),
+		responseTrailer: make(http.Header),
+	}
+	return context.WithValue(ctx, clientCallInfoContextKey{}, info), info
+}
+
+// CallInfoFromHandlerContext returns the CallInfo for the RPC being handled
+// by a SimpleUnaryHandler. Headers and trailers set on the CallInfo are sent
+// with the response. If the handler returns an error, they're added to the
+// metadata of a copy of the error, wrapped with CodeUnknown if it isn't
+// already an *Error, and sent with the error instead.
+func CallInfoFromHandlerContext(ctx context.Context) (*CallInfo, bool) {
+	info, ok := ctx.Value(handlerCallInfoContextKey{}).(*CallInfo)
+	return info, ok
+}
+
+// Spec returns a description of this RPC. On the client, it's populated once
+// the call returns.
+func (c *CallInfo) Spec() Spec {
+	return c.spec
+}
+
+// Peer describes the other party for this RPC. On the client, it's populated
+// once the call returns.
+func (c *CallInfo) Peer() Peer {
+	return c.peer.clone()
+}
+
+// RequestHeader returns the HTTP headers for the request.
+func (c *CallInfo) RequestHeader() http.Header {
+	return c.requestHeader
+}
+
+// ResponseHeader returns the HTTP headers for the response.
+func (c *CallInfo) ResponseHeader() http.Header {
+	return c.responseHeader
+}
+
+// ResponseTrailer returns the trailers for the response.
+func (c *CallInfo) ResponseTrailer() http.Header {
+	return c.responseTrailer
+}
+
+// SimpleUnaryHandler adapts a unary function that works with bare messages to
+// the signature expected by NewUnaryHandler. The function can access request
+// headers and set response headers and trailers through the CallInfo in its
+// context.
+func SimpleUnaryHandler[Req, Res any](
+	unary func(context.Context, *Req) (*Res, error),
+) func(context.Context, *Request[Req]) (*Response[Res], error) {
+	return func(ctx context.Context, request *Request[Req]) (*Response[Res], error) {
+		info := &CallInfo{
+			spec:            request.Spec(),
+			peer:            request.Peer(),
+			requestHeader:   request.Header(),
+			responseHeader:  make(http.Header),
+			responseTrailer: make(http.Header),
+		}
+		message, err := unary(context.WithValue(ctx, handlerCallInfoContextKey{}, info), request.Msg)
+		if err != nil {
+			if len(info.responseHeader) == 0 && len(info.responseTrailer) == 0 {
+				return nil, err
+			}
+			// Errors are often shared sentinels, so add the metadata to a copy.
+			connectErr, _ := asError(wrapIfUncoded(err))
+			connectErr = connectErr.clone()
+			mergeHeaders(connectErr.Meta(), info.responseHeader)
+			mergeHeaders(connectErr.Meta(), info.responseTrailer)
+			return nil, connectErr
+		}
+		response := NewResponse(message)
+		response.header = info.responseHeader
+		response.trailer = info.responseTrailer
+		return response, nil
+	}
+}
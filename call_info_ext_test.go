@@ -0,0 +1,109 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+)
+
+func TestSimpleUnary(t *testing.T) {
+	t.Parallel()
+	const procedure = "/connect.ping.v1.PingService/Ping"
+	errShared := connect.NewError(connect.CodeNotFound, errors.New("shared"))
+	ping := func(ctx context.Context, request *pingv1.PingRequest) (*pingv1.PingResponse, error) {
+		info, ok := connect.CallInfoFromHandlerContext(ctx)
+		if !ok {
+			return nil, connect.NewError(connect.CodeInternal, errors.New("no call info"))
+		}
+		if info.Spec().Procedure != procedure || info.Spec().IsClient {
+			return nil, connect.NewError(connect.CodeInternal, errors.New("wrong spec"))
+		}
+		info.ResponseHeader().Set(handlerHeader, info.RequestHeader().Get(clientHeader))
+		info.ResponseTrailer().Set(handlerTrailer, headerValue)
+		switch request.Number {
+		case -1:
+			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("negative number"))
+		case -2:
+			return nil, errShared
+		case -3:
+			return nil, errors.New("uncoded")
+		}
+		return &pingv1.PingResponse{Number: request.Number}, nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle(procedure, connect.NewUnaryHandler(procedure, connect.SimpleUnaryHandler(ping)))
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	for _, opt := range []connect.ClientOption{nil, connect.WithGRPC()} {
+		var options []connect.ClientOption
+		if opt != nil {
+			options = append(options, opt)
+		}
+		client := connect.NewClient[pingv1.PingRequest, pingv1.PingResponse](
+			server.Client(),
+			server.URL+procedure,
+			options...,
+		)
+		t.Run("success", func(t *testing.T) {
+			ctx, info := connect.NewClientContext(context.Background())
+			info.RequestHeader().Set(clientHeader, headerValue)
+			response, err := client.CallUnarySimple(ctx, &pingv1.PingRequest{Number: 42})
+			assert.Nil(t, err)
+			assert.Equal(t, response.Number, 42)
+			assert.Equal(t, info.Spec().Procedure, procedure)
+			assert.True(t, info.Spec().IsClient)
+			assert.Equal(t, info.ResponseHeader().Get(handlerHeader), headerValue)
+			assert.Equal(t, info.ResponseTrailer().Get(handlerTrailer), headerValue)
+		})
+		t.Run("error", func(t *testing.T) {
+			ctx, info := connect.NewClientContext(context.Background())
+			info.RequestHeader().Set(clientHeader, headerValue)
+			_, err := client.CallUnarySimple(ctx, &pingv1.PingRequest{Number: -1})
+			assert.Equal(t, connect.CodeOf(err), connect.CodeInvalidArgument)
+			assert.Equal(t, info.ResponseHeader().Get(handlerHeader), headerValue)
+		})
+		t.Run("shared_error", func(t *testing.T) {
+			ctx, info := connect.NewClientContext(context.Background())
+			info.RequestHeader().Set(clientHeader, headerValue)
+			_, err := client.CallUnarySimple(ctx, &pingv1.PingRequest{Number: -2})
+			assert.Equal(t, connect.CodeOf(err), connect.CodeNotFound)
+			assert.Equal(t, info.ResponseHeader().Get(handlerHeader), headerValue)
+			assert.Equal(t, len(errShared.Meta()), 0)
+		})
+		t.Run("uncoded_error", func(t *testing.T) {
+			ctx, info := connect.NewClientContext(context.Background())
+			info.RequestHeader().Set(clientHeader, headerValue)
+			_, err := client.CallUnarySimple(ctx, &pingv1.PingRequest{Number: -3})
+			assert.Equal(t, connect.CodeOf(err), connect.CodeUnknown)
+			assert.Equal(t, info.ResponseHeader().Get(handlerHeader), headerValue)
+		})
+		t.Run("without_call_info", func(t *testing.T) {
+			response, err := client.CallUnarySimple(context.Background(), &pingv1.PingRequest{Number: 1})
+			assert.Nil(t, err)
+			assert.Equal(t, response.Number, 1)
+		})
+	}
+}
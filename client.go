@@ -36,9 +36,9 @@ type Client[Req, Res any] struct {
 }
 
 // NewClient constructs a new Client.
-func NewClient[Req, Res any](httpClient HTTPClient, url string, options ...ClientOption) *Client[Req, Res] {
+func NewClient[Req, Res any](httpClient HTTPClient, rawURL string, options ...ClientOption) *Client[Req, Res] {
 	client := &Client[Req, Res]{}
-	config, err := newClientConfig(url, options)
+	config, err := newClientConfig(rawURL, options)
 	if err != nil {
 		client.err = err
 		return client
@@ -55,9 +55,11 @@ func NewClient[Req, Res any](httpClient HTTPClient, url string, options ...Clien
 			Protobuf:         config.protobuf(),
 			CompressMinBytes: config.CompressMinBytes,
 			HTTPClient:       httpClient,
-			URL:              url,
+			URL:              rawURL,
 			BufferPool:       config.BufferPool,
 			ReadMaxBytes:     config.ReadMaxBytes,
+			ErrorClassifiers: config.ErrorClassifiers,
+			StatsHandler:     config.StatsHandler,
 		},
 	)
 	if protocolErr != nil {
@@ -68,6 +70,7 @@ func NewClient[Req, Res any](httpClient HTTPClient, url string, options ...Clien
 	// Rather than applying unary interceptors along the hot path, we can do it
 	// once at client creation.
 	unarySpec := config.newSpec(StreamTypeUnary)
+	unaryPeer := protocolClient.Peer()
 	unaryFunc := UnaryFunc(func(ctx context.Context, request AnyRequest) (AnyResponse, error) {
 		conn := protocolClient.NewConn(ctx, unarySpec, request.Header())
 		// Send always returns an io.EOF unless the error is from the client-side.
@@ -82,7 +85,7 @@ func NewClient[Req, Res any](httpClient HTTPClient, url string, options ...Clien
 			_ = conn.CloseResponse()
 			return nil, err
 		}
-		response, err := receiveUnaryResponse[Res](conn)
+		response, err := receiveUnaryResponse[Res](conn, statsSourceFromContext(ctx))
 		if err != nil {
 			_ = conn.CloseResponse()
 			return nil, err
@@ -93,10 +96,16 @@ func NewClient[Req, Res any](httpClient HTTPClient, url string, options ...Clien
 		unaryFunc = interceptor.WrapUnary(unaryFunc)
 	}
 	client.callUnary = func(ctx context.Context, request *Request[Req]) (*Response[Res], error) {
-		// To make the specification and RPC headers visible to the full interceptor
-		// chain (as though they were supplied by the caller), we'll add them here.
+		// To make the specification, peer, and RPC headers visible to the full
+		// interceptor chain (as though they were supplied by the caller), we'll
+		// add them here.
 		request.spec = unarySpec
+		request.peer = unaryPeer
+		request.newResponse = newEmptyResponse[Res]
 		protocolClient.WriteRequestHeader(StreamTypeUnary, request.Header())
+		// Attach the call's Stats to the context, so that interceptors can read
+		// them even if the call fails.
+		ctx, _ = withStatsSource(ctx)
 		response, err := unaryFunc(ctx, request)
 		if err != nil {
 			return nil, err
@@ -118,12 +127,39 @@ func (c *Client[Req, Res]) CallUnary(ctx context.Context, request *Request[Req])
 	return c.callUnary(ctx, request)
 }
 
+// CallUnarySimple calls a request-response procedure with bare messages. To
+// send request headers or read the response headers and trailers, attach a
+// CallInfo to the context with NewClientContext.
+func (c *Client[Req, Res]) CallUnarySimple(ctx context.Context, message *Req) (*Res, error) {
+	request := NewRequest(message)
+	info, _ := ctx.Value(clientCallInfoContextKey{}).(*CallInfo)
+	if info != nil {
+		mergeHeaders(request.Header(), info.requestHeader)
+	}
+	response, err := c.CallUnary(ctx, request)
+	if info != nil {
+		info.spec = request.spec
+		info.peer = request.peer
+		if response != nil {
+			mergeHeaders(info.responseHeader, response.Header())
+			mergeHeaders(info.responseTrailer, response.Trailer())
+		} else if connectErr := new(Error); errors.As(err, &connectErr) {
+			mergeHeaders(info.responseHeader, connectErr.Meta())
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return response.Msg, nil
+}
+
 // CallClientStream calls a client streaming procedure.
 func (c *Client[Req, Res]) CallClientStream(ctx context.Context) *ClientStreamForClient[Req, Res] {
 	if c.err != nil {
 		return &ClientStreamForClient[Req, Res]{err: c.err}
 	}
-	return &ClientStreamForClient[Req, Res]{conn: c.newConn(ctx, StreamTypeClient)}
+	conn, stats := c.newConn(ctx, StreamTypeClient)
+	return &ClientStreamForClient[Req, Res]{conn: conn, stats: stats}
 }
 
 // CallServerStream calls a server streaming procedure.
@@ -131,7 +167,7 @@ func (c *Client[Req, Res]) CallServerStream(ctx context.Context, request *Reques
 	if c.err != nil {
 		return nil, c.err
 	}
-	conn := c.newConn(ctx, StreamTypeServer)
+	conn, stats := c.newConn(ctx, StreamTypeServer)
 	mergeHeaders(conn.RequestHeader(), request.header)
 	// Send always returns an io.EOF unless the error is from the client-side.
 	// We want the user to continue to call Receive in those cases to get the
@@ -144,7 +180,7 @@ func (c *Client[Req, Res]) CallServerStream(ctx context.Context, request *Reques
 	if err := conn.CloseRequest(); err != nil {
 		return nil, err
 	}
-	return &ServerStreamForClient[Res]{conn: conn}, nil
+	return &ServerStreamForClient[Res]{conn: conn, stats: stats}, nil
 }
 
 // CallBidiStream calls a bidirectional streaming procedure.
@@ -152,10 +188,13 @@ func (c *Client[Req, Res]) CallBidiStream(ctx context.Context) *BidiStreamForCli
 	if c.err != nil {
 		return &BidiStreamForClient[Req, Res]{err: c.err}
 	}
-	return &BidiStreamForClient[Req, Res]{conn: c.newConn(ctx, StreamTypeBidi)}
+	conn, stats := c.newConn(ctx, StreamTypeBidi)
+	return &BidiStreamForClient[Req, Res]{conn: conn, stats: stats}
 }
 
-func (c *Client[Req, Res]) newConn(ctx context.Context, streamType StreamType) StreamingClientConn {
+// newConn creates a conn, wrapped by any interceptors. The returned
+// statsSource is attached to the context seen by the interceptors.
+func (c *Client[Req, Res]) newConn(ctx context.Context, streamType StreamType) (StreamingClientConn, *statsSource) {
 	newConn := func(ctx context.Context, spec Spec) StreamingClientConn {
 		header := make(http.Header, 8) // arbitrary power of two, prevent immediate resizing
 		c.protocolClient.WriteRequestHeader(streamType, header)
@@ -164,12 +203,14 @@ func (c *Client[Req, Res]) newConn(ctx context.Context, streamType StreamType) S
 	if interceptor := c.config.Interceptor; interceptor != nil {
 		newConn = interceptor.WrapStreamingClient(newConn)
 	}
-	return newConn(ctx, c.config.newSpec(streamType))
+	ctx, stats := withStatsSource(ctx)
+	return newConn(ctx, c.config.newSpec(streamType)), stats
 }
 
 type clientConfig struct {
 	Protocol               protocol
 	Procedure              string
+	Schema                 any
 	CompressMinBytes       int
 	Interceptor            Interceptor
 	CompressionPools       map[string]*compressionPool
@@ -178,10 +219,12 @@ type clientConfig struct {
 	RequestCompressionName string
 	BufferPool             *bufferPool
 	ReadMaxBytes           int
+	ErrorClassifiers       []ErrorClassifier
+	StatsHandler           StatsHandler
 }
 
-func newClientConfig(url string, options []ClientOption) (*clientConfig, *Error) {
-	protoPath := extractProtoPath(url)
+func newClientConfig(rawURL string, options []ClientOption) (*clientConfig, *Error) {
+	protoPath := extractProtoPath(rawURL)
 	config := clientConfig{
 		Protocol:         &protocolConnect{},
 		Procedure:        protoPath,
@@ -222,6 +265,7 @@ func (c *clientConfig) newSpec(t StreamType) Spec {
 	return Spec{
 		StreamType: t,
 		Procedure:  c.Procedure,
+		Schema:     c.Schema,
 		IsClient:   true,
 	}
 }
@@ -25,7 +25,8 @@ import (
 // It's returned from Client.CallClientStream, but doesn't currently have an
 // exported constructor function.
 type ClientStreamForClient[Req, Res any] struct {
-	conn StreamingClientConn
+	conn  StreamingClientConn
+	stats *statsSource
 	// Error from client construction. If non-nil, return for all calls.
 	err error
 }
@@ -62,7 +63,7 @@ func (c *ClientStreamForClient[Req, Res]) CloseAndReceive() (*Response[Res], err
 		_ = c.conn.CloseResponse()
 		return nil, err
 	}
-	response, err := receiveUnaryResponse[Res](c.conn)
+	response, err := receiveUnaryResponse[Res](c.conn, c.stats)
 	if err != nil {
 		_ = c.conn.CloseResponse()
 		return nil, err
@@ -75,7 +76,8 @@ func (c *ClientStreamForClient[Req, Res]) CloseAndReceive() (*Response[Res], err
 // It's returned from Client.CallServerStream, but doesn't currently have an
 // exported constructor function.
 type ServerStreamForClient[Res any] struct {
-	conn StreamingClientConn
+	conn  StreamingClientConn
+	stats *statsSource
 	msg  Res
 	// Error from client construction. If non-nil, return for all calls.
 	constructErr error
@@ -132,6 +134,15 @@ func (s *ServerStreamForClient[Res]) ResponseTrailer() http.Header {
 	return s.conn.ResponseTrailer()
 }
 
+// Stats reports the messages and bytes exchanged so far.
+func (s *ServerStreamForClient[Res]) Stats() Stats {
+	if s.constructErr != nil {
+		return Stats{}
+	}
+	stats, _ := s.stats.Stats()
+	return stats
+}
+
 // Close the receive side of the stream.
 func (s *ServerStreamForClient[Res]) Close() error {
 	if s.constructErr != nil {
@@ -145,7 +156,8 @@ func (s *ServerStreamForClient[Res]) Close() error {
 // It's returned from Client.CallBidiStream, but doesn't currently have an
 // exported constructor function.
 type BidiStreamForClient[Req, Res any] struct {
-	conn StreamingClientConn
+	conn  StreamingClientConn
+	stats *statsSource
 	// Error from client construction. If non-nil, return for all calls.
 	err error
 }
@@ -218,3 +230,12 @@ func (b *BidiStreamForClient[Req, Res]) ResponseTrailer() http.Header {
 	}
 	return b.conn.ResponseTrailer()
 }
+
+// Stats reports the messages and bytes exchanged so far.
+func (b *BidiStreamForClient[Req, Res]) Stats() Stats {
+	if b.err != nil {
+		return Stats{}
+	}
+	stats, _ := b.stats.Stats()
+	return stats
+}
@@ -0,0 +1,411 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+const (
+	ioPackage    = protogen.GoImportPath("io")
+	protoPackage = protogen.GoImportPath("google.golang.org/protobuf/proto")
+	syncPackage  = protogen.GoImportPath("sync")
+
+	generatedFakeFilenameExtension = ".fake.go"
+	generatedFakePackageSuffix     = "fake"
+
+	// The fake client never makes network calls, but it still needs a
+	// well-formed URL.
+	fakeBaseURL = "http://fake.invalid"
+)
+
+// generateFakes writes a separate package with fake implementations of each
+// service's client and handler interfaces. The fakes import the connect code
+// generated for the file, whose import path is connectFile.
+func generateFakes(plugin *protogen.Plugin, file *protogen.File, connectFile protogen.GoImportPath, opts *options) {
+	fakePackageName := file.GoPackageName + generatedFakePackageSuffix
+	// The fakes are a sibling of the connect sub-package or, if the connect
+	// code is in the base package, a sub-package of it.
+	dir := path.Dir(file.GeneratedFilenamePrefix)
+	parentImportPath := string(connectFile)
+	if !opts.SamePackage {
+		dir = path.Dir(dir)
+		parentImportPath = path.Dir(parentImportPath)
+	}
+	g := plugin.NewGeneratedFile(
+		path.Join(dir, string(fakePackageName), path.Base(file.GeneratedFilenamePrefix)+generatedFakeFilenameExtension),
+		protogen.GoImportPath(path.Join(parentImportPath, string(fakePackageName))),
+	)
+	g.P("// Code generated by protoc-gen-connect-go. DO NOT EDIT.")
+	g.P("//")
+	g.P("// Source: ", file.Desc.Path())
+	g.P()
+	g.P("package ", fakePackageName)
+	g.P()
+	for _, service := range file.Services {
+		names := newNames(service)
+		if opts.generateServer() {
+			generateFakeHandler(g, service, names, connectFile, opts)
+		}
+		if opts.generateClient() {
+			generateFakeClient(g, service, names, connectFile, opts)
+		}
+	}
+}
+
+func generateFakeHandler(g *protogen.GeneratedFile, service *protogen.Service, names names, connectFile protogen.GoImportPath, opts *options) {
+	fake := names.Server
+	unimplemented := connectFile.Ident(names.UnimplementedServer)
+	wrapComments(g, fake, " is a fake implementation of ", connectFile.Ident(names.Server), ". ",
+		"Its zero value is ready to use, and methods without configured behavior return ",
+		"CodeUnimplemented errors. Unary methods are configured with function fields, ",
+		"and streaming methods with scripted responses. Each method records the request ",
+		"messages it receives.")
+	g.P("type ", fake, " struct {")
+	g.P(unimplemented)
+	g.P()
+	generateFakeFields(g, service, opts)
+	g.P("}")
+	g.P()
+	g.P("var _ ", connectFile.Ident(names.Server), " = (*", fake, ")(nil)")
+	g.P()
+	for _, method := range service.Methods {
+		isStreamingClient := method.Desc.IsStreamingClient()
+		isStreamingServer := method.Desc.IsStreamingServer()
+		switch {
+		case isStreamingClient && !isStreamingServer:
+			wrapComments(g, method.GoName, " receives and records all request messages, then returns ",
+				method.GoName, "Err or ", method.GoName, "Response.")
+			g.P("func (f *", fake, ") ", method.GoName, serverSignatureParams(g, method, true /* named */, false /* simple */), " {")
+			g.P("if f.", method.GoName, "Response == nil && f.", method.GoName, "Err == nil {")
+			g.P("return f.", names.UnimplementedServer, ".", method.GoName, "(ctx, stream)")
+			g.P("}")
+			g.P("for stream.Receive() {")
+			g.P("f.record", method.GoName, "(", protoPackage.Ident("Clone"), "(stream.Msg()).(*", method.Input.GoIdent, "))")
+			g.P("}")
+			g.P("if err := stream.Err(); err != nil {")
+			g.P("return nil, err")
+			g.P("}")
+			g.P("if f.", method.GoName, "Err != nil {")
+			g.P("return nil, f.", method.GoName, "Err")
+			g.P("}")
+			g.P("return ", connectPackage.Ident("NewResponse"), "(f.", method.GoName, "Response), nil")
+		case !isStreamingClient && isStreamingServer:
+			wrapComments(g, method.GoName, " records the request message, sends each of ",
+				method.GoName, "Responses, and then returns ", method.GoName, "Err.")
+			g.P("func (f *", fake, ") ", method.GoName, serverSignatureParams(g, method, true /* named */, false /* simple */), " {")
+			g.P("f.record", method.GoName, "(req.Msg)")
+			g.P("if f.", method.GoName, "Responses == nil && f.", method.GoName, "Err == nil {")
+			g.P("return f.", names.UnimplementedServer, ".", method.GoName, "(ctx, req, stream)")
+			g.P("}")
+			g.P("for _, res := range f.", method.GoName, "Responses {")
+			g.P("if err := stream.Send(res); err != nil {")
+			g.P("return err")
+			g.P("}")
+			g.P("}")
+			g.P("return f.", method.GoName, "Err")
+		case isStreamingClient && isStreamingServer:
+			wrapComments(g, method.GoName, " records each request message and replies with the next of ",
+				method.GoName, "Responses. Once the client closes its side of the stream, it sends any ",
+				"remaining responses and returns ", method.GoName, "Err.")
+			g.P("func (f *", fake, ") ", method.GoName, serverSignatureParams(g, method, true /* named */, false /* simple */), " {")
+			g.P("if f.", method.GoName, "Responses == nil && f.", method.GoName, "Err == nil {")
+			g.P("return f.", names.UnimplementedServer, ".", method.GoName, "(ctx, stream)")
+			g.P("}")
+			g.P("responses := f.", method.GoName, "Responses")
+			g.P("for {")
+			g.P("req, err := stream.Receive()")
+			g.P("if ", errorsPackage.Ident("Is"), "(err, ", ioPackage.Ident("EOF"), ") {")
+			g.P("break")
+			g.P("} else if err != nil {")
+			g.P("return err")
+			g.P("}")
+			g.P("f.record", method.GoName, "(req)")
+			g.P("if len(responses) > 0 {")
+			g.P("if err := stream.Send(responses[0]); err != nil {")
+			g.P("return err")
+			g.P("}")
+			g.P("responses = responses[1:]")
+			g.P("}")
+			g.P("}")
+			g.P("for _, res := range responses {")
+			g.P("if err := stream.Send(res); err != nil {")
+			g.P("return err")
+			g.P("}")
+			g.P("}")
+			g.P("return f.", method.GoName, "Err")
+		default:
+			wrapComments(g, method.GoName, " records the request message and calls ", method.GoName, "Func.")
+			g.P("func (f *", fake, ") ", method.GoName, serverSignatureParams(g, method, true /* named */, opts.isSimple(method)), " {")
+			if opts.isSimple(method) {
+				g.P("f.record", method.GoName, "(req)")
+			} else {
+				g.P("f.record", method.GoName, "(req.Msg)")
+			}
+			g.P("if f.", method.GoName, "Func == nil {")
+			g.P("return f.", names.UnimplementedServer, ".", method.GoName, "(ctx, req)")
+			g.P("}")
+			g.P("return f.", method.GoName, "Func(ctx, req)")
+		}
+		g.P("}")
+		g.P()
+	}
+	generateFakeRecorders(g, service, fake)
+}
+
+func generateFakeClient(g *protogen.GeneratedFile, service *protogen.Service, names names, connectFile protogen.GoImportPath, opts *options) {
+	fake := names.Client
+	interceptor := unexport(fake) + "Interceptor"
+	hasStreams := false
+	for _, method := range service.Methods {
+		if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() {
+			hasStreams = true
+		}
+	}
+	wrapComments(g, fake, " is a fake implementation of ", connectFile.Ident(names.Client), ". ",
+		"Its zero value is ready to use, and methods without configured behavior return ",
+		"CodeUnimplemented errors. Unary methods are configured with function fields, ",
+		"and streaming methods with scripted responses, which are served in memory ",
+		"without using the network. Each method records the request messages it sends.")
+	g.P("type ", fake, " struct {")
+	generateFakeFields(g, service, opts)
+	if hasStreams {
+		g.P()
+		g.P("clientOnce ", syncPackage.Ident("Once"))
+		g.P("client ", connectFile.Ident(names.Client))
+	}
+	g.P("}")
+	g.P()
+	g.P("var _ ", connectFile.Ident(names.Client), " = (*", fake, ")(nil)")
+	g.P()
+	for _, method := range service.Methods {
+		isStreamingClient := method.Desc.IsStreamingClient()
+		isStreamingServer := method.Desc.IsStreamingServer()
+		if isStreamingClient || isStreamingServer {
+			wrapComments(g, method.GoName, " calls ", method.Desc.FullName(), " in memory.")
+			g.P("func (f *", fake, ") ", clientSignature(g, method, true /* named */, false /* simple */), " {")
+			if isStreamingServer && !isStreamingClient {
+				g.P("return f.inMemoryClient().", method.GoName, "(ctx, req)")
+			} else {
+				g.P("return f.inMemoryClient().", method.GoName, "(ctx)")
+			}
+			g.P("}")
+			g.P()
+			continue
+		}
+		wrapComments(g, method.GoName, " records a copy of the request message and calls ", method.GoName, "Func.")
+		g.P("func (f *", fake, ") ", clientSignature(g, method, true /* named */, opts.isSimple(method)), " {")
+		// Callers may reuse their request messages, so record copies.
+		if opts.isSimple(method) {
+			g.P("f.record", method.GoName, "(", protoPackage.Ident("Clone"), "(req).(*", method.Input.GoIdent, "))")
+		} else {
+			g.P("f.record", method.GoName, "(", protoPackage.Ident("Clone"), "(req.Msg).(*", method.Input.GoIdent, "))")
+		}
+		g.P("if f.", method.GoName, "Func == nil {")
+		g.P(append([]any{"return nil, "}, unimplementedError(method)...)...)
+		g.P("}")
+		g.P("return f.", method.GoName, "Func(ctx, req)")
+		g.P("}")
+		g.P()
+	}
+	generateFakeRecorders(g, service, fake)
+	if !hasStreams {
+		return
+	}
+
+	// In-memory client for streaming methods.
+	g.P("func (f *", fake, ") inMemoryClient() ", connectFile.Ident(names.Client), " {")
+	g.P("f.clientOnce.Do(func() {")
+	g.P("f.client = ", connectFile.Ident(names.ClientConstructor), "(")
+	g.P(httpPackage.Ident("DefaultClient"), ",")
+	g.P(`"`, fakeBaseURL, `",`)
+	g.P(connectPackage.Ident("WithInterceptors"), "(&", interceptor, "{fake: f}),")
+	g.P(")")
+	g.P("})")
+	g.P("return f.client")
+	g.P("}")
+	g.P()
+	g.P("func (f *", fake, ") serve(conn *", connectPackage.Ident("InMemoryHandlerConn"), ") error {")
+	g.P("switch conn.Spec().Procedure {")
+	for _, method := range service.Methods {
+		if !method.Desc.IsStreamingClient() && !method.Desc.IsStreamingServer() {
+			continue
+		}
+		g.P("case ", connectFile.Ident(procedureConstName(method)), ":")
+		g.P("return f.serve", method.GoName, "(conn)")
+	}
+	g.P("}")
+	g.P("return ", connectPackage.Ident("NewError"), "(", connectPackage.Ident("CodeUnimplemented"), ", ",
+		errorsPackage.Ident("New"), `(conn.Spec().Procedure + " is not implemented"))`)
+	g.P("}")
+	g.P()
+	for _, method := range service.Methods {
+		isStreamingClient := method.Desc.IsStreamingClient()
+		isStreamingServer := method.Desc.IsStreamingServer()
+		if !isStreamingClient && !isStreamingServer {
+			continue
+		}
+		g.P("func (f *", fake, ") serve", method.GoName, "(conn *", connectPackage.Ident("InMemoryHandlerConn"), ") error {")
+		if isStreamingClient && !isStreamingServer {
+			g.P("if f.", method.GoName, "Response == nil && f.", method.GoName, "Err == nil {")
+		} else {
+			g.P("if f.", method.GoName, "Responses == nil && f.", method.GoName, "Err == nil {")
+		}
+		g.P(append([]any{"return "}, unimplementedError(method)...)...)
+		g.P("}")
+		switch {
+		case isStreamingClient && !isStreamingServer:
+			g.P("for {")
+			g.P("req := &", method.Input.GoIdent, "{}")
+			g.P("if err := conn.Receive(req); ", errorsPackage.Ident("Is"), "(err, ", ioPackage.Ident("EOF"), ") {")
+			g.P("break")
+			g.P("} else if err != nil {")
+			g.P("return err")
+			g.P("}")
+			g.P("f.record", method.GoName, "(req)")
+			g.P("}")
+			g.P("if f.", method.GoName, "Err != nil {")
+			g.P("return f.", method.GoName, "Err")
+			g.P("}")
+			g.P("return conn.Send(f.", method.GoName, "Response)")
+		case !isStreamingClient && isStreamingServer:
+			g.P("req := &", method.Input.GoIdent, "{}")
+			g.P("if err := conn.Receive(req); err != nil {")
+			g.P("return err")
+			g.P("}")
+			g.P("f.record", method.GoName, "(req)")
+			g.P("for _, res := range f.", method.GoName, "Responses {")
+			g.P("if err := conn.Send(res); err != nil {")
+			g.P("return err")
+			g.P("}")
+			g.P("}")
+			g.P("return f.", method.GoName, "Err")
+		default:
+			g.P("responses := f.", method.GoName, "Responses")
+			g.P("for {")
+			g.P("req := &", method.Input.GoIdent, "{}")
+			g.P("if err := conn.Receive(req); ", errorsPackage.Ident("Is"), "(err, ", ioPackage.Ident("EOF"), ") {")
+			g.P("break")
+			g.P("} else if err != nil {")
+			g.P("return err")
+			g.P("}")
+			g.P("f.record", method.GoName, "(req)")
+			g.P("if len(responses) > 0 {")
+			g.P("if err := conn.Send(responses[0]); err != nil {")
+			g.P("return err")
+			g.P("}")
+			g.P("responses = responses[1:]")
+			g.P("}")
+			g.P("}")
+			g.P("for _, res := range responses {")
+			g.P("if err := conn.Send(res); err != nil {")
+			g.P("return err")
+			g.P("}")
+			g.P("}")
+			g.P("return f.", method.GoName, "Err")
+		}
+		g.P("}")
+		g.P()
+	}
+
+	// Interceptor that serves streaming calls from the fake.
+	g.P("type ", interceptor, " struct {")
+	g.P("fake *", fake)
+	g.P("}")
+	g.P()
+	g.P("func (i *", interceptor, ") WrapUnary(next ", connectPackage.Ident("UnaryFunc"), ") ",
+		connectPackage.Ident("UnaryFunc"), " {")
+	g.P("return next")
+	g.P("}")
+	g.P()
+	g.P("func (i *", interceptor, ") WrapStreamingClient(", connectPackage.Ident("StreamingClientFunc"), ") ",
+		connectPackage.Ident("StreamingClientFunc"), " {")
+	g.P("return func(ctx ", contextPackage.Ident("Context"), ", spec ", connectPackage.Ident("Spec"), ") ",
+		connectPackage.Ident("StreamingClientConn"), " {")
+	g.P("client, handler := ", connectPackage.Ident("NewInMemoryStream"), "(ctx, spec)")
+	g.P("go func() {")
+	g.P("_ = handler.Close(i.fake.serve(handler))")
+	g.P("}()")
+	g.P("return client")
+	g.P("}")
+	g.P("}")
+	g.P()
+	g.P("func (i *", interceptor, ") WrapStreamingHandler(next ", connectPackage.Ident("StreamingHandlerFunc"), ") ",
+		connectPackage.Ident("StreamingHandlerFunc"), " {")
+	g.P("return next")
+	g.P("}")
+	g.P()
+}
+
+// generateFakeFields writes the configuration fields and request records
+// shared by fake clients and handlers.
+func generateFakeFields(g *protogen.GeneratedFile, service *protogen.Service, opts *options) {
+	for _, method := range service.Methods {
+		isStreamingClient := method.Desc.IsStreamingClient()
+		isStreamingServer := method.Desc.IsStreamingServer()
+		switch {
+		case isStreamingClient && !isStreamingServer:
+			wrapComments(g, method.GoName, "Response and ", method.GoName, "Err script calls to ",
+				method.GoName, ". If both are nil, ", method.GoName, " returns CodeUnimplemented.")
+			g.P(method.GoName, "Response *", method.Output.GoIdent)
+			g.P(method.GoName, "Err error")
+		case isStreamingServer:
+			wrapComments(g, method.GoName, "Responses and ", method.GoName, "Err script calls to ",
+				method.GoName, ". If both are nil, ", method.GoName, " returns CodeUnimplemented.")
+			g.P(method.GoName, "Responses []*", method.Output.GoIdent)
+			g.P(method.GoName, "Err error")
+		default:
+			wrapComments(g, method.GoName, "Func handles calls to ", method.GoName,
+				". If it's nil, ", method.GoName, " returns CodeUnimplemented.")
+			g.P(method.GoName, "Func func", serverSignatureParams(g, method, false /* named */, opts.isSimple(method)))
+		}
+	}
+	g.P()
+	g.P("mu ", syncPackage.Ident("Mutex"))
+	for _, method := range service.Methods {
+		g.P(unexport(method.GoName), "Requests []*", method.Input.GoIdent)
+	}
+}
+
+// generateFakeRecorders writes the methods that record and return each
+// method's request messages.
+func generateFakeRecorders(g *protogen.GeneratedFile, service *protogen.Service, fake string) {
+	for _, method := range service.Methods {
+		field := unexport(method.GoName) + "Requests"
+		wrapComments(g, method.GoName, "Requests returns the request messages for all calls to ",
+			method.GoName, ", in the order they were received.")
+		g.P("func (f *", fake, ") ", method.GoName, "Requests() []*", method.Input.GoIdent, " {")
+		g.P("f.mu.Lock()")
+		g.P("defer f.mu.Unlock()")
+		g.P("return append([]*", method.Input.GoIdent, "(nil), f.", field, "...)")
+		g.P("}")
+		g.P()
+		g.P("func (f *", fake, ") record", method.GoName, "(req *", method.Input.GoIdent, ") {")
+		g.P("f.mu.Lock()")
+		g.P("defer f.mu.Unlock()")
+		g.P("f.", field, " = append(f.", field, ", req)")
+		g.P("}")
+		g.P()
+	}
+}
+
+func unimplementedError(method *protogen.Method) []any {
+	return []any{
+		connectPackage.Ident("NewError"), "(", connectPackage.Ident("CodeUnimplemented"), ", ",
+		errorsPackage.Ident("New"), `("`, method.Desc.FullName(), ` is not implemented"))`,
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connect"
+	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connectfake"
+)
+
+func TestFakeHandler(t *testing.T) {
+	t.Parallel()
+	fake := &pingv1connectfake.PingServiceHandler{
+		PingFunc: func(_ context.Context, request *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+			return connect.NewResponse(&pingv1.PingResponse{Number: request.Msg.Number}), nil
+		},
+		SumResponse:      &pingv1.SumResponse{Sum: 3},
+		CountUpResponses: []*pingv1.CountUpResponse{{Number: 1}, {Number: 2}},
+		CountUpErr:       connect.NewError(connect.CodeDataLoss, errors.New("oops")),
+	}
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(fake))
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	client := pingv1connect.NewPingServiceClient(server.Client(), server.URL)
+	testFake(t, client, func() ([]*pingv1.PingRequest, []*pingv1.SumRequest, []*pingv1.CountUpRequest) {
+		return fake.PingRequests(), fake.SumRequests(), fake.CountUpRequests()
+	})
+}
+
+func TestFakeClient(t *testing.T) {
+	t.Parallel()
+	fake := &pingv1connectfake.PingServiceClient{
+		PingFunc: func(_ context.Context, request *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+			return connect.NewResponse(&pingv1.PingResponse{Number: request.Msg.Number}), nil
+		},
+		SumResponse:      &pingv1.SumResponse{Sum: 3},
+		CountUpResponses: []*pingv1.CountUpResponse{{Number: 1}, {Number: 2}},
+		CountUpErr:       connect.NewError(connect.CodeDataLoss, errors.New("oops")),
+	}
+	testFake(t, fake, func() ([]*pingv1.PingRequest, []*pingv1.SumRequest, []*pingv1.CountUpRequest) {
+		return fake.PingRequests(), fake.SumRequests(), fake.CountUpRequests()
+	})
+}
+
+func TestFakeClientRecordsCopies(t *testing.T) {
+	t.Parallel()
+	fake := &pingv1connectfake.PingServiceClient{
+		PingFunc: func(context.Context, *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+			return connect.NewResponse(&pingv1.PingResponse{}), nil
+		},
+	}
+	request := connect.NewRequest(&pingv1.PingRequest{Number: 1})
+	_, err := fake.Ping(context.Background(), request)
+	assert.Nil(t, err)
+	// Callers may reuse their requests.
+	request.Msg.Number = 2
+	_, err = fake.Ping(context.Background(), request)
+	assert.Nil(t, err)
+	pings := fake.PingRequests()
+	assert.Equal(t, len(pings), 2)
+	assert.Equal(t, pings[0].Number, 1)
+	assert.Equal(t, pings[1].Number, 2)
+}
+
+func testFake(
+	t *testing.T,
+	client pingv1connect.PingServiceClient,
+	requests func() ([]*pingv1.PingRequest, []*pingv1.SumRequest, []*pingv1.CountUpRequest),
+) {
+	t.Helper()
+	ctx := context.Background()
+
+	response, err := client.Ping(ctx, connect.NewRequest(&pingv1.PingRequest{Number: 42}))
+	assert.Nil(t, err)
+	assert.Equal(t, response.Msg.Number, 42)
+
+	_, err = client.Fail(ctx, connect.NewRequest(&pingv1.FailRequest{}))
+	assert.Equal(t, connect.CodeOf(err), connect.CodeUnimplemented)
+
+	sum := client.Sum(ctx)
+	assert.Nil(t, sum.Send(&pingv1.SumRequest{Number: 1}))
+	assert.Nil(t, sum.Send(&pingv1.SumRequest{Number: 2}))
+	sumResponse, err := sum.CloseAndReceive()
+	assert.Nil(t, err)
+	assert.Equal(t, sumResponse.Msg.Sum, 3)
+
+	countUp, err := client.CountUp(ctx, connect.NewRequest(&pingv1.CountUpRequest{Number: 2}))
+	assert.Nil(t, err)
+	var numbers []int64
+	for countUp.Receive() {
+		numbers = append(numbers, countUp.Msg().Number)
+	}
+	assert.Equal(t, numbers, []int64{1, 2})
+	assert.Equal(t, connect.CodeOf(countUp.Err()), connect.CodeDataLoss)
+	assert.Nil(t, countUp.Close())
+
+	// CumSum isn't configured, so the handler may reject the stream before
+	// the client finishes sending.
+	cumSum := client.CumSum(ctx)
+	_ = cumSum.Send(&pingv1.CumSumRequest{Number: 1})
+	_ = cumSum.CloseRequest()
+	_, err = cumSum.Receive()
+	assert.Equal(t, connect.CodeOf(err), connect.CodeUnimplemented)
+	assert.False(t, errors.Is(err, io.EOF))
+	assert.Nil(t, cumSum.CloseResponse())
+
+	pings, sums, countUps := requests()
+	assert.Equal(t, len(pings), 1)
+	assert.Equal(t, pings[0].Number, 42)
+	assert.Equal(t, len(sums), 2)
+	assert.Equal(t, sums[1].Number, 2)
+	assert.Equal(t, len(countUps), 1)
+	assert.Equal(t, countUps[0].Number, 2)
+}
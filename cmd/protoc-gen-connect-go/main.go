@@ -36,10 +36,37 @@
 //
 //	 gen/path/to/file.pb.go
 //	 gen/path/to/connectfoov1/file.connect.go
+//
+// The plugin accepts a few parameters to fit the generated code to your
+// repository's layout. With protoc, pass them with --connect-go_opt; with buf,
+// use the opt key in buf.gen.yaml.
+//
+//   package_suffix=<suffix>
+//     Append <suffix> to the Go package name and output directory instead of
+//     "connect".
+//   same_package
+//     Generate code into the same Go package and directory as the base Go
+//     types, with no sub-package.
+//   filename_extension=<extension>
+//     Use <extension> for generated file names instead of ".connect.go".
+//   generate=<client|server|both>
+//     Generate only clients, only handlers, or both (the default).
+//   simple
+//     Generate unary methods with bare messages, like
+//     Method(context.Context, *Request) (*Response, error), instead of
+//     connect.Request and connect.Response wrappers. Headers and trailers are
+//     available through connect.NewClientContext and
+//     connect.CallInfoFromHandlerContext.
+//   fakes
+//     Also generate a package of fake clients and handlers for use in tests.
+//     If foov1connect is the package of generated Connect code, the fakes are
+//     written to the sibling package foov1connectfake.
 package main
 
 import (
 	"bytes"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"path"
@@ -60,8 +87,12 @@ const (
 	stringsPackage = protogen.GoImportPath("strings")
 	connectPackage = protogen.GoImportPath("github.com/bufbuild/connect-go")
 
-	generatedFilenameExtension = ".connect.go"
-	generatedPackageSuffix     = "connect"
+	defaultFilenameExtension = ".connect.go"
+	defaultPackageSuffix     = "connect"
+
+	generateClient = "client"
+	generateServer = "server"
+	generateBoth   = "both"
 
 	usage = "See https://connect.build/docs/go/getting-started to learn how to use this plugin.\n\nFlags:\n  -h, --help\tPrint this help and exit.\n      --version\tPrint the version and exit."
 
@@ -81,42 +112,131 @@ func main() {
 		fmt.Fprintln(os.Stderr, usage)
 		os.Exit(1)
 	}
-	protogen.Options{}.Run(
-		func(plugin *protogen.Plugin) error {
-			plugin.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
-			for _, file := range plugin.Files {
-				if file.Generate {
-					generate(plugin, file)
-				}
-			}
-			return nil
-		},
-	)
+	var opts options
+	protogen.Options{ParamFunc: opts.paramFunc()}.Run(opts.run)
+}
+
+// options are the plugin parameters.
+type options struct {
+	PackageSuffix     string
+	SamePackage       bool
+	FilenameExtension string
+	Generate          string
+	Simple            bool
+	Fakes             bool
+}
+
+// paramFunc returns a function that sets the options from plugin parameters.
+func (o *options) paramFunc() func(name, value string) error {
+	flags := o.flagSet()
+	return func(name, value string) error {
+		// Boolean parameters may be passed without a value, like
+		// "same_package".
+		if value == "" && isBoolFlag(flags, name) {
+			value = "true"
+		}
+		return flags.Set(name, value)
+	}
+}
+
+// run generates Connect code for every file the plugin was asked to generate.
+func (o *options) run(plugin *protogen.Plugin) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+	plugin.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+	for _, file := range plugin.Files {
+		if file.Generate {
+			generate(plugin, file, o)
+		}
+	}
+	return nil
+}
+
+func (o *options) flagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet("protoc-gen-connect-go", flag.ContinueOnError)
+	flags.StringVar(&o.PackageSuffix, "package_suffix", defaultPackageSuffix, "")
+	flags.BoolVar(&o.SamePackage, "same_package", false, "")
+	flags.StringVar(&o.FilenameExtension, "filename_extension", defaultFilenameExtension, "")
+	flags.StringVar(&o.Generate, "generate", generateBoth, "")
+	flags.BoolVar(&o.Simple, "simple", false, "")
+	flags.BoolVar(&o.Fakes, "fakes", false, "")
+	return flags
+}
+
+func (o *options) validate() error {
+	switch o.Generate {
+	case generateClient, generateServer, generateBoth:
+	default:
+		return fmt.Errorf("invalid generate parameter %q: must be %q, %q, or %q",
+			o.Generate, generateClient, generateServer, generateBoth)
+	}
+	if o.FilenameExtension == "" {
+		return errors.New("filename_extension must not be empty")
+	}
+	if !o.SamePackage && o.PackageSuffix == "" {
+		return errors.New("package_suffix must not be empty; use same_package to generate into the base package")
+	}
+	if o.SamePackage && o.FilenameExtension == ".pb.go" {
+		return errors.New("filename_extension .pb.go conflicts with protoc-gen-go output in the same package")
+	}
+	return nil
+}
+
+func (o *options) generateClient() bool {
+	return o.Generate == generateClient || o.Generate == generateBoth
+}
+
+func (o *options) generateServer() bool {
+	return o.Generate == generateServer || o.Generate == generateBoth
+}
+
+// isSimple reports whether the method should be generated with bare messages.
+func (o *options) isSimple(method *protogen.Method) bool {
+	return o.Simple && !method.Desc.IsStreamingClient() && !method.Desc.IsStreamingServer()
+}
+
+func isBoolFlag(flags *flag.FlagSet, name string) bool {
+	f := flags.Lookup(name)
+	if f == nil {
+		return false
+	}
+	boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && boolFlag.IsBoolFlag()
 }
 
-func generate(plugin *protogen.Plugin, file *protogen.File) {
+func generate(plugin *protogen.Plugin, file *protogen.File, opts *options) {
 	if len(file.Services) == 0 {
 		return
 	}
-	file.GoPackageName += generatedPackageSuffix
-
-	generatedFilenamePrefixToSlash := filepath.ToSlash(file.GeneratedFilenamePrefix)
-	file.GeneratedFilenamePrefix = path.Join(
-		path.Dir(generatedFilenamePrefixToSlash),
-		string(file.GoPackageName),
-		path.Base(generatedFilenamePrefixToSlash),
-	)
-	generatedFile := plugin.NewGeneratedFile(
-		file.GeneratedFilenamePrefix+generatedFilenameExtension,
-		protogen.GoImportPath(path.Join(
+	goImportPath := file.GoImportPath
+	if !opts.SamePackage {
+		file.GoPackageName += protogen.GoPackageName(opts.PackageSuffix)
+		generatedFilenamePrefixToSlash := filepath.ToSlash(file.GeneratedFilenamePrefix)
+		file.GeneratedFilenamePrefix = path.Join(
+			path.Dir(generatedFilenamePrefixToSlash),
+			string(file.GoPackageName),
+			path.Base(generatedFilenamePrefixToSlash),
+		)
+		goImportPath = protogen.GoImportPath(path.Join(
 			string(file.GoImportPath),
 			string(file.GoPackageName),
-		)),
+		))
+	}
+	generatedFile := plugin.NewGeneratedFile(
+		file.GeneratedFilenamePrefix+opts.FilenameExtension,
+		goImportPath,
 	)
 	generatePreamble(generatedFile, file)
 	generateServiceNameConstants(generatedFile, file.Services)
+	generateProcedureConstants(generatedFile, file.Services)
+	generateDescriptorVariables(generatedFile, file)
+	generateMethodRegistration(generatedFile, file)
 	for _, service := range file.Services {
-		generateService(generatedFile, file, service)
+		generateService(generatedFile, file, service, opts)
+	}
+	if opts.Fakes {
+		generateFakes(plugin, file, goImportPath, opts)
 	}
 }
 
@@ -136,7 +256,7 @@ func generatePreamble(g *protogen.GeneratedFile, file *protogen.File) {
 		"is not defined, this code was generated with a version of connect newer than the one ",
 		"compiled into your binary. You can fix the problem by either regenerating this code ",
 		"with an older version of connect or updating the connect version compiled into your binary.")
-	g.P("const _ = ", connectPackage.Ident("IsAtLeastVersion0_1_0"))
+	g.P("const _ = ", connectPackage.Ident("IsAtLeastVersion0_3_0"))
 	g.P()
 }
 
@@ -152,16 +272,74 @@ func generateServiceNameConstants(g *protogen.GeneratedFile, services []*protoge
 	g.P()
 }
 
-func generateService(g *protogen.GeneratedFile, file *protogen.File, service *protogen.Service) {
+func generateProcedureConstants(g *protogen.GeneratedFile, services []*protogen.Service) {
+	wrapComments(g, "These constants are the fully-qualified names of the RPCs defined in this package. ",
+		"They're exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.")
+	g.P("//")
+	wrapComments(g, "Note that these are different from the fully-qualified method names used by ",
+		"google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to ",
+		"reflection-formatted method names, remove the leading slash and convert the ",
+		"remaining slash to a period.")
+	g.P("const (")
+	for _, service := range services {
+		for _, method := range service.Methods {
+			wrapComments(g, procedureConstName(method), " is the fully-qualified name of the ",
+				service.Desc.Name(), "'s ", method.Desc.Name(), " RPC.")
+			g.P(procedureConstName(method), ` = "`, procedureName(method), `"`)
+		}
+	}
+	g.P(")")
+	g.P()
+}
+
+func generateDescriptorVariables(g *protogen.GeneratedFile, file *protogen.File) {
+	wrapComments(g, "These variables are the protoreflect.Descriptor objects for the RPCs defined in this package.")
+	g.P("var (")
+	for _, service := range file.Services {
+		serviceDescriptor := serviceDescriptorName(service)
+		g.P(serviceDescriptor, " = ", file.GoDescriptorIdent, `.Services().ByName("`, service.Desc.Name(), `")`)
+		for _, method := range service.Methods {
+			g.P(methodDescriptorName(method), " = ", serviceDescriptor, `.Methods().ByName("`, method.Desc.Name(), `")`)
+		}
+	}
+	g.P(")")
+	g.P()
+}
+
+func generateMethodRegistration(g *protogen.GeneratedFile, file *protogen.File) {
+	wrapComments(g, "Register the RPCs defined in this file, so interceptors can look up their ",
+		"schemas with connect.LookupMethod.")
+	g.P("func init() {")
+	g.P(connectPackage.Ident("RegisterMethods"), "(")
+	for _, service := range file.Services {
+		for _, method := range service.Methods {
+			g.P("&", connectPackage.Ident("MethodInfo"), "{")
+			g.P("Procedure: ", procedureConstName(method), ",")
+			g.P("StreamType: ", connectPackage.Ident(streamTypeName(method)), ",")
+			g.P("IdempotencyLevel: ", connectPackage.Ident(idempotencyLevelName(method)), ",")
+			g.P("Descriptor: ", methodDescriptorName(method), ",")
+			g.P("},")
+		}
+	}
+	g.P(")")
+	g.P("}")
+	g.P()
+}
+
+func generateService(g *protogen.GeneratedFile, file *protogen.File, service *protogen.Service, opts *options) {
 	names := newNames(service)
-	generateClientInterface(g, service, names)
-	generateClientImplementation(g, service, names)
-	generateServerInterface(g, service, names)
-	generateServerConstructor(g, service, names)
-	generateUnimplementedServerImplementation(g, service, names)
+	if opts.generateClient() {
+		generateClientInterface(g, service, names, opts)
+		generateClientImplementation(g, service, names, opts)
+	}
+	if opts.generateServer() {
+		generateServerInterface(g, service, names, opts)
+		generateServerConstructor(g, service, names, opts)
+		generateUnimplementedServerImplementation(g, service, names, opts)
+	}
 }
 
-func generateClientInterface(g *protogen.GeneratedFile, service *protogen.Service, names names) {
+func generateClientInterface(g *protogen.GeneratedFile, service *protogen.Service, names names, opts *options) {
 	wrapComments(g, names.Client, " is a client for the ", service.Desc.FullName(), " service.")
 	if isDeprecatedService(service) {
 		g.P("//")
@@ -176,13 +354,13 @@ func generateClientInterface(g *protogen.GeneratedFile, service *protogen.Servic
 			method.Comments.Leading,
 			isDeprecatedMethod(method),
 		)
-		g.P(clientSignature(g, method, false /* named */))
+		g.P(clientSignature(g, method, false /* named */, opts.isSimple(method)))
 	}
 	g.P("}")
 	g.P()
 }
 
-func generateClientImplementation(g *protogen.GeneratedFile, service *protogen.Service, names names) {
+func generateClientImplementation(g *protogen.GeneratedFile, service *protogen.Service, names names, opts *options) {
 	clientOption := connectPackage.Ident("ClientOption")
 
 	// Client constructor.
@@ -194,6 +372,9 @@ func generateClientImplementation(g *protogen.GeneratedFile, service *protogen.S
 	g.P("//")
 	wrapComments(g, "The URL supplied here should be the base URL for the Connect or gRPC server ",
 		"(for example, http://api.acme.com or https://acme.com/grpc).")
+	g.P("//")
+	wrapComments(g, "The options apply to every method's client. To configure a single method, ",
+		"use connect.WithProcedureOptions.")
 	if isDeprecatedService(service) {
 		g.P("//")
 		deprecated(g)
@@ -209,8 +390,9 @@ func generateClientImplementation(g *protogen.GeneratedFile, service *protogen.S
 			"(",
 		)
 		g.P("httpClient,")
-		g.P(`baseURL + "`, procedureName(method), `",`)
-		g.P("opts...,")
+		g.P("baseURL + ", procedureConstName(method), ",")
+		g.P(connectPackage.Ident("WithSchema"), "(", methodDescriptorName(method), "),")
+		g.P(connectPackage.Ident("WithClientOptions"), "(opts...),")
 		g.P("),")
 	}
 	g.P("}")
@@ -227,11 +409,11 @@ func generateClientImplementation(g *protogen.GeneratedFile, service *protogen.S
 	g.P("}")
 	g.P()
 	for _, method := range service.Methods {
-		generateClientMethod(g, service, method, names)
+		generateClientMethod(g, service, method, names, opts)
 	}
 }
 
-func generateClientMethod(g *protogen.GeneratedFile, service *protogen.Service, method *protogen.Method, names names) {
+func generateClientMethod(g *protogen.GeneratedFile, service *protogen.Service, method *protogen.Method, names names, opts *options) {
 	receiver := names.ClientImpl
 	isStreamingClient := method.Desc.IsStreamingClient()
 	isStreamingServer := method.Desc.IsStreamingServer()
@@ -240,9 +422,11 @@ func generateClientMethod(g *protogen.GeneratedFile, service *protogen.Service,
 		g.P("//")
 		deprecated(g)
 	}
-	g.P("func (c *", receiver, ") ", clientSignature(g, method, true /* named */), " {")
+	g.P("func (c *", receiver, ") ", clientSignature(g, method, true /* named */, opts.isSimple(method)), " {")
 
 	switch {
+	case opts.isSimple(method):
+		g.P("return c.", unexport(method.GoName), ".CallUnarySimple(ctx, req)")
 	case isStreamingClient && !isStreamingServer:
 		g.P("return c.", unexport(method.GoName), ".CallClientStream(ctx)")
 	case !isStreamingClient && isStreamingServer:
@@ -256,7 +440,7 @@ func generateClientMethod(g *protogen.GeneratedFile, service *protogen.Service,
 	g.P()
 }
 
-func clientSignature(g *protogen.GeneratedFile, method *protogen.Method, named bool) string {
+func clientSignature(g *protogen.GeneratedFile, method *protogen.Method, named, simple bool) string {
 	reqName := "req"
 	ctxName := "ctx"
 	if !named {
@@ -283,10 +467,10 @@ func clientSignature(g *protogen.GeneratedFile, method *protogen.Method, named b
 			", error)"
 	}
 	// unary; symmetric so we can re-use server templating
-	return method.GoName + serverSignatureParams(g, method, named)
+	return method.GoName + serverSignatureParams(g, method, named, simple)
 }
 
-func generateServerInterface(g *protogen.GeneratedFile, service *protogen.Service, names names) {
+func generateServerInterface(g *protogen.GeneratedFile, service *protogen.Service, names names, opts *options) {
 	wrapComments(g, names.Server, " is an implementation of the ", service.Desc.FullName(), " service.")
 	if isDeprecatedService(service) {
 		g.P("//")
@@ -301,18 +485,21 @@ func generateServerInterface(g *protogen.GeneratedFile, service *protogen.Servic
 			isDeprecatedMethod(method),
 		)
 		g.Annotate(names.Server+"."+method.GoName, method.Location)
-		g.P(serverSignature(g, method))
+		g.P(serverSignature(g, method, opts.isSimple(method)))
 	}
 	g.P("}")
 	g.P()
 }
 
-func generateServerConstructor(g *protogen.GeneratedFile, service *protogen.Service, names names) {
+func generateServerConstructor(g *protogen.GeneratedFile, service *protogen.Service, names names, opts *options) {
 	wrapComments(g, names.ServerConstructor, " builds an HTTP handler from the service implementation.",
 		" It returns the path on which to mount the handler and the handler itself.")
 	g.P("//")
 	wrapComments(g, "By default, handlers support the Connect, gRPC, and gRPC-Web protocols with ",
 		"the binary Protobuf and JSON codecs. They also support gzip compression.")
+	g.P("//")
+	wrapComments(g, "The options apply to every method's handler. To configure a single method, ",
+		"use connect.WithProcedureOptions.")
 	if isDeprecatedService(service) {
 		g.P("//")
 		deprecated(g)
@@ -320,23 +507,28 @@ func generateServerConstructor(g *protogen.GeneratedFile, service *protogen.Serv
 	handlerOption := connectPackage.Ident("HandlerOption")
 	g.P("func ", names.ServerConstructor, "(svc ", names.Server, ", opts ...", handlerOption,
 		") (string, ", httpPackage.Ident("Handler"), ") {")
-	g.P("mux := ", httpPackage.Ident("NewServeMux"), "()")
+	g.P("mux := ", connectPackage.Ident("NewRouter"), "(opts...)")
 	for _, method := range service.Methods {
 		isStreamingServer := method.Desc.IsStreamingServer()
 		isStreamingClient := method.Desc.IsStreamingClient()
 		switch {
 		case isStreamingClient && !isStreamingServer:
-			g.P(`mux.Handle("`, procedureName(method), `", `, connectPackage.Ident("NewClientStreamHandler"), "(")
+			g.P("mux.Handle(", procedureConstName(method), ", ", connectPackage.Ident("NewClientStreamHandler"), "(")
 		case !isStreamingClient && isStreamingServer:
-			g.P(`mux.Handle("`, procedureName(method), `", `, connectPackage.Ident("NewServerStreamHandler"), "(")
+			g.P("mux.Handle(", procedureConstName(method), ", ", connectPackage.Ident("NewServerStreamHandler"), "(")
 		case isStreamingClient && isStreamingServer:
-			g.P(`mux.Handle("`, procedureName(method), `", `, connectPackage.Ident("NewBidiStreamHandler"), "(")
+			g.P("mux.Handle(", procedureConstName(method), ", ", connectPackage.Ident("NewBidiStreamHandler"), "(")
 		default:
-			g.P(`mux.Handle("`, procedureName(method), `", `, connectPackage.Ident("NewUnaryHandler"), "(")
+			g.P("mux.Handle(", procedureConstName(method), ", ", connectPackage.Ident("NewUnaryHandler"), "(")
 		}
-		g.P(`"`, procedureName(method), `",`)
-		g.P("svc.", method.GoName, ",")
-		g.P("opts...,")
+		g.P(procedureConstName(method), ",")
+		if opts.isSimple(method) {
+			g.P(connectPackage.Ident("SimpleUnaryHandler"), "(svc.", method.GoName, "),")
+		} else {
+			g.P("svc.", method.GoName, ",")
+		}
+		g.P(connectPackage.Ident("WithSchema"), "(", methodDescriptorName(method), "),")
+		g.P(connectPackage.Ident("WithHandlerOptions"), "(opts...),")
 		g.P("))")
 	}
 	g.P(`return "/`, reflectionName(service), `/", mux`)
@@ -344,12 +536,12 @@ func generateServerConstructor(g *protogen.GeneratedFile, service *protogen.Serv
 	g.P()
 }
 
-func generateUnimplementedServerImplementation(g *protogen.GeneratedFile, service *protogen.Service, names names) {
+func generateUnimplementedServerImplementation(g *protogen.GeneratedFile, service *protogen.Service, names names, opts *options) {
 	wrapComments(g, names.UnimplementedServer, " returns CodeUnimplemented from all methods.")
 	g.P("type ", names.UnimplementedServer, " struct {}")
 	g.P()
 	for _, method := range service.Methods {
-		g.P("func (", names.UnimplementedServer, ") ", serverSignature(g, method), "{")
+		g.P("func (", names.UnimplementedServer, ") ", serverSignature(g, method, opts.isSimple(method)), "{")
 		if method.Desc.IsStreamingServer() {
 			g.P("return ", connectPackage.Ident("NewError"), "(",
 				connectPackage.Ident("CodeUnimplemented"), ", ", errorsPackage.Ident("New"),
@@ -365,11 +557,11 @@ func generateUnimplementedServerImplementation(g *protogen.GeneratedFile, servic
 	g.P()
 }
 
-func serverSignature(g *protogen.GeneratedFile, method *protogen.Method) string {
-	return method.GoName + serverSignatureParams(g, method, false /* named */)
+func serverSignature(g *protogen.GeneratedFile, method *protogen.Method, simple bool) string {
+	return method.GoName + serverSignatureParams(g, method, false /* named */, simple)
 }
 
-func serverSignatureParams(g *protogen.GeneratedFile, method *protogen.Method, named bool) string {
+func serverSignatureParams(g *protogen.GeneratedFile, method *protogen.Method, named, simple bool) string {
 	ctxName := "ctx "
 	reqName := "req "
 	streamName := "stream "
@@ -399,6 +591,12 @@ func serverSignatureParams(g *protogen.GeneratedFile, method *protogen.Method, n
 			"[" + g.QualifiedGoIdent(method.Output.GoIdent) + "]" +
 			") error"
 	}
+	if simple {
+		// unary with bare messages
+		return "(" + ctxName + g.QualifiedGoIdent(contextPackage.Ident("Context")) +
+			", " + reqName + "*" + g.QualifiedGoIdent(method.Input.GoIdent) + ") " +
+			"(*" + g.QualifiedGoIdent(method.Output.GoIdent) + ", error)"
+	}
 	// unary
 	return "(" + ctxName + g.QualifiedGoIdent(contextPackage.Ident("Context")) +
 		", " + reqName + "*" + g.QualifiedGoIdent(connectPackage.Ident("Request")) + "[" +
@@ -416,6 +614,45 @@ func procedureName(method *protogen.Method) string {
 	)
 }
 
+func procedureConstName(method *protogen.Method) string {
+	return fmt.Sprintf("%s%sProcedure", method.Parent.GoName, method.GoName)
+}
+
+func serviceDescriptorName(service *protogen.Service) string {
+	return unexport(service.GoName) + "ServiceDescriptor"
+}
+
+func methodDescriptorName(method *protogen.Method) string {
+	return unexport(method.Parent.GoName) + method.GoName + "MethodDescriptor"
+}
+
+func streamTypeName(method *protogen.Method) string {
+	isStreamingClient := method.Desc.IsStreamingClient()
+	isStreamingServer := method.Desc.IsStreamingServer()
+	switch {
+	case isStreamingClient && isStreamingServer:
+		return "StreamTypeBidi"
+	case isStreamingClient:
+		return "StreamTypeClient"
+	case isStreamingServer:
+		return "StreamTypeServer"
+	default:
+		return "StreamTypeUnary"
+	}
+}
+
+func idempotencyLevelName(method *protogen.Method) string {
+	methodOptions, _ := method.Desc.Options().(*descriptorpb.MethodOptions)
+	switch methodOptions.GetIdempotencyLevel() {
+	case descriptorpb.MethodOptions_NO_SIDE_EFFECTS:
+		return "IdempotencyNoSideEffects"
+	case descriptorpb.MethodOptions_IDEMPOTENT:
+		return "IdempotencyIdempotent"
+	default:
+		return "IdempotencyUnknown"
+	}
+}
+
 func reflectionName(service *protogen.Service) string {
 	return fmt.Sprintf("%s.%s", service.Desc.ParentFile().Package(), service.Desc.Name())
 }
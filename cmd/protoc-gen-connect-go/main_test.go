@@ -0,0 +1,225 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// baseParams map ping.proto to a Go package, like buf's managed mode, and
+// generate files relative to the proto source.
+const baseParams = "paths=source_relative,Mconnect/ping/v1/ping.proto=example.com/gen/connect/ping/v1;pingv1"
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name    string
+		params  string
+		files   []string
+		pkg     string
+		client  bool
+		server  bool
+		imports []string
+	}{
+		{
+			name:    "default",
+			files:   []string{"connect/ping/v1/pingv1connect/ping.connect.go"},
+			pkg:     "pingv1connect",
+			client:  true,
+			server:  true,
+			imports: []string{"example.com/gen/connect/ping/v1"},
+		},
+		{
+			name:   "package_suffix",
+			params: "package_suffix=rpc",
+			files:  []string{"connect/ping/v1/pingv1rpc/ping.connect.go"},
+			pkg:    "pingv1rpc",
+			client: true,
+			server: true,
+		},
+		{
+			name:   "same_package",
+			params: "same_package",
+			files:  []string{"connect/ping/v1/ping.connect.go"},
+			pkg:    "pingv1",
+			client: true,
+			server: true,
+		},
+		{
+			name:   "filename_extension",
+			params: "filename_extension=.rpc.go",
+			files:  []string{"connect/ping/v1/pingv1connect/ping.rpc.go"},
+			pkg:    "pingv1connect",
+			client: true,
+			server: true,
+		},
+		{
+			name:   "generate_client",
+			params: "generate=client",
+			files:  []string{"connect/ping/v1/pingv1connect/ping.connect.go"},
+			pkg:    "pingv1connect",
+			client: true,
+		},
+		{
+			name:   "generate_server",
+			params: "generate=server",
+			files:  []string{"connect/ping/v1/pingv1connect/ping.connect.go"},
+			pkg:    "pingv1connect",
+			server: true,
+		},
+		{
+			name:   "fakes",
+			params: "fakes",
+			files: []string{
+				"connect/ping/v1/pingv1connect/ping.connect.go",
+				"connect/ping/v1/pingv1connectfake/ping.fake.go",
+			},
+			pkg:    "pingv1connect",
+			client: true,
+			server: true,
+		},
+		{
+			name:   "fakes_same_package",
+			params: "same_package,fakes",
+			files: []string{
+				"connect/ping/v1/ping.connect.go",
+				"connect/ping/v1/pingv1fake/ping.fake.go",
+			},
+			pkg:    "pingv1",
+			client: true,
+			server: true,
+		},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			files, err := generateFiles(t, testCase.params)
+			assert.Nil(t, err)
+			assert.Equal(t, sortedKeys(files), testCase.files)
+			content := files[testCase.files[0]]
+			parsed, err := parser.ParseFile(token.NewFileSet(), testCase.files[0], content, parser.ImportsOnly)
+			assert.Nil(t, err)
+			assert.Equal(t, parsed.Name.Name, testCase.pkg)
+			for _, path := range testCase.imports {
+				assert.True(t, strings.Contains(content, `"`+path+`"`), assert.Sprintf("missing import %q", path))
+			}
+			assert.Equal(t, strings.Contains(content, "func NewPingServiceClient("), testCase.client)
+			assert.Equal(t, strings.Contains(content, "type PingServiceClient interface"), testCase.client)
+			assert.Equal(t, strings.Contains(content, "func NewPingServiceHandler("), testCase.server)
+			assert.Equal(t, strings.Contains(content, "type PingServiceHandler interface"), testCase.server)
+			for _, name := range testCase.files[1:] {
+				_, err := parser.ParseFile(token.NewFileSet(), name, files[name], 0)
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestGenerateSimple(t *testing.T) {
+	t.Parallel()
+	files, err := generateFiles(t, "simple,fakes")
+	assert.Nil(t, err)
+	content := files["connect/ping/v1/pingv1connect/ping.connect.go"]
+	_, err = parser.ParseFile(token.NewFileSet(), "ping.connect.go", content, 0)
+	assert.Nil(t, err)
+	for _, want := range []string{
+		// Unary methods use bare messages on both sides...
+		"Ping(context.Context, *v1.PingRequest) (*v1.PingResponse, error)",
+		"return c.ping.CallUnarySimple(ctx, req)",
+		"connect_go.SimpleUnaryHandler(svc.Ping)",
+		"func (UnimplementedPingServiceHandler) Ping(context.Context, *v1.PingRequest) (*v1.PingResponse, error)",
+		// ...but streaming methods are unchanged.
+		"CountUp(context.Context, *connect_go.Request[v1.CountUpRequest]) (*connect_go.ServerStreamForClient[v1.CountUpResponse], error)",
+		"CountUp(context.Context, *connect_go.Request[v1.CountUpRequest], *connect_go.ServerStream[v1.CountUpResponse]) error",
+	} {
+		assert.True(t, strings.Contains(content, want), assert.Sprintf("missing %q", want))
+	}
+	assert.False(t, strings.Contains(content, "Ping(context.Context, *connect_go.Request[v1.PingRequest])"))
+	fakes := files["connect/ping/v1/pingv1connectfake/ping.fake.go"]
+	_, err = parser.ParseFile(token.NewFileSet(), "ping.fake.go", fakes, 0)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(fakes, "func (f *PingServiceClient) Ping(ctx context.Context, req *v1.PingRequest) (*v1.PingResponse, error)"))
+	assert.True(t, strings.Contains(fakes, "func (f *PingServiceHandler) Ping(ctx context.Context, req *v1.PingRequest) (*v1.PingResponse, error)"))
+}
+
+func TestGenerateInvalidParameters(t *testing.T) {
+	t.Parallel()
+	for _, params := range []string{
+		"generate=neither",
+		"package_suffix=",
+		"filename_extension=",
+		"same_package,filename_extension=.pb.go",
+		"unknown=true",
+	} {
+		_, err := generateFiles(t, params)
+		assert.NotNil(t, err, assert.Sprintf("params %q", params))
+	}
+}
+
+// generateFiles runs the plugin on ping.proto and returns the generated files
+// by name.
+func generateFiles(tb testing.TB, params string) (map[string]string, error) {
+	tb.Helper()
+	if params == "" {
+		params = baseParams
+	} else {
+		params += "," + baseParams
+	}
+	var opts options
+	plugin, err := protogen.Options{ParamFunc: opts.paramFunc()}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"connect/ping/v1/ping.proto"},
+		Parameter:      proto.String(params),
+		ProtoFile: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(pingv1.File_connect_ping_v1_ping_proto),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.run(plugin); err != nil {
+		return nil, err
+	}
+	response := plugin.Response()
+	if response.Error != nil {
+		tb.Fatalf("plugin error: %s", response.GetError())
+	}
+	files := make(map[string]string, len(response.File))
+	for _, file := range response.File {
+		files[file.GetName()] = file.GetContent()
+	}
+	return files, nil
+}
+
+func sortedKeys(files map[string]string) []string {
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
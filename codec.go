@@ -15,6 +15,7 @@
 package connect
 
 import (
+	"bytes"
 	"fmt"
 
 	"google.golang.org/protobuf/encoding/protojson"
@@ -27,6 +28,8 @@ const (
 )
 
 // Codec marshals structs (typically generated from a schema) to and from bytes.
+// Codecs may also implement MarshalAppender to avoid allocating a new byte
+// slice for each message.
 type Codec interface {
 	// Name returns the name of the Codec.
 	//
@@ -48,9 +51,65 @@ type Codec interface {
 	Unmarshal([]byte, any) error
 }
 
-type protoBinaryCodec struct{}
+// MarshalAppender is an optional extension to Codec. Codecs that implement it
+// marshal messages directly into this package's pooled buffers, avoiding an
+// allocation and a copy for each message. The codec returned by
+// NewProtoBinaryCodec implements MarshalAppender.
+type MarshalAppender interface {
+	Codec
+	// MarshalAppend marshals the given message and appends it to the given
+	// byte slice, returning the extended slice.
+	//
+	// MarshalAppend may expect a specific type of message, and will error if
+	// this type is not given.
+	MarshalAppend([]byte, any) ([]byte, error)
+}
+
+// NewProtoBinaryCodec returns a Codec for the binary Protocol Buffer format,
+// using the supplied options from google.golang.org/protobuf/proto. It's named
+// "proto", so registering it with WithCodec replaces the default binary codec
+// for clients and handlers:
+//
+//   connect.WithCodec(connect.NewProtoBinaryCodec(
+//     proto.MarshalOptions{Deterministic: true},
+//     proto.UnmarshalOptions{DiscardUnknown: true},
+//   ))
+func NewProtoBinaryCodec(marshal proto.MarshalOptions, unmarshal proto.UnmarshalOptions) Codec {
+	return &protoBinaryCodec{
+		marshalOptions:   marshal,
+		unmarshalOptions: unmarshal,
+	}
+}
+
+// NewProtoJSONCodec returns a Codec for the standard Protobuf JSON mapping,
+// using the supplied options from google.golang.org/protobuf/encoding/protojson.
+// It's named "json", so registering it with WithCodec replaces the default
+// JSON codec for handlers and makes clients send JSON:
+//
+//   connect.WithCodec(connect.NewProtoJSONCodec(
+//     protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+//     protojson.UnmarshalOptions{DiscardUnknown: true},
+//   ))
+//
+// To resolve the types in google.protobuf.Any fields from a custom registry,
+// set the Resolver field of both options.
+//
+// The returned Codec doesn't implement MarshalAppender: this module requires
+// google.golang.org/protobuf v1.28.0, which doesn't have a MarshalAppend
+// method on protojson.MarshalOptions.
+func NewProtoJSONCodec(marshal protojson.MarshalOptions, unmarshal protojson.UnmarshalOptions) Codec {
+	return &protoJSONCodec{
+		marshalOptions:   marshal,
+		unmarshalOptions: unmarshal,
+	}
+}
+
+type protoBinaryCodec struct {
+	marshalOptions   proto.MarshalOptions
+	unmarshalOptions proto.UnmarshalOptions
+}
 
-var _ Codec = (*protoBinaryCodec)(nil)
+var _ MarshalAppender = (*protoBinaryCodec)(nil)
 
 func (c *protoBinaryCodec) Name() string { return codecNameProto }
 
@@ -59,7 +118,15 @@ func (c *protoBinaryCodec) Marshal(message any) ([]byte, error) {
 	if !ok {
 		return nil, errNotProto(message)
 	}
-	return proto.Marshal(protoMessage)
+	return c.marshalOptions.Marshal(protoMessage)
+}
+
+func (c *protoBinaryCodec) MarshalAppend(dst []byte, message any) ([]byte, error) {
+	protoMessage, ok := message.(proto.Message)
+	if !ok {
+		return nil, errNotProto(message)
+	}
+	return c.marshalOptions.MarshalAppend(dst, protoMessage)
 }
 
 func (c *protoBinaryCodec) Unmarshal(data []byte, message any) error {
@@ -67,10 +134,13 @@ func (c *protoBinaryCodec) Unmarshal(data []byte, message any) error {
 	if !ok {
 		return errNotProto(message)
 	}
-	return proto.Unmarshal(data, protoMessage)
+	return c.unmarshalOptions.Unmarshal(data, protoMessage)
 }
 
-type protoJSONCodec struct{}
+type protoJSONCodec struct {
+	marshalOptions   protojson.MarshalOptions
+	unmarshalOptions protojson.UnmarshalOptions
+}
 
 var _ Codec = (*protoJSONCodec)(nil)
 
@@ -81,8 +151,7 @@ func (c *protoJSONCodec) Marshal(message any) ([]byte, error) {
 	if !ok {
 		return nil, errNotProto(message)
 	}
-	var options protojson.MarshalOptions
-	return options.Marshal(protoMessage)
+	return c.marshalOptions.Marshal(protoMessage)
 }
 
 func (c *protoJSONCodec) Unmarshal(binary []byte, message any) error {
@@ -90,8 +159,7 @@ func (c *protoJSONCodec) Unmarshal(binary []byte, message any) error {
 	if !ok {
 		return errNotProto(message)
 	}
-	var options protojson.UnmarshalOptions
-	return options.Unmarshal(binary, protoMessage)
+	return c.unmarshalOptions.Unmarshal(binary, protoMessage)
 }
 
 // readOnlyCodecs is a read-only interface to a map of named codecs.
@@ -139,6 +207,38 @@ func (m *codecMap) Names() []string {
 	return names
 }
 
+// marshalToBuffer marshals the message into a buffer. The caller should return
+// the buffer to the pool when they're done with it.
+func marshalToBuffer(codec Codec, pool *bufferPool, message any) (*bytes.Buffer, error) {
+	appender, ok := codec.(MarshalAppender)
+	if !ok {
+		// We can't avoid allocating the byte slice, so we may as well reuse it
+		// once we're done with it.
+		raw, err := codec.Marshal(message)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewBuffer(raw), nil
+	}
+	buffer := pool.Get()
+	raw, err := appender.MarshalAppend(buffer.Bytes(), message)
+	if err != nil {
+		pool.Put(buffer)
+		return nil, err
+	}
+	if cap(raw) > buffer.Cap() {
+		// The pooled buffer was too small, so MarshalAppend grew the slice. Keep
+		// the larger slice instead, so the pool adapts to the workload, and
+		// return the smaller buffer to the pool.
+		pool.Put(buffer)
+		return bytes.NewBuffer(raw), nil
+	}
+	// MarshalAppend wrote into the buffer's backing array, so point the buffer
+	// at the marshaled bytes rather than copying them onto themselves.
+	*buffer = *bytes.NewBuffer(raw)
+	return buffer, nil
+}
+
 func errNotProto(message any) error {
 	return fmt.Errorf("%T doesn't implement proto.Message", message)
 }
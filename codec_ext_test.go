@@ -0,0 +1,98 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connect"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProtoJSONCodecOptions(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		pingServer{},
+		connect.WithCodec(connect.NewProtoJSONCodec(
+			protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+			protojson.UnmarshalOptions{DiscardUnknown: true},
+		)),
+	))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	t.Run("wire", func(t *testing.T) {
+		response, err := server.Client().Post(
+			server.URL+pingv1connect.PingServicePingProcedure,
+			"application/json",
+			strings.NewReader(`{"text": "hi", "added_in_v2": true}`),
+		)
+		assert.Nil(t, err)
+		defer response.Body.Close()
+		assert.Equal(t, response.StatusCode, http.StatusOK)
+		var fields map[string]any
+		assert.Nil(t, json.NewDecoder(response.Body).Decode(&fields))
+		assert.Equal(t, fields, map[string]any{"number": "0", "text": "hi"})
+	})
+	t.Run("client", func(t *testing.T) {
+		client := pingv1connect.NewPingServiceClient(
+			server.Client(),
+			server.URL,
+			connect.WithCodec(connect.NewProtoJSONCodec(
+				protojson.MarshalOptions{UseProtoNames: true},
+				protojson.UnmarshalOptions{},
+			)),
+		)
+		response, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{Number: 42}))
+		assert.Nil(t, err)
+		assert.Equal(t, response.Msg.Number, 42)
+	})
+}
+
+func TestProtoBinaryCodecOptions(t *testing.T) {
+	t.Parallel()
+	codec := connect.NewProtoBinaryCodec(
+		proto.MarshalOptions{Deterministic: true},
+		proto.UnmarshalOptions{DiscardUnknown: true},
+	)
+	assert.Equal(t, codec.Name(), "proto")
+	unknown := (&pingv1.PingRequest{Number: 42}).ProtoReflect()
+	unknown.SetUnknown([]byte{0xc0, 0x3e, 0x01}) // field 1000, varint 1
+	data, err := proto.Marshal(unknown.Interface())
+	assert.Nil(t, err)
+	var request pingv1.PingRequest
+	assert.Nil(t, codec.Unmarshal(data, &request))
+	assert.Equal(t, request.Number, 42)
+	assert.Equal(t, len(request.ProtoReflect().GetUnknown()), 0)
+
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(pingServer{}, connect.WithCodec(codec)))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	client := pingv1connect.NewPingServiceClient(server.Client(), server.URL, connect.WithGRPC(), connect.WithCodec(codec))
+	response, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{Number: 42}))
+	assert.Nil(t, err)
+	assert.Equal(t, response.Msg.Number, 42)
+}
@@ -0,0 +1,48 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMarshalToBuffer(t *testing.T) {
+	t.Parallel()
+	pool := newBufferPool()
+	for _, text := range []string{"", "short", strings.Repeat("a", 4*initialBufferSize)} {
+		message := &pingv1.PingRequest{Number: 42, Text: text}
+		want, err := proto.Marshal(message)
+		assert.Nil(t, err)
+		for _, codec := range []Codec{&protoBinaryCodec{}, &protoJSONCodec{}} {
+			buffer, err := marshalToBuffer(codec, pool, message)
+			assert.Nil(t, err)
+			if codec.Name() == codecNameProto {
+				assert.True(t, bytes.Equal(buffer.Bytes(), want))
+			}
+			roundtrip := &pingv1.PingRequest{}
+			assert.Nil(t, codec.Unmarshal(buffer.Bytes(), roundtrip))
+			assert.True(t, proto.Equal(roundtrip, message))
+			pool.Put(buffer)
+		}
+	}
+	_, err := marshalToBuffer(&protoBinaryCodec{}, pool, "not a proto")
+	assert.NotNil(t, err)
+}
@@ -26,6 +26,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"net/url"
 )
 
 // Version is the semantic version of the connect module.
@@ -36,6 +37,7 @@ const Version = "0.3.0-dev"
 const (
 	IsAtLeastVersion0_0_1 = true
 	IsAtLeastVersion0_1_0 = true
+	IsAtLeastVersion0_3_0 = true
 )
 
 // StreamType describes whether the client, server, neither, or both is
@@ -119,7 +121,11 @@ type Request[T any] struct {
 	Msg *T
 
 	spec   Spec
+	peer   Peer
 	header http.Header
+	stats  *statsSource
+	// newResponse is set by clients, which know the response type.
+	newResponse func() AnyResponse
 }
 
 // NewRequest wraps a generated request message.
@@ -142,6 +148,12 @@ func (r *Request[_]) Spec() Spec {
 	return r.spec
 }
 
+// Peer describes the other party for this RPC. The returned Peer has its own
+// copy of the URL, so callers may modify it.
+func (r *Request[_]) Peer() Peer {
+	return r.peer.clone()
+}
+
 // Header returns the HTTP headers for this request.
 func (r *Request[_]) Header() http.Header {
 	if r.header == nil {
@@ -150,9 +162,52 @@ func (r *Request[_]) Header() http.Header {
 	return r.header
 }
 
+// Stats reports the messages and bytes exchanged so far. It's only populated
+// for requests received by handlers. In unary handlers, it covers just the
+// request message, since the response hasn't been sent yet. For requests
+// constructed with NewRequest, it returns the zero value.
+func (r *Request[_]) Stats() Stats {
+	stats, _ := r.stats.Stats()
+	return stats
+}
+
+// HTTPMethod returns the HTTP method for this request. It's populated by
+// clients and handlers, so it's empty until the request has been sent or
+// received.
+func (r *Request[_]) HTTPMethod() string {
+	return r.peer.HTTPMethod
+}
+
+// URL returns a copy of the URL for this request. On the client, it's the URL
+// the request is sent to; in handlers, it's the URL received from the network,
+// including any query parameters. It's nil until the request has been sent or
+// received.
+func (r *Request[_]) URL() *url.URL {
+	if r.peer.URL == nil {
+		return nil
+	}
+	clone := *r.peer.URL
+	return &clone
+}
+
 // internalOnly implements AnyRequest.
 func (r *Request[_]) internalOnly() {}
 
+// clone implements AnyRequest.
+func (r *Request[_]) clone() AnyRequest {
+	clone := *r
+	clone.header = r.header.Clone()
+	return &clone
+}
+
+// newClientResponse implements AnyRequest.
+func (r *Request[_]) newClientResponse() (AnyResponse, bool) {
+	if r.newResponse == nil {
+		return nil, false
+	}
+	return r.newResponse(), true
+}
+
 // AnyRequest is the common method set of all Requests, regardless of type
 // parameter. It's used in unary interceptors.
 //
@@ -162,9 +217,30 @@ func (r *Request[_]) internalOnly() {}
 type AnyRequest interface {
 	Any() any
 	Spec() Spec
+	Peer() Peer
 	Header() http.Header
+	HTTPMethod() string
+	URL() *url.URL
 
 	internalOnly()
+	clone() AnyRequest
+	newClientResponse() (AnyResponse, bool)
+}
+
+// CloneRequest returns a shallow copy of the request with its own copy of the
+// headers. The copy shares the message with the original, so interceptors
+// that pass the copy to other goroutines should copy the message too.
+func CloneRequest(request AnyRequest) AnyRequest {
+	return request.clone()
+}
+
+// NewClientResponse returns an empty response of the type expected by the
+// client sending the request, with a newly allocated message. It lets client
+// interceptors that answer calls without calling the next UnaryFunc, like
+// caches, build responses without knowing the response type. It returns false
+// if the request isn't being sent by a Client.
+func NewClientResponse(request AnyRequest) (AnyResponse, bool) {
+	return request.newClientResponse()
 }
 
 // Response is a wrapper around a generated response message. It provides
@@ -175,6 +251,7 @@ type Response[T any] struct {
 
 	header  http.Header
 	trailer http.Header
+	stats   Stats
 }
 
 // NewResponse wraps a generated response message.
@@ -211,6 +288,18 @@ func (r *Response[_]) Trailer() http.Header {
 	return r.trailer
 }
 
+// Stats reports the messages and bytes exchanged during the RPC. It's only
+// populated for responses returned by clients: for responses constructed with
+// NewResponse, it returns the zero value.
+func (r *Response[_]) Stats() Stats {
+	return r.stats
+}
+
+// newEmptyResponse returns a response with a newly allocated message.
+func newEmptyResponse[T any]() AnyResponse {
+	return &Response[T]{Msg: new(T)}
+}
+
 // internalOnly implements AnyResponse.
 func (r *Response[_]) internalOnly() {}
 
@@ -235,12 +324,66 @@ type HTTPClient interface {
 }
 
 // Spec is a description of a client call or a handler invocation.
+//
+// If you're using Protobuf, protoc-gen-connect-go generates a constant for the
+// fully-qualified Procedure corresponding to each RPC in your schema, and it
+// populates Schema with the RPC's protoreflect.MethodDescriptor. Schema is
+// nil for clients and handlers constructed without WithSchema.
 type Spec struct {
 	StreamType StreamType
+	Schema     any
 	Procedure  string // for example, "/acme.foo.v1.FooService/Bar"
 	IsClient   bool   // otherwise we're in a handler
 }
 
+// Peer describes the other party to an RPC.
+//
+// When accessed client-side, Addr contains the host or host:port from the
+// server's URL. When accessed server-side, Addr contains the client's address
+// in IP:port format.
+//
+// Protocol is the RPC protocol in use: ProtocolConnect, ProtocolGRPC, or
+// ProtocolGRPCWeb. HTTPMethod and URL describe the HTTP request. Client-side,
+// URL is the procedure's URL on the server; server-side, it's the URL received
+// from the network, including any query parameters. Each call to a Peer
+// method in this package returns a Peer with its own copy of the URL.
+type Peer struct {
+	Addr       string
+	Protocol   string
+	HTTPMethod string
+	URL        *url.URL
+}
+
+// clone returns a copy of the Peer with its own copy of the URL.
+func (p Peer) clone() Peer {
+	if p.URL != nil {
+		url := *p.URL
+		p.URL = &url
+	}
+	return p
+}
+
+// PeerReporter is an optional interface for StreamingHandlerConns and
+// StreamingClientConns that know their Peer. All the conns in this module
+// implement it, including the wrappers added by this module's interceptors.
+// Interceptors that wrap conns should implement PeerReporter by delegating to
+// the wrapped conn, so that the Peer stays visible to later interceptors and
+// to server-streaming handlers.
+type PeerReporter interface {
+	// Peer describes the other party to the RPC. It must be safe to call
+	// concurrently with all other methods.
+	Peer() Peer
+}
+
+// PeerOf returns the conn's Peer, or the zero value if the conn doesn't
+// implement PeerReporter.
+func PeerOf(conn any) Peer {
+	if reporter, ok := conn.(PeerReporter); ok {
+		return reporter.Peer()
+	}
+	return Peer{}
+}
+
 // handlerConnCloser extends HandlerConn with a method for handlers to
 // terminate the message exchange (and optionally send an error to the client).
 type handlerConnCloser interface {
@@ -249,11 +392,27 @@ type handlerConnCloser interface {
 	Close(error) error
 }
 
+// receiveUnaryRequest unmarshals a message from a StreamingHandlerConn, then
+// envelopes the message and attaches the request metadata.
+func receiveUnaryRequest[T any](conn StreamingHandlerConn) (*Request[T], error) {
+	var msg T
+	if err := conn.Receive(&msg); err != nil {
+		return nil, err
+	}
+	request := &Request[T]{
+		Msg:    &msg,
+		spec:   conn.Spec(),
+		peer:   PeerOf(conn),
+		header: conn.RequestHeader(),
+	}
+	return request, nil
+}
+
 // receiveUnaryResponse unmarshals a message from a StreamingClientConn, then
 // envelopes the message and attaches headers and trailers. It attempts to
 // consume the response stream and isn't appropriate when receiving multiple
-// messages.
-func receiveUnaryResponse[T any](conn StreamingClientConn) (*Response[T], error) {
+// messages. The response's Stats are read from the supplied statsSource.
+func receiveUnaryResponse[T any](conn StreamingClientConn, stats *statsSource) (*Response[T], error) {
 	var msg T
 	if err := conn.Receive(&msg); err != nil {
 		return nil, err
@@ -266,9 +425,11 @@ func receiveUnaryResponse[T any](conn StreamingClientConn) (*Response[T], error)
 	} else if err != nil && !errors.Is(err, io.EOF) {
 		return nil, NewError(CodeUnknown, err)
 	}
+	snapshot, _ := stats.Stats()
 	return &Response[T]{
 		Msg:     &msg,
 		header:  conn.ResponseHeader(),
 		trailer: conn.ResponseTrailer(),
+		stats:   snapshot,
 	}, nil
 }
@@ -722,6 +722,134 @@ func TestInterceptorReturnsWrongType(t *testing.T) {
 	assert.True(t, strings.Contains(connectErr.Message(), "unexpected client response type"))
 }
 
+func TestRequestMetadata(t *testing.T) {
+	t.Parallel()
+	handlerRequests := make(chan connect.AnyRequest, 1)
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		pingServer{},
+		connect.WithInterceptors(connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+			return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
+				handlerRequests <- request
+				return next(ctx, request)
+			}
+		})),
+	))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	for _, protocol := range []struct {
+		name   string
+		option connect.ClientOption
+	}{
+		{connect.ProtocolConnect, connect.WithClientOptions()},
+		{connect.ProtocolGRPCWeb, connect.WithGRPCWeb()},
+	} {
+		request := connect.NewRequest(&pingv1.PingRequest{Number: 42})
+		request.Header().Set(clientHeader, headerValue)
+		assert.Equal(t, request.HTTPMethod(), "")
+		assert.Nil(t, request.URL())
+		client := connect.NewClient[pingv1.PingRequest, pingv1.PingResponse](
+			server.Client(),
+			server.URL+"/connect.ping.v1.PingService/Ping?key=value",
+			protocol.option,
+		)
+		_, err := client.CallUnary(context.Background(), request)
+		assert.Nil(t, err)
+
+		assert.Equal(t, request.HTTPMethod(), http.MethodPost)
+		assert.Equal(t, request.Peer().Protocol, protocol.name)
+		assert.Equal(t, request.Peer().Addr, strings.TrimPrefix(server.URL, "http://"))
+		assert.Equal(t, request.URL().Path, "/connect.ping.v1.PingService/Ping")
+		// Every request from a client shares the same Peer, so mutating one
+		// request's Peer must not affect later calls.
+		request.Peer().URL.Path = "/mutated"
+		assert.Equal(t, request.Peer().URL.Path, "/connect.ping.v1.PingService/Ping")
+		<-handlerRequests
+		next := connect.NewRequest(&pingv1.PingRequest{Number: 42})
+		_, err = client.CallUnary(context.Background(), next)
+		assert.Nil(t, err)
+		assert.Equal(t, next.Peer().URL.Path, "/connect.ping.v1.PingService/Ping")
+
+		handlerRequest := <-handlerRequests
+		assert.Equal(t, handlerRequest.HTTPMethod(), http.MethodPost)
+		assert.Equal(t, handlerRequest.Peer().Protocol, protocol.name)
+		assert.NotZero(t, handlerRequest.Peer().Addr)
+		assert.Equal(t, handlerRequest.URL().Query().Get("key"), "value")
+		assert.Equal(t, handlerRequest.URL().Path, "/connect.ping.v1.PingService/Ping")
+		// Mutating the returned URL must not affect the request.
+		handlerRequest.URL().Path = "/mutated"
+		assert.Equal(t, handlerRequest.URL().Path, "/connect.ping.v1.PingService/Ping")
+	}
+}
+
+func TestRequestMetadataWrappedConn(t *testing.T) {
+	t.Parallel()
+	// Interceptors that wrap the StreamingHandlerConn must not hide the
+	// request's HTTP method and URL.
+	handlerRequests := make(chan connect.AnyRequest, 1)
+	mux := http.NewServeMux()
+	mux.Handle(
+		pingv1connect.PingServiceCountUpProcedure,
+		connect.NewServerStreamHandler(
+			pingv1connect.PingServiceCountUpProcedure,
+			func(_ context.Context, request *connect.Request[pingv1.CountUpRequest], _ *connect.ServerStream[pingv1.CountUpResponse]) error {
+				handlerRequests <- request
+				return nil
+			},
+			connect.WithInterceptors(connect.NewStreamInterceptor(
+				connect.OnReceive(func(context.Context, connect.Spec, any) error { return nil }),
+			)),
+		),
+	)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := connect.NewClient[pingv1.CountUpRequest, pingv1.CountUpResponse](
+		server.Client(),
+		server.URL+pingv1connect.PingServiceCountUpProcedure+"?key=value",
+	)
+	stream, err := client.CallServerStream(context.Background(), connect.NewRequest(&pingv1.CountUpRequest{}))
+	assert.Nil(t, err)
+	assert.False(t, stream.Receive())
+	assert.Nil(t, stream.Err())
+	assert.Nil(t, stream.Close())
+
+	handlerRequest := <-handlerRequests
+	assert.Equal(t, handlerRequest.HTTPMethod(), http.MethodPost)
+	assert.NotNil(t, handlerRequest.URL())
+	assert.Equal(t, handlerRequest.URL().Path, pingv1connect.PingServiceCountUpProcedure)
+	assert.Equal(t, handlerRequest.URL().Query().Get("key"), "value")
+}
+
+func TestCloneRequestAndNewClientResponse(t *testing.T) {
+	t.Parallel()
+	request := connect.NewRequest(&pingv1.PingRequest{Number: 1})
+	request.Header().Set("Foo", "bar")
+	_, ok := connect.NewClientResponse(request)
+	assert.False(t, ok)
+
+	client := pingv1connect.NewPingServiceClient(
+		http.DefaultClient,
+		"http://invalid.test",
+		connect.WithInterceptors(connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+			return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
+				clone := connect.CloneRequest(request)
+				clone.Header().Set("Foo", "baz")
+				assert.Equal(t, request.Header().Get("Foo"), "bar")
+				assert.Equal(t, clone.Spec().Procedure, request.Spec().Procedure)
+				assert.Equal(t, clone.Any(), request.Any())
+				// Answer without calling next.
+				response, ok := connect.NewClientResponse(clone)
+				assert.True(t, ok)
+				response.Any().(*pingv1.PingResponse).Number = 42
+				return response, nil
+			}
+		})),
+	)
+	response, err := client.Ping(context.Background(), request)
+	assert.Nil(t, err)
+	assert.Equal(t, response.Msg.Number, 42)
+}
+
 func TestHandlerWithReadMaxBytes(t *testing.T) {
 	t.Parallel()
 	mux := http.NewServeMux()
@@ -0,0 +1,241 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dedup provides a client-side connect.Interceptor that merges
+// concurrent, identical unary calls into a single network call, like
+// golang.org/x/sync/singleflight. It's most useful in front of hot keys, where
+// many callers ask for the same data at the same time.
+package dedup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"google.golang.org/protobuf/proto"
+)
+
+const headerAuthorization = "Authorization"
+
+// NewInterceptor returns a client-side connect.Interceptor that deduplicates
+// in-flight unary calls. Calls are identical if they're sent to the same URL
+// with the same protocol, have the same deterministic binary encoding of the
+// request message, and have the same values for the Authorization header and
+// any headers selected with WithHeaders. Only Protobuf requests are
+// deduplicated.
+//
+// The first caller starts the shared call, and later callers wait for its
+// result. Every caller gets its own copy of the response. Errors are shared,
+// so callers must not modify them.
+//
+// Each caller's context is respected separately: a caller whose context ends
+// stops waiting and gets a CodeCanceled or CodeDeadlineExceeded error, but the
+// shared call continues until every waiting caller is gone. The shared call
+// uses the first caller's context values and request headers, but it has no
+// deadline of its own. It sends a copy of the first caller's request that
+// shares the request message, so callers may reuse their request headers as
+// soon as they stop waiting, but must never modify a message once it's sent.
+// If the shared call panics, every waiting caller gets a CodeInternal error.
+//
+// The interceptor has no effect on handlers or streaming RPCs.
+func NewInterceptor(options ...Option) connect.Interceptor {
+	interceptor := &interceptor{
+		headers: []string{headerAuthorization},
+		calls:   make(map[string]*call),
+	}
+	for _, opt := range options {
+		opt.apply(interceptor)
+	}
+	return interceptor
+}
+
+type interceptor struct {
+	headers []string
+
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// call is an in-flight or completed shared call.
+type call struct {
+	done     chan struct{}
+	cancel   context.CancelFunc
+	waiters  int // guarded by interceptor.mu
+	response connect.AnyResponse
+	err      error
+}
+
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
+		if !request.Spec().IsClient {
+			return next(ctx, request)
+		}
+		key, ok := i.key(request)
+		if !ok {
+			return next(ctx, request)
+		}
+		i.mu.Lock()
+		shared, ok := i.calls[key]
+		if !ok {
+			sharedCtx, cancel := context.WithCancel(detachedContext{ctx})
+			shared = &call{done: make(chan struct{}), cancel: cancel}
+			i.calls[key] = shared
+			go i.run(sharedCtx, next, connect.CloneRequest(request), key, shared)
+		}
+		shared.waiters++
+		i.mu.Unlock()
+
+		select {
+		case <-shared.done:
+			i.leave(key, shared)
+			if shared.err != nil {
+				return nil, shared.err
+			}
+			return cloneResponse(request, shared.response)
+		case <-ctx.Done():
+			i.leave(key, shared)
+			return nil, contextError(ctx.Err())
+		}
+	}
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+func (i *interceptor) run(
+	ctx context.Context,
+	next connect.UnaryFunc,
+	request connect.AnyRequest,
+	key string,
+	shared *call,
+) {
+	panicked := true
+	defer func() {
+		if panicked {
+			// Waiters can't receive the panic, so give them an error instead.
+			r := recover()
+			shared.response = nil
+			shared.err = connect.NewError(connect.CodeInternal, fmt.Errorf("deduplicated call panicked: %v", r))
+		}
+		i.mu.Lock()
+		// Callers arriving from now on should start a new call.
+		if i.calls[key] == shared {
+			delete(i.calls, key)
+		}
+		i.mu.Unlock()
+		close(shared.done)
+	}()
+	shared.response, shared.err = next(ctx, request)
+	panicked = false
+}
+
+// leave removes a waiter from the call, canceling it if nobody's left.
+func (i *interceptor) leave(key string, shared *call) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	shared.waiters--
+	if shared.waiters > 0 {
+		return
+	}
+	shared.cancel()
+	if i.calls[key] == shared {
+		delete(i.calls, key)
+	}
+}
+
+func (i *interceptor) key(request connect.AnyRequest) (string, bool) {
+	message, ok := request.Any().(proto.Message)
+	if !ok {
+		return "", false
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		return "", false
+	}
+	peer := request.Peer()
+	var key strings.Builder
+	key.WriteString(peer.Protocol)
+	key.WriteByte(0)
+	if peer.URL != nil {
+		key.WriteString(peer.URL.String())
+	} else {
+		key.WriteString(peer.Addr)
+		key.WriteString(request.Spec().Procedure)
+	}
+	key.WriteByte(0)
+	key.Write(data)
+	for _, name := range i.headers {
+		key.WriteByte(0)
+		key.WriteString(strings.Join(request.Header().Values(name), "\x01"))
+	}
+	return key.String(), true
+}
+
+// cloneResponse returns a deep copy of the shared response, with the type
+// expected by the client sending the request.
+func cloneResponse(request connect.AnyRequest, response connect.AnyResponse) (connect.AnyResponse, error) {
+	clone, ok := connect.NewClientResponse(request)
+	if !ok {
+		return nil, connect.NewError(connect.CodeInternal, errors.New("deduplicated call made outside a client"))
+	}
+	cloneMessage, ok := clone.Any().(proto.Message)
+	if !ok {
+		return nil, connect.NewError(connect.CodeInternal, errors.New("deduplicated response isn't a Protobuf message"))
+	}
+	protoMessage, ok := response.Any().(proto.Message)
+	if !ok {
+		return nil, connect.NewError(connect.CodeInternal, errors.New("deduplicated response isn't a Protobuf message"))
+	}
+	proto.Merge(cloneMessage, protoMessage)
+	mergeHeaders(clone.Header(), response.Header())
+	mergeHeaders(clone.Trailer(), response.Trailer())
+	return clone, nil
+}
+
+func mergeHeaders(into, from http.Header) {
+	for key, values := range from {
+		into[key] = append(into[key], values...)
+	}
+}
+
+func contextError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return connect.NewError(connect.CodeDeadlineExceeded, err)
+	}
+	return connect.NewError(connect.CodeCanceled, err)
+}
+
+// detachedContext keeps a context's values, but not its deadline or
+// cancellation.
+type detachedContext struct {
+	parent context.Context // nolint:containedctx
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key any) any {
+	return c.parent.Value(key)
+}
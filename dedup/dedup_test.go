@@ -0,0 +1,274 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dedup_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/bufbuild/connect-go/dedup"
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connect"
+)
+
+// blockingPingServer blocks each call until it's released.
+type blockingPingServer struct {
+	pingv1connect.UnimplementedPingServiceHandler
+
+	calls    int64
+	started  chan struct{}
+	release  chan struct{}
+	canceled chan struct{}
+}
+
+func newBlockingPingServer() *blockingPingServer {
+	return &blockingPingServer{
+		started:  make(chan struct{}, 10),
+		release:  make(chan struct{}),
+		canceled: make(chan struct{}, 10),
+	}
+}
+
+func (p *blockingPingServer) Ping(ctx context.Context, request *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+	calls := atomic.AddInt64(&p.calls, 1)
+	p.started <- struct{}{}
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		p.canceled <- struct{}{}
+		return nil, ctx.Err()
+	}
+	response := connect.NewResponse(&pingv1.PingResponse{Number: calls, Text: request.Msg.Text})
+	response.Header().Set("Ping-Header", "header")
+	return response, nil
+}
+
+func (p *blockingPingServer) Calls() int64 {
+	return atomic.LoadInt64(&p.calls)
+}
+
+func TestInterceptor(t *testing.T) {
+	t.Parallel()
+	newClientWithInterceptors := func(t *testing.T, server *blockingPingServer, interceptors ...connect.Interceptor) pingv1connect.PingServiceClient {
+		t.Helper()
+		mux := http.NewServeMux()
+		mux.Handle(pingv1connect.NewPingServiceHandler(server))
+		httpServer := httptest.NewServer(mux)
+		t.Cleanup(httpServer.Close)
+		return pingv1connect.NewPingServiceClient(
+			httpServer.Client(),
+			httpServer.URL,
+			connect.WithInterceptors(interceptors...),
+		)
+	}
+	newClient := func(t *testing.T, server *blockingPingServer) pingv1connect.PingServiceClient {
+		t.Helper()
+		return newClientWithInterceptors(t, server, dedup.NewInterceptor(dedup.WithHeaders("Tenant")))
+	}
+	type result struct {
+		response *connect.Response[pingv1.PingResponse]
+		err      error
+	}
+	call := func(ctx context.Context, client pingv1connect.PingServiceClient, text, tenant string) <-chan result {
+		results := make(chan result, 1)
+		go func() {
+			request := connect.NewRequest(&pingv1.PingRequest{Text: text})
+			request.Header().Set("Tenant", tenant)
+			response, err := client.Ping(ctx, request)
+			results <- result{response: response, err: err}
+		}()
+		return results
+	}
+	// waitForWaiters gives callers time to join the in-flight call. The
+	// interceptor doesn't expose its waiters, so we can only wait.
+	waitForWaiters := func() {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Run("merge", func(t *testing.T) {
+		t.Parallel()
+		server := newBlockingPingServer()
+		client := newClient(t, server)
+		var results []<-chan result
+		for i := 0; i < 5; i++ {
+			results = append(results, call(context.Background(), client, "hot", "acme"))
+		}
+		other := call(context.Background(), client, "hot", "globex")
+		<-server.started
+		<-server.started
+		waitForWaiters()
+		close(server.release)
+		var responses []*connect.Response[pingv1.PingResponse]
+		for _, results := range results {
+			result := <-results
+			assert.Nil(t, result.err)
+			assert.Equal(t, result.response.Header().Get("Ping-Header"), "header")
+			responses = append(responses, result.response)
+		}
+		assert.Nil(t, (<-other).err)
+		// One call per distinct tenant.
+		assert.Equal(t, server.Calls(), int64(2))
+		// Every caller gets its own copy.
+		responses[0].Msg.Text = "modified"
+		assert.Equal(t, responses[1].Msg.Text, "hot")
+		assert.Equal(t, responses[1].Msg.Number, responses[0].Msg.Number)
+	})
+	t.Run("cancel_one", func(t *testing.T) {
+		t.Parallel()
+		server := newBlockingPingServer()
+		client := newClient(t, server)
+		ctx, cancel := context.WithCancel(context.Background())
+		first := call(ctx, client, "hot", "acme")
+		<-server.started
+		second := call(context.Background(), client, "hot", "acme")
+		waitForWaiters()
+		cancel()
+		assert.Equal(t, connect.CodeOf((<-first).err), connect.CodeCanceled)
+		close(server.release)
+		result := <-second
+		assert.Nil(t, result.err)
+		assert.Equal(t, server.Calls(), int64(1))
+	})
+	t.Run("different_servers", func(t *testing.T) {
+		t.Parallel()
+		interceptor := dedup.NewInterceptor()
+		firstServer, secondServer := newBlockingPingServer(), newBlockingPingServer()
+		first := call(context.Background(), newClientWithInterceptors(t, firstServer, interceptor), "hot", "acme")
+		second := call(context.Background(), newClientWithInterceptors(t, secondServer, interceptor), "hot", "acme")
+		// Each server gets its own call.
+		for _, server := range []*blockingPingServer{firstServer, secondServer} {
+			select {
+			case <-server.started:
+			case <-time.After(5 * time.Second):
+				t.Fatal("calls to different servers were merged")
+			}
+		}
+		close(firstServer.release)
+		close(secondServer.release)
+		assert.Nil(t, (<-first).err)
+		assert.Nil(t, (<-second).err)
+	})
+	t.Run("request_reuse", func(t *testing.T) {
+		t.Parallel()
+		server := newBlockingPingServer()
+		close(server.release)
+		// The shared call blocks in an inner interceptor until the first caller
+		// has modified its request headers.
+		proceed := make(chan struct{})
+		var sentTenant string
+		recorder := connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+			return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
+				<-proceed
+				sentTenant = request.Header().Get("Tenant")
+				return next(ctx, request)
+			}
+		})
+		client := newClientWithInterceptors(t, server, dedup.NewInterceptor(dedup.WithHeaders("Tenant")), recorder)
+		ctx, cancel := context.WithCancel(context.Background())
+		request := connect.NewRequest(&pingv1.PingRequest{Text: "hot"})
+		request.Header().Set("Tenant", "acme")
+		first := make(chan error, 1)
+		go func() {
+			_, err := client.Ping(ctx, request)
+			first <- err
+		}()
+		waitForWaiters()
+		second := call(context.Background(), client, "hot", "acme")
+		waitForWaiters()
+		cancel()
+		assert.Equal(t, connect.CodeOf(<-first), connect.CodeCanceled)
+		request.Header().Set("Tenant", "globex")
+		close(proceed)
+		result := <-second
+		assert.Nil(t, result.err)
+		assert.Equal(t, result.response.Msg.Text, "hot")
+		assert.Equal(t, sentTenant, "acme")
+	})
+	t.Run("authorization", func(t *testing.T) {
+		t.Parallel()
+		server := newBlockingPingServer()
+		client := newClientWithInterceptors(t, server, dedup.NewInterceptor())
+		authorized := func(authorization string) <-chan result {
+			results := make(chan result, 1)
+			go func() {
+				request := connect.NewRequest(&pingv1.PingRequest{Text: "hot"})
+				request.Header().Set("Authorization", authorization)
+				response, err := client.Ping(context.Background(), request)
+				results <- result{response: response, err: err}
+			}()
+			return results
+		}
+		alice, bob := authorized("Bearer alice"), authorized("Bearer bob")
+		// Calls with different credentials are never merged.
+		for i := 0; i < 2; i++ {
+			select {
+			case <-server.started:
+			case <-time.After(5 * time.Second):
+				t.Fatal("calls with different credentials were merged")
+			}
+		}
+		close(server.release)
+		assert.Nil(t, (<-alice).err)
+		assert.Nil(t, (<-bob).err)
+	})
+	t.Run("panic", func(t *testing.T) {
+		t.Parallel()
+		server := newBlockingPingServer()
+		close(server.release)
+		proceed := make(chan struct{})
+		panicker := connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+			return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
+				<-proceed
+				panic("boom")
+			}
+		})
+		client := newClientWithInterceptors(t, server, dedup.NewInterceptor(dedup.WithHeaders("Tenant")), panicker)
+		first := call(context.Background(), client, "hot", "acme")
+		second := call(context.Background(), client, "hot", "acme")
+		waitForWaiters()
+		close(proceed)
+		// Every waiter gets an error instead of blocking forever.
+		for _, results := range []<-chan result{first, second} {
+			select {
+			case result := <-results:
+				assert.Equal(t, connect.CodeOf(result.err), connect.CodeInternal)
+			case <-time.After(5 * time.Second):
+				t.Fatal("waiter blocked after the shared call panicked")
+			}
+		}
+	})
+	t.Run("cancel_all", func(t *testing.T) {
+		t.Parallel()
+		server := newBlockingPingServer()
+		client := newClient(t, server)
+		ctx, cancel := context.WithCancel(context.Background())
+		first := call(ctx, client, "hot", "acme")
+		<-server.started
+		cancel()
+		assert.Equal(t, connect.CodeOf((<-first).err), connect.CodeCanceled)
+		// With no waiters left, the shared call is canceled too.
+		select {
+		case <-server.canceled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("shared call wasn't canceled")
+		}
+	})
+}
@@ -0,0 +1,38 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dedup
+
+// An Option configures the interceptor returned by NewInterceptor.
+type Option interface {
+	apply(*interceptor)
+}
+
+// WithHeaders configures the interceptor to include the values of the named
+// request headers when deciding whether calls are identical. Use it for
+// headers that change the response, like cookies or locale.
+//
+// By default, only the Authorization header is included, so calls made with
+// different credentials are never merged.
+func WithHeaders(names ...string) Option {
+	return &headersOption{names: names}
+}
+
+type headersOption struct {
+	names []string
+}
+
+func (o *headersOption) apply(i *interceptor) {
+	i.headers = append(i.headers, o.names...)
+}
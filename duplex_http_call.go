@@ -33,6 +33,7 @@ type duplexHTTPCall struct {
 	httpClient       HTTPClient
 	streamType       StreamType
 	validateResponse func(*http.Response) *Error
+	stats            *streamStats
 
 	// We'll use a pipe as the request body. We hand the read side of the pipe to
 	// net/http, and we write to the write side (naturally). The two ends are
@@ -55,6 +56,7 @@ func newDuplexHTTPCall(
 	url string,
 	spec Spec,
 	header http.Header,
+	stats *streamStats,
 ) *duplexHTTPCall {
 	pipeReader, pipeWriter := io.Pipe()
 	request, err := http.NewRequestWithContext(
@@ -68,6 +70,7 @@ func newDuplexHTTPCall(
 		ctx:               ctx,
 		httpClient:        httpClient,
 		streamType:        spec.StreamType,
+		stats:             stats,
 		requestBodyReader: pipeReader,
 		requestBodyWriter: pipeWriter,
 		request:           request,
@@ -241,6 +244,7 @@ func (d *duplexHTTPCall) makeRequest() {
 
 	// Once we send a message to the server, they send a message back and
 	// establish the receive side of the stream.
+	d.stats.OutHeader(d.request.Header)
 	response, err := d.httpClient.Do(d.request)
 	if err != nil {
 		err = wrapIfContextError(err)
@@ -254,6 +258,7 @@ func (d *duplexHTTPCall) makeRequest() {
 		return
 	}
 	d.response = response
+	d.stats.InHeader(response.Header)
 	if err := d.validateResponse(response); err != nil {
 		d.SetError(err)
 		return
@@ -54,24 +54,35 @@ type envelopeWriter struct {
 	compressMinBytes int
 	compressionPool  *compressionPool
 	bufferPool       *bufferPool
+	stats            *streamStats
 }
 
 func (w *envelopeWriter) Marshal(message any) *Error {
-	raw, err := w.codec.Marshal(message)
+	buffer, err := marshalToBuffer(w.codec, w.bufferPool, message)
 	if err != nil {
 		return errorf(CodeInternal, "marshal message: %w", err)
 	}
-	// We can't avoid allocating the byte slice, so we may as well reuse it once
-	// we're done with it.
-	buffer := bytes.NewBuffer(raw)
 	defer w.bufferPool.Put(buffer)
+	uncompressedBytes := buffer.Len()
 	envelope := &envelope{Data: buffer}
-	return w.Write(envelope)
+	wireBytes, writeErr := w.compressAndWrite(envelope)
+	if writeErr != nil {
+		return writeErr
+	}
+	w.stats.Sent(message, wireBytes, uncompressedBytes)
+	return nil
 }
 
 // Write writes the enveloped message, compressing as necessary. It doesn't
 // retain any references to the supplied envelope or its underlying data.
 func (w *envelopeWriter) Write(env *envelope) *Error {
+	_, err := w.compressAndWrite(env)
+	return err
+}
+
+// compressAndWrite implements Write, and also returns the number of bytes
+// written to the network.
+func (w *envelopeWriter) compressAndWrite(env *envelope) (int, *Error) {
 	if env.IsSet(flagEnvelopeCompressed) ||
 		w.compressionPool == nil ||
 		env.Data.Len() < w.compressMinBytes {
@@ -80,7 +91,7 @@ func (w *envelopeWriter) Write(env *envelope) *Error {
 	data := w.bufferPool.Get()
 	defer w.bufferPool.Put(data)
 	if err := w.compressionPool.Compress(data, env.Data); err != nil {
-		return err
+		return 0, err
 	}
 	return w.write(&envelope{
 		Data:  data,
@@ -88,20 +99,21 @@ func (w *envelopeWriter) Write(env *envelope) *Error {
 	})
 }
 
-func (w *envelopeWriter) write(env *envelope) *Error {
+func (w *envelopeWriter) write(env *envelope) (int, *Error) {
+	size := 5 + env.Data.Len()
 	prefix := [5]byte{}
 	prefix[0] = env.Flags
 	binary.BigEndian.PutUint32(prefix[1:5], uint32(env.Data.Len()))
 	if _, err := w.writer.Write(prefix[:]); err != nil {
 		if connectErr, ok := asError(err); ok {
-			return connectErr
+			return 0, connectErr
 		}
-		return errorf(CodeUnknown, "write envelope: %w", err)
+		return 0, errorf(CodeUnknown, "write envelope: %w", err)
 	}
 	if _, err := io.Copy(w.writer, env.Data); err != nil {
-		return errorf(CodeUnknown, "write message: %w", err)
+		return 0, errorf(CodeUnknown, "write message: %w", err)
 	}
-	return nil
+	return size, nil
 }
 
 type envelopeReader struct {
@@ -111,6 +123,7 @@ type envelopeReader struct {
 	compressionPool *compressionPool
 	bufferPool      *bufferPool
 	readMaxBytes    int
+	stats           *streamStats
 }
 
 func (r *envelopeReader) Unmarshal(message any) *Error {
@@ -125,6 +138,7 @@ func (r *envelopeReader) Unmarshal(message any) *Error {
 		env.Data.Len() == 0:
 		// This is a standard message (because none of the top 7 bits are set) and
 		// there's no data, so the zero value of the message is correct.
+		r.stats.Received(message, 5, 0)
 		return nil
 	case err != nil && errors.Is(err, io.EOF):
 		// The stream has ended. Propagate the EOF to the caller.
@@ -135,6 +149,7 @@ func (r *envelopeReader) Unmarshal(message any) *Error {
 	}
 
 	data := env.Data
+	wireBytes := 5 + data.Len()
 	if data.Len() > 0 && env.IsSet(flagEnvelopeCompressed) {
 		if r.compressionPool == nil {
 			return errorf(
@@ -170,6 +185,7 @@ func (r *envelopeReader) Unmarshal(message any) *Error {
 	if err := r.codec.Unmarshal(data.Bytes(), message); err != nil {
 		return errorf(CodeInvalidArgument, "unmarshal into %T: %w", message, err)
 	}
+	r.stats.Received(message, wireBytes, data.Len())
 	return nil
 }
 
@@ -0,0 +1,131 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package errdetail provides constructors for the standard error details
+// defined in google/rpc/error_details.proto. Attach them to a *connect.Error
+// with Add:
+//
+//   err := connect.NewError(connect.CodeInvalidArgument, errors.New("invalid name"))
+//   errdetail.Add(err, errdetail.BadRequest(
+//     errdetail.FieldViolation("name", "must not be empty"),
+//   ))
+//
+// To read them back, use connect.AsErrorDetail:
+//
+//   if badRequest, ok := connect.AsErrorDetail[errdetails.BadRequest](err); ok {
+//     // use badRequest.FieldViolations
+//   }
+//
+// The generated types live in google.golang.org/genproto, so errdetail is a
+// separate module. Programs that don't use it don't depend on genproto.
+package errdetail
+
+import (
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Add attaches each message to the *Error as a detail. It returns an error,
+// leaving the *Error unchanged, if any of the messages can't be marshaled.
+func Add(err *connect.Error, messages ...proto.Message) error {
+	details := make([]connect.ErrorDetail, 0, len(messages))
+	for _, message := range messages {
+		detail, marshalErr := connect.NewErrorDetail(message)
+		if marshalErr != nil {
+			return marshalErr
+		}
+		details = append(details, detail)
+	}
+	for _, detail := range details {
+		err.AddDetail(detail)
+	}
+	return nil
+}
+
+// BadRequest describes violations in a client request. It's typically
+// attached to errors with connect.CodeInvalidArgument.
+func BadRequest(violations ...*errdetails.BadRequest_FieldViolation) *errdetails.BadRequest {
+	return &errdetails.BadRequest{FieldViolations: violations}
+}
+
+// FieldViolation describes a single bad request field. The field is a path to
+// the offending field, like "address.zip_code".
+func FieldViolation(field, description string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: description,
+	}
+}
+
+// RetryInfo tells the client how long to wait before retrying the request.
+func RetryInfo(delay time.Duration) *errdetails.RetryInfo {
+	return &errdetails.RetryInfo{RetryDelay: durationpb.New(delay)}
+}
+
+// QuotaFailure describes how a quota check failed. It's typically attached to
+// errors with connect.CodeResourceExhausted.
+func QuotaFailure(violations ...*errdetails.QuotaFailure_Violation) *errdetails.QuotaFailure {
+	return &errdetails.QuotaFailure{Violations: violations}
+}
+
+// QuotaViolation describes a single quota violation. The subject identifies
+// the quota that was exceeded, like "clientip:203.0.113.7".
+func QuotaViolation(subject, description string) *errdetails.QuotaFailure_Violation {
+	return &errdetails.QuotaFailure_Violation{
+		Subject:     subject,
+		Description: description,
+	}
+}
+
+// ErrorInfo describes the cause of the error with structured details. The
+// reason is a constant, UPPER_SNAKE_CASE identifier, and the domain is the
+// logical grouping the reason belongs to, typically a service name.
+func ErrorInfo(reason, domain string, metadata map[string]string) *errdetails.ErrorInfo {
+	return &errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   domain,
+		Metadata: metadata,
+	}
+}
+
+// DebugInfo describes additional debugging information. Debugging
+// information is rarely appropriate for public APIs, so take care not to leak
+// implementation details to untrusted clients.
+func DebugInfo(detail string) *errdetails.DebugInfo {
+	return &errdetails.DebugInfo{Detail: detail}
+}
+
+// DebugInfoWithStack is like DebugInfo, but it also includes the stack trace
+// of the calling goroutine. Stack traces reveal the server's code, so only
+// send them to trusted clients.
+func DebugInfoWithStack(detail string) *errdetails.DebugInfo {
+	info := DebugInfo(detail)
+	info.StackEntries = strings.Split(strings.TrimSpace(string(debug.Stack())), "\n")
+	return info
+}
+
+// LocalizedMessage provides an error message that's safe to return to the
+// end user. The locale follows the IETF BCP-47 specification, like "en-US".
+func LocalizedMessage(locale, message string) *errdetails.LocalizedMessage {
+	return &errdetails.LocalizedMessage{
+		Locale:  locale,
+		Message: message,
+	}
+}
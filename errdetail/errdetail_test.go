@@ -0,0 +1,101 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errdetail_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/bufbuild/connect-go/errdetail"
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+type detailPingServer struct {
+	pingv1connect.UnimplementedPingServiceHandler
+}
+
+func (detailPingServer) Ping(context.Context, *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+	err := connect.NewError(connect.CodeInvalidArgument, errors.New("invalid number"))
+	if detailErr := errdetail.Add(
+		err,
+		errdetail.BadRequest(errdetail.FieldViolation("number", "must be positive")),
+		errdetail.RetryInfo(time.Second),
+		errdetail.LocalizedMessage("en-US", "Please pick a positive number."),
+	); detailErr != nil {
+		return nil, detailErr
+	}
+	return nil, err
+}
+
+func TestErrorDetails(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(detailPingServer{}))
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	assertDetails := func(tb testing.TB, err error) {
+		tb.Helper()
+		assert.Equal(tb, connect.CodeOf(err), connect.CodeInvalidArgument)
+		badRequest, ok := connect.AsErrorDetail[errdetails.BadRequest](err)
+		if assert.True(tb, ok) && assert.Equal(tb, len(badRequest.FieldViolations), 1) {
+			assert.Equal(tb, badRequest.FieldViolations[0].Field, "number")
+			assert.Equal(tb, badRequest.FieldViolations[0].Description, "must be positive")
+		}
+		retryInfo, ok := connect.AsErrorDetail[errdetails.RetryInfo](err)
+		if assert.True(tb, ok) {
+			assert.Equal(tb, retryInfo.RetryDelay.AsDuration(), time.Second)
+		}
+		message, ok := connect.AsErrorDetail[errdetails.LocalizedMessage](err)
+		if assert.True(tb, ok) {
+			assert.Equal(tb, message.Locale, "en-US")
+		}
+		_, ok = connect.AsErrorDetail[errdetails.QuotaFailure](err)
+		assert.False(tb, ok)
+	}
+	t.Run("connect", func(t *testing.T) {
+		t.Parallel()
+		client := pingv1connect.NewPingServiceClient(server.Client(), server.URL)
+		_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+		assertDetails(t, err)
+	})
+	t.Run("grpc", func(t *testing.T) {
+		t.Parallel()
+		client := pingv1connect.NewPingServiceClient(server.Client(), server.URL, connect.WithGRPC())
+		_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+		assertDetails(t, err)
+	})
+}
+
+func TestDebugInfo(t *testing.T) {
+	t.Parallel()
+	info := errdetail.DebugInfo("something broke")
+	assert.Equal(t, info.Detail, "something broke")
+	// Stack traces are only captured when asked for.
+	assert.Zero(t, len(info.StackEntries))
+	info = errdetail.DebugInfoWithStack("something broke")
+	assert.Equal(t, info.Detail, "something broke")
+	assert.NotZero(t, len(info.StackEntries))
+}
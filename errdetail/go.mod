@@ -0,0 +1,13 @@
+module github.com/bufbuild/connect-go/errdetail
+
+go 1.18
+
+require (
+	github.com/bufbuild/connect-go v0.0.0-00010101000000-000000000000
+	google.golang.org/genproto v0.0.0-20220722212130-b98a9ff5e252
+	google.golang.org/protobuf v1.28.0
+)
+
+require github.com/google/go-cmp v0.5.8 // indirect
+
+replace github.com/bufbuild/connect-go => ../
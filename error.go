@@ -45,6 +45,46 @@ type ErrorDetail interface {
 	UnmarshalTo(proto.Message) error
 }
 
+// NewErrorDetail wraps a Protobuf message in an *anypb.Any, so that it can be
+// attached to an *Error with AddDetail. It returns an error if the message
+// can't be marshaled.
+func NewErrorDetail(message proto.Message) (ErrorDetail, error) {
+	detail, err := anypb.New(message)
+	if err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// AsErrorDetail finds the first detail of type T attached to a wrapped
+// *Error. It returns false if err doesn't wrap an *Error, or if the *Error
+// doesn't have any details of type T. For example:
+//
+//   if badRequest, ok := connect.AsErrorDetail[errdetails.BadRequest](err); ok {
+//     // use badRequest.FieldViolations
+//   }
+func AsErrorDetail[T any, PT interface {
+	*T
+	proto.Message
+}](err error) (PT, bool) {
+	connectErr, ok := asError(err)
+	if !ok {
+		return nil, false
+	}
+	var msg PT = new(T)
+	name := msg.ProtoReflect().Descriptor().FullName()
+	for _, detail := range connectErr.details {
+		if detail.MessageName() != name {
+			continue
+		}
+		if err := detail.UnmarshalTo(msg); err != nil {
+			continue
+		}
+		return msg, true
+	}
+	return nil, false
+}
+
 // An Error captures four key pieces of information: a Code, an underlying Go
 // error, a map of metadata, and an optional collection of arbitrary Protobuf
 // messages called "details" (more on those below). Servers send the code, the
@@ -129,6 +169,20 @@ func (e *Error) Meta() http.Header {
 	return e.meta
 }
 
+// clone returns a copy of the error that can be modified without affecting
+// the original, which may be shared.
+func (e *Error) clone() *Error {
+	clone := &Error{
+		code: e.code,
+		err:  e.err,
+		meta: e.meta.Clone(),
+	}
+	if len(e.details) > 0 {
+		clone.details = append([]ErrorDetail(nil), e.details...)
+	}
+	return clone
+}
+
 func (e *Error) detailsAsAny() ([]*anypb.Any, error) {
 	anys := make([]*anypb.Any, 0, len(e.details))
 	for _, detail := range e.details {
@@ -0,0 +1,182 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect
+
+import "errors"
+
+// errorClassMetaKey is the metadata key that MatchError classifiers use to tag
+// the errors they produce with the caller's ID. Clients only rebuild a
+// sentinel for errors tagged by a matching classifier, so unrelated errors
+// with the same code, or sentinels that share a code, aren't confused.
+const errorClassMetaKey = "Connect-Error-Class"
+
+// A Coder is an error that knows which Code best describes it. Handlers send
+// uncoded errors that implement Coder to clients with the reported Code,
+// rather than CodeUnknown.
+type Coder interface {
+	Code() Code
+}
+
+// An ErrorClassifier maps domain errors, like sql.ErrNoRows or
+// os.ErrPermission, to and from *Errors. Register classifiers with
+// WithErrorClassifiers.
+//
+// Most users should construct classifiers with MatchError or MatchErrorType.
+// Implement the interface directly to attach details to outgoing errors or to
+// inspect details when rebuilding domain errors.
+type ErrorClassifier interface {
+	// ClassifyError converts an uncoded error returned by a handler to an
+	// *Error. It returns nil if it doesn't recognize the error.
+	ClassifyError(err error) *Error
+	// UnclassifyError returns the domain error corresponding to an *Error
+	// received by a client. It returns nil if it doesn't recognize the error.
+	UnclassifyError(err *Error) error
+}
+
+// MatchError returns an ErrorClassifier that maps errors matching target,
+// using errors.Is, to the supplied code. Handlers tag the errors they classify
+// with the id, sent to clients in the Connect-Error-Class metadata key.
+// Clients using a classifier with the same id rebuild the sentinel only for
+// errors carrying that tag, so that errors.Is(err, target) reports true for
+// errors classified by a matching handler and false for any other error with
+// the same code.
+//
+// The id should be a short, stable identifier that's valid in an HTTP header,
+// like "acme.sql.no_rows". It's visible to every client, so it shouldn't
+// reveal implementation details. If the id is empty, handlers don't tag errors
+// and clients never rebuild the sentinel.
+func MatchError(target error, code Code, id string) ErrorClassifier {
+	return &matchErrorClassifier{target: target, code: code, id: id}
+}
+
+// MatchErrorType returns an ErrorClassifier that maps errors of type T, using
+// errors.As, to the supplied code. There's no general way to rebuild a value
+// of type T, so clients ignore classifiers returned by MatchErrorType.
+func MatchErrorType[T error](code Code) ErrorClassifier {
+	return &matchErrorTypeClassifier[T]{code: code}
+}
+
+type matchErrorClassifier struct {
+	target error
+	code   Code
+	id     string
+}
+
+func (c *matchErrorClassifier) ClassifyError(err error) *Error {
+	if !errors.Is(err, c.target) {
+		return nil
+	}
+	connectErr := NewError(c.code, err)
+	if c.id != "" {
+		connectErr.Meta().Set(errorClassMetaKey, c.id)
+	}
+	return connectErr
+}
+
+func (c *matchErrorClassifier) UnclassifyError(err *Error) error {
+	if err.Code() != c.code || c.id == "" {
+		return nil
+	}
+	for _, value := range err.meta.Values(errorClassMetaKey) {
+		if value == c.id {
+			return c.target
+		}
+	}
+	return nil
+}
+
+type matchErrorTypeClassifier[T error] struct {
+	code Code
+}
+
+func (c *matchErrorTypeClassifier[T]) ClassifyError(err error) *Error {
+	var target T
+	if errors.As(err, &target) {
+		return NewError(c.code, err)
+	}
+	return nil
+}
+
+func (c *matchErrorTypeClassifier[T]) UnclassifyError(*Error) error {
+	return nil
+}
+
+// classifiedError wraps the underlying error of an *Error received by a
+// client, so that errors.Is and errors.As also match the domain error
+// rebuilt by an ErrorClassifier.
+type classifiedError struct {
+	err    error
+	domain error
+}
+
+func (e *classifiedError) Error() string {
+	if e.err == nil {
+		return ""
+	}
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+func (e *classifiedError) Is(target error) bool {
+	return errors.Is(e.domain, target)
+}
+
+func (e *classifiedError) As(target any) bool {
+	return errors.As(e.domain, target)
+}
+
+// classifyError converts uncoded errors to *Errors, using the error's Coder
+// implementation or the first matching classifier. Errors that can't be
+// classified are returned unchanged.
+func classifyError(err error, classifiers []ErrorClassifier) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := asError(err); ok {
+		return err
+	}
+	var coder Coder
+	if errors.As(err, &coder) {
+		return NewError(coder.Code(), err)
+	}
+	for _, classifier := range classifiers {
+		if connectErr := classifier.ClassifyError(err); connectErr != nil {
+			return connectErr
+		}
+	}
+	return err
+}
+
+// unclassifyError rebuilds domain errors from *Errors received by clients,
+// using the first classifier that recognizes the error.
+func unclassifyError(err error, classifiers []ErrorClassifier) error {
+	connectErr, ok := asError(err)
+	if !ok {
+		return err
+	}
+	if _, ok := connectErr.err.(*classifiedError); ok {
+		return err // already unclassified
+	}
+	for _, classifier := range classifiers {
+		if domain := classifier.UnclassifyError(connectErr); domain != nil {
+			connectErr.err = &classifiedError{err: connectErr.err, domain: domain}
+			return err
+		}
+	}
+	return err
+}
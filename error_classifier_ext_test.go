@@ -0,0 +1,173 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connect"
+)
+
+var (
+	errNoRows = errors.New("no rows in result set")
+	errNoUser = errors.New("no such user")
+)
+
+type quotaError struct {
+	limit int
+}
+
+func (e *quotaError) Error() string {
+	return fmt.Sprintf("exceeded quota of %d", e.limit)
+}
+
+type permissionError struct{}
+
+func (permissionError) Error() string      { return "permission denied" }
+func (permissionError) Code() connect.Code { return connect.CodePermissionDenied }
+
+type domainErrorPingServer struct {
+	pingv1connect.UnimplementedPingServiceHandler
+}
+
+func (domainErrorPingServer) Ping(context.Context, *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+	return nil, fmt.Errorf("lookup ping: %w", errNoRows)
+}
+
+func (domainErrorPingServer) Fail(context.Context, *connect.Request[pingv1.FailRequest]) (*connect.Response[pingv1.FailResponse], error) {
+	return nil, permissionError{}
+}
+
+func (domainErrorPingServer) CountUp(context.Context, *connect.Request[pingv1.CountUpRequest], *connect.ServerStream[pingv1.CountUpResponse]) error {
+	return &quotaError{limit: 10}
+}
+
+func TestErrorClassifiers(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		domainErrorPingServer{},
+		connect.WithErrorClassifiers(
+			connect.MatchError(errNoRows, connect.CodeNotFound, "test.no_rows"),
+			connect.MatchErrorType[*quotaError](connect.CodeResourceExhausted),
+		),
+	))
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	testProtocol := func(t *testing.T, opts ...connect.ClientOption) {
+		t.Helper()
+		client := pingv1connect.NewPingServiceClient(
+			server.Client(),
+			server.URL,
+			append(opts, connect.WithErrorClassifiers(
+				connect.MatchError(errNoRows, connect.CodeNotFound, "test.no_rows"),
+			))...,
+		)
+		_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+		assert.Equal(t, connect.CodeOf(err), connect.CodeNotFound)
+		assert.True(t, errors.Is(err, errNoRows))
+		var connectErr *connect.Error
+		if assert.True(t, errors.As(err, &connectErr)) {
+			assert.Equal(t, connectErr.Message(), "lookup ping: no rows in result set")
+		}
+
+		_, err = client.Fail(context.Background(), connect.NewRequest(&pingv1.FailRequest{}))
+		assert.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+		assert.False(t, errors.Is(err, errNoRows))
+
+		stream, err := client.CountUp(context.Background(), connect.NewRequest(&pingv1.CountUpRequest{}))
+		assert.Nil(t, err)
+		assert.False(t, stream.Receive())
+		assert.Equal(t, connect.CodeOf(stream.Err()), connect.CodeResourceExhausted)
+		assert.Nil(t, stream.Close())
+	}
+	t.Run("connect", func(t *testing.T) {
+		t.Parallel()
+		testProtocol(t)
+	})
+	t.Run("grpc", func(t *testing.T) {
+		t.Parallel()
+		testProtocol(t, connect.WithGRPC())
+	})
+	t.Run("grpcweb", func(t *testing.T) {
+		t.Parallel()
+		testProtocol(t, connect.WithGRPCWeb())
+	})
+	t.Run("no_client_classifiers", func(t *testing.T) {
+		t.Parallel()
+		client := pingv1connect.NewPingServiceClient(server.Client(), server.URL)
+		_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+		assert.Equal(t, connect.CodeOf(err), connect.CodeNotFound)
+		assert.False(t, errors.Is(err, errNoRows))
+	})
+}
+
+func TestErrorClassifiersSharedCode(t *testing.T) {
+	t.Parallel()
+	classifiers := connect.WithErrorClassifiers(
+		connect.MatchError(errNoRows, connect.CodeNotFound, "test.no_rows"),
+		connect.MatchError(errNoUser, connect.CodeNotFound, "test.no_user"),
+	)
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.PingServicePingProcedure, connect.NewUnaryHandler(
+		pingv1connect.PingServicePingProcedure,
+		func(_ context.Context, request *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+			switch request.Msg.Number {
+			case 1:
+				return nil, fmt.Errorf("lookup ping: %w", errNoRows)
+			case 2:
+				return nil, fmt.Errorf("lookup user: %w", errNoUser)
+			default:
+				return nil, connect.NewError(connect.CodeNotFound, errors.New("no such ping"))
+			}
+		},
+		classifiers,
+	))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	for _, option := range []connect.ClientOption{connect.WithClientOptions(), connect.WithGRPCWeb()} {
+		client := pingv1connect.NewPingServiceClient(server.Client(), server.URL, option, classifiers)
+		ping := func(number int64) error {
+			_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{Number: number}))
+			assert.Equal(t, connect.CodeOf(err), connect.CodeNotFound)
+			return err
+		}
+		err := ping(1)
+		assert.True(t, errors.Is(err, errNoRows))
+		assert.False(t, errors.Is(err, errNoUser))
+		// Only the caller's ID is sent, never the sentinel's message.
+		var connectErr *connect.Error
+		if assert.True(t, errors.As(err, &connectErr)) {
+			assert.Equal(t, connectErr.Meta().Values("Connect-Error-Class"), []string{"test.no_rows"})
+		}
+		err = ping(2)
+		assert.False(t, errors.Is(err, errNoRows))
+		assert.True(t, errors.Is(err, errNoUser))
+		err = ping(3)
+		assert.False(t, errors.Is(err, errNoRows))
+		assert.False(t, errors.Is(err, errNoUser))
+	}
+}
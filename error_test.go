@@ -15,6 +15,7 @@
 package connect
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
@@ -87,6 +88,62 @@ func TestErrorDetails(t *testing.T) {
 	assert.Equal(t, connectErr.Details(), []ErrorDetail{detail})
 }
 
+func TestAsErrorDetail(t *testing.T) {
+	t.Parallel()
+	second := durationpb.New(time.Second)
+	detail, err := NewErrorDetail(second)
+	assert.Nil(t, err)
+	empty, err := NewErrorDetail(&emptypb.Empty{})
+	assert.Nil(t, err)
+	connectErr := NewError(CodeUnknown, errors.New("error with details"))
+	_, ok := AsErrorDetail[durationpb.Duration](connectErr)
+	assert.False(t, ok)
+	connectErr.AddDetail(empty)
+	connectErr.AddDetail(detail)
+	got, ok := AsErrorDetail[durationpb.Duration](fmt.Errorf("wrapped: %w", connectErr))
+	assert.True(t, ok)
+	assert.Equal(t, got.AsDuration(), time.Second)
+	_, ok = AsErrorDetail[durationpb.Duration](errors.New("not a connect error"))
+	assert.False(t, ok)
+}
+
+func TestConnectWireErrorDetails(t *testing.T) {
+	t.Parallel()
+	detail, err := NewErrorDetail(durationpb.New(time.Second))
+	assert.Nil(t, err)
+	connectErr := NewError(CodeUnavailable, errors.New("try again later"))
+	connectErr.AddDetail(detail)
+	data, err := json.Marshal((*connectWireError)(connectErr))
+	assert.Nil(t, err)
+	// Details are Protobuf JSON Any values, so known types are readable on the
+	// wire and peers running older versions can still decode them.
+	var raw struct {
+		Details []map[string]string `json:"details"`
+	}
+	assert.Nil(t, json.Unmarshal(data, &raw))
+	assert.Equal(t, raw.Details, []map[string]string{{
+		"@type": "type.googleapis.com/google.protobuf.Duration",
+		"value": "1s",
+	}})
+
+	var roundTripped connectWireError
+	assert.Nil(t, json.Unmarshal(data, &roundTripped))
+	assert.Equal(t, roundTripped.code, CodeUnavailable)
+	got, ok := AsErrorDetail[durationpb.Duration]((*Error)(&roundTripped))
+	assert.True(t, ok)
+	assert.Equal(t, got.AsDuration(), time.Second)
+
+	// Errors written by earlier versions must still decode.
+	const earlier = `{"code":"unavailable","message":"try again later",` +
+		`"details":[{"@type":"type.googleapis.com/google.protobuf.Duration","value":"2s"}]}`
+	var decoded connectWireError
+	assert.Nil(t, json.Unmarshal([]byte(earlier), &decoded))
+	assert.Equal(t, decoded.code, CodeUnavailable)
+	got, ok = AsErrorDetail[durationpb.Duration]((*Error)(&decoded))
+	assert.True(t, ok)
+	assert.Equal(t, got.AsDuration(), 2*time.Second)
+}
+
 func TestErrorIs(t *testing.T) {
 	t.Parallel()
 	// errors.New and fmt.Errorf return *errors.errorString. errors.Is
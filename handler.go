@@ -55,15 +55,11 @@ func NewUnaryHandler[Req, Res any](
 	}
 	// Given a stream, how should we call the unary function?
 	implementation := func(ctx context.Context, conn StreamingHandlerConn) error {
-		var msg Req
-		if err := conn.Receive(&msg); err != nil {
+		request, err := receiveUnaryRequest[Req](conn)
+		if err != nil {
 			return err
 		}
-		request := &Request[Req]{
-			Msg:    &msg,
-			spec:   conn.Spec(),
-			header: conn.RequestHeader(),
-		}
+		request.stats = statsSourceFromContext(ctx)
 		response, err := untyped(ctx, request)
 		if err != nil {
 			return err
@@ -115,19 +111,12 @@ func NewServerStreamHandler[Req, Res any](
 		procedure,
 		StreamTypeServer,
 		func(ctx context.Context, conn StreamingHandlerConn) error {
-			var msg Req
-			if err := conn.Receive(&msg); err != nil {
+			request, err := receiveUnaryRequest[Req](conn)
+			if err != nil {
 				return err
 			}
-			return implementation(
-				ctx,
-				&Request[Req]{
-					Msg:    &msg,
-					spec:   conn.Spec(),
-					header: conn.RequestHeader(),
-				},
-				&ServerStream[Res]{conn: conn},
-			)
+			request.stats = statsSourceFromContext(ctx)
+			return implementation(ctx, request, &ServerStream[Res]{conn: conn})
 		},
 		options...,
 	)
@@ -173,13 +162,7 @@ func (h *Handler) ServeHTTP(responseWriter http.ResponseWriter, request *http.Re
 
 	// Find our implementation of the RPC protocol in use.
 	contentType := request.Header.Get("Content-Type")
-	var protocolHandler protocolHandler
-	for _, handler := range h.protocolHandlers {
-		if _, ok := handler.ContentTypes()[contentType]; ok {
-			protocolHandler = handler
-			break
-		}
-	}
+	protocolHandler := findProtocolHandler(h.protocolHandlers, contentType)
 	if protocolHandler == nil {
 		responseWriter.Header().Set("Accept-Post", h.acceptPost)
 		responseWriter.WriteHeader(http.StatusUnsupportedMediaType)
@@ -194,6 +177,9 @@ func (h *Handler) ServeHTTP(responseWriter http.ResponseWriter, request *http.Re
 	if cancel != nil {
 		defer cancel()
 	}
+	// Attach the RPC's Stats to the context before the conn is created, so
+	// that interceptors can read them.
+	ctx, _ = withStatsSource(ctx)
 	connCloser, ok := protocolHandler.NewConn(
 		responseWriter,
 		request.WithContext(ctx),
@@ -217,10 +203,14 @@ type handlerConfig struct {
 	CompressMinBytes int
 	Interceptor      Interceptor
 	Procedure        string
+	Schema           any
 	HandleGRPC       bool
 	HandleGRPCWeb    bool
 	BufferPool       *bufferPool
 	ReadMaxBytes     int
+	ErrorClassifiers []ErrorClassifier
+	RedactError      func(error) error
+	StatsHandler     StatsHandler
 }
 
 func newHandlerConfig(procedure string, options []HandlerOption) *handlerConfig {
@@ -245,6 +235,7 @@ func newHandlerConfig(procedure string, options []HandlerOption) *handlerConfig
 func (c *handlerConfig) newSpec(streamType StreamType) Spec {
 	return Spec{
 		Procedure:  c.Procedure,
+		Schema:     c.Schema,
 		StreamType: streamType,
 	}
 }
@@ -271,6 +262,9 @@ func (c *handlerConfig) newProtocolHandlers(streamType StreamType) []protocolHan
 			CompressMinBytes: c.CompressMinBytes,
 			BufferPool:       c.BufferPool,
 			ReadMaxBytes:     c.ReadMaxBytes,
+			ErrorClassifiers: c.ErrorClassifiers,
+			RedactError:      c.RedactError,
+			StatsHandler:     c.StatsHandler,
 		}))
 	}
 	return handlers
@@ -17,6 +17,7 @@ package connect_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -90,6 +91,73 @@ func TestHandler_ServeHTTP(t *testing.T) {
 	})
 }
 
+func TestWithErrorRedactor(t *testing.T) {
+	t.Parallel()
+	redact := func(err error) error {
+		var connectErr *connect.Error
+		if !errors.As(err, &connectErr) {
+			return connect.NewError(connect.CodeInternal, errors.New("internal error"))
+		}
+		// Keep the code and message, but drop any details.
+		return connect.NewError(connectErr.Code(), errors.New(connectErr.Message()))
+	}
+	originals := make(chan error, 8)
+	observer := connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
+			response, err := next(ctx, request)
+			originals <- err
+			return response, err
+		}
+	})
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		leakyPingServer{},
+		connect.WithErrorRedactor(redact),
+		connect.WithInterceptors(observer),
+	))
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	testProtocol := func(t *testing.T, opts ...connect.ClientOption) {
+		t.Helper()
+		client := pingv1connect.NewPingServiceClient(server.Client(), server.URL, opts...)
+		_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+		var connectErr *connect.Error
+		assert.True(t, errors.As(err, &connectErr))
+		assert.Equal(t, connectErr.Code(), connect.CodeInternal)
+		assert.Equal(t, connectErr.Message(), "internal error")
+		assert.False(t, strings.Contains(connectErr.Error(), "hunter2"))
+		assert.Equal(t, (<-originals).Error(), leakyError)
+
+		_, err = client.Fail(context.Background(), connect.NewRequest(&pingv1.FailRequest{}))
+		assert.True(t, errors.As(err, &connectErr))
+		assert.Equal(t, connectErr.Code(), connect.CodeResourceExhausted)
+		assert.Equal(t, connectErr.Message(), "too many requests")
+		assert.Zero(t, connectErr.Details())
+		var originalErr *connect.Error
+		assert.True(t, errors.As(<-originals, &originalErr))
+		assert.Equal(t, len(originalErr.Details()), 1)
+
+		stream, err := client.CountUp(context.Background(), connect.NewRequest(&pingv1.CountUpRequest{}))
+		assert.Nil(t, err)
+		assert.False(t, stream.Receive())
+		assert.Equal(t, connect.CodeOf(stream.Err()), connect.CodeInternal)
+		assert.False(t, strings.Contains(stream.Err().Error(), "hunter2"))
+		assert.Nil(t, stream.Close())
+	}
+	t.Run("connect", func(t *testing.T) {
+		testProtocol(t)
+	})
+	t.Run("grpc", func(t *testing.T) {
+		testProtocol(t, connect.WithGRPC())
+	})
+	t.Run("grpcweb", func(t *testing.T) {
+		testProtocol(t, connect.WithGRPCWeb())
+	})
+}
+
 type successPingServer struct {
 	pingv1connect.UnimplementedPingServiceHandler
 }
@@ -97,3 +165,66 @@ type successPingServer struct {
 func (successPingServer) Ping(context.Context, *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
 	return &connect.Response[pingv1.PingResponse]{}, nil
 }
+
+const leakyError = "query failed: password=hunter2"
+
+type leakyPingServer struct {
+	pingv1connect.UnimplementedPingServiceHandler
+}
+
+func (leakyPingServer) Ping(context.Context, *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+	return nil, errors.New(leakyError)
+}
+
+func (leakyPingServer) Fail(context.Context, *connect.Request[pingv1.FailRequest]) (*connect.Response[pingv1.FailResponse], error) {
+	err := connect.NewError(connect.CodeResourceExhausted, errors.New("too many requests"))
+	detail, detailErr := connect.NewErrorDetail(&pingv1.FailRequest{})
+	if detailErr != nil {
+		return nil, detailErr
+	}
+	err.AddDetail(detail)
+	return nil, err
+}
+
+func (leakyPingServer) CountUp(context.Context, *connect.Request[pingv1.CountUpRequest], *connect.ServerStream[pingv1.CountUpResponse]) error {
+	return errors.New(leakyError)
+}
+
+func TestWithProcedureOptions(t *testing.T) {
+	t.Parallel()
+	const (
+		pingProcedure = "/connect.ping.v1.PingService/Ping"
+		failProcedure = "/connect.ping.v1.PingService/Fail"
+	)
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		pingServer{},
+		connect.WithReadMaxBytes(1024),
+		connect.WithProcedureHandlerOptions(pingProcedure, connect.WithReadMaxBytes(8)),
+	))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	intercepted := make(map[string]int)
+	counter := connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
+			intercepted[request.Spec().Procedure]++
+			return next(ctx, request)
+		}
+	})
+	client := pingv1connect.NewPingServiceClient(
+		server.Client(),
+		server.URL,
+		// Procedures may omit the leading slash.
+		connect.WithProcedureOptions(strings.TrimPrefix(failProcedure, "/"), connect.WithInterceptors(counter)),
+	)
+	text := strings.Repeat("a", 64)
+	_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{Text: text}))
+	assert.Equal(t, connect.CodeOf(err), connect.CodeInvalidArgument)
+	_, err = client.Fail(context.Background(), connect.NewRequest(&pingv1.FailRequest{
+		Code: int32(connect.CodeUnavailable),
+	}))
+	assert.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+	_, err = client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{Number: 1}))
+	assert.Nil(t, err)
+	assert.Equal(t, intercepted, map[string]int{failProcedure: 1})
+}
@@ -0,0 +1,392 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// NewInMemoryStream creates a connected pair of in-memory conns, so client
+// interceptors can short-circuit streaming RPCs: rather than calling the next
+// StreamingClientFunc, an interceptor can return the InMemoryClientConn and
+// serve cached data, mocks, or fallbacks from the InMemoryHandlerConn. Nothing
+// is sent over the network.
+//
+// The conns behave like their network-backed counterparts. Request headers
+// are sent with the first call to Send or CloseRequest, response headers are
+// sent with the first call to the handler's Send or Close, and trailers are
+// sent when the handler calls Close. Messages are copied as they pass from
+// one side to the other, so both sides may reuse their messages after Send
+// returns. Both conns support the concurrent use documented on
+// StreamingClientConn and StreamingHandlerConn.
+//
+// The supplied Spec describes the client's view of the RPC; the handler's Spec
+// is the same, but with IsClient set to false. Both conns report an empty
+// Peer. The client's message counts are reported by StatsFromContext, just
+// like those of network-backed conns.
+func NewInMemoryStream(ctx context.Context, spec Spec) (*InMemoryClientConn, *InMemoryHandlerConn) {
+	pipe := &inMemoryPipe{
+		ctx:                 ctx,
+		requests:            make(chan any),
+		requestHeaderSent:   make(chan struct{}),
+		requestClosed:       make(chan struct{}),
+		responses:           make(chan any),
+		responseHeaderSent:  make(chan struct{}),
+		responseClosed:      make(chan struct{}),
+		clientClosed:        make(chan struct{}),
+		requestHeader:       make(http.Header),
+		responseHeader:      make(http.Header),
+		responseTrailer:     make(http.Header),
+		sentRequestHeader:   make(http.Header),
+		sentResponseHeader:  make(http.Header),
+		sentResponseTrailer: make(http.Header),
+	}
+	clientSpec := spec
+	clientSpec.IsClient = true
+	handlerSpec := spec
+	handlerSpec.IsClient = false
+	client := &InMemoryClientConn{
+		pipe: pipe,
+		spec: clientSpec,
+		// Like network-backed conns, the client's stats are the RPC's stats.
+		stats: newStreamStats(ctx, nil /* handler */, true /* client */),
+	}
+	handler := &InMemoryHandlerConn{
+		pipe:  pipe,
+		spec:  handlerSpec,
+		stats: &streamStats{},
+	}
+	return client, handler
+}
+
+// InMemoryClientConn is the client side of an in-memory stream created with
+// NewInMemoryStream. It implements StreamingClientConn.
+type InMemoryClientConn struct {
+	pipe  *inMemoryPipe
+	spec  Spec
+	stats *streamStats
+}
+
+var _ StreamingClientConn = (*InMemoryClientConn)(nil)
+
+// Spec returns the specification for the RPC.
+func (cc *InMemoryClientConn) Spec() Spec {
+	return cc.spec
+}
+
+// Peer returns an empty Peer.
+func (cc *InMemoryClientConn) Peer() Peer {
+	return Peer{}
+}
+
+// Stats reports the number of messages exchanged so far. Since messages never
+// leave memory, byte counts are always zero.
+func (cc *InMemoryClientConn) Stats() Stats {
+	return cc.stats.Snapshot()
+}
+
+// Send copies the message to the handler, blocking until the handler receives
+// it. If the handler has already closed the stream, Send returns an error
+// wrapping io.EOF; call Receive to retrieve the handler's error.
+func (cc *InMemoryClientConn) Send(msg any) error {
+	cc.pipe.sendRequestHeader()
+	clone, err := cloneMessage(msg)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-cc.pipe.requestClosed:
+		return errorf(CodeInternal, "send on closed request")
+	default:
+	}
+	select {
+	case cc.pipe.requests <- clone:
+		cc.stats.Sent(msg, 0, 0)
+		return nil
+	case <-cc.pipe.responseClosed:
+		return NewError(CodeUnknown, io.EOF)
+	case <-cc.pipe.clientClosed:
+		return NewError(CodeUnknown, io.EOF)
+	case <-cc.pipe.ctx.Done():
+		return wrapIfContextError(cc.pipe.ctx.Err())
+	}
+}
+
+// RequestHeader returns the request headers. Headers are sent to the handler
+// with the first call to Send or CloseRequest, so subsequent mutations have no
+// effect.
+func (cc *InMemoryClientConn) RequestHeader() http.Header {
+	return cc.pipe.requestHeader
+}
+
+// CloseRequest signals to the handler that the client is done sending
+// messages.
+func (cc *InMemoryClientConn) CloseRequest() error {
+	cc.pipe.sendRequestHeader()
+	cc.pipe.closeRequestOnce.Do(func() {
+		close(cc.pipe.requestClosed)
+	})
+	return nil
+}
+
+// Receive copies the next message from the handler into msg, blocking until
+// one is available. After the handler closes the stream, Receive returns the
+// handler's error or, if the handler closed the stream successfully, an error
+// wrapping io.EOF.
+func (cc *InMemoryClientConn) Receive(msg any) error {
+	select {
+	case response := <-cc.pipe.responses:
+		if err := assignMessage(msg, response); err != nil {
+			return err
+		}
+		cc.stats.Received(msg, 0, 0)
+		return nil
+	case <-cc.pipe.responseClosed:
+		if err := cc.pipe.responseErr; err != nil {
+			return err
+		}
+		return NewError(CodeUnknown, io.EOF)
+	case <-cc.pipe.clientClosed:
+		return errorf(CodeCanceled, "receive on closed response")
+	case <-cc.pipe.ctx.Done():
+		return wrapIfContextError(cc.pipe.ctx.Err())
+	}
+}
+
+// ResponseHeader returns the headers sent by the handler, blocking until
+// they're available.
+func (cc *InMemoryClientConn) ResponseHeader() http.Header {
+	select {
+	case <-cc.pipe.responseHeaderSent:
+		return cc.pipe.sentResponseHeader
+	case <-cc.pipe.clientClosed:
+	case <-cc.pipe.ctx.Done():
+	}
+	return make(http.Header)
+}
+
+// ResponseTrailer returns the trailers sent by the handler. Like HTTP
+// trailers, they're only available after Receive returns an error.
+func (cc *InMemoryClientConn) ResponseTrailer() http.Header {
+	select {
+	case <-cc.pipe.responseClosed:
+		return cc.pipe.sentResponseTrailer
+	default:
+		return make(http.Header)
+	}
+}
+
+// CloseResponse signals to the handler that the client is done receiving
+// messages. Subsequent calls to the handler's Send and Receive methods return
+// errors.
+func (cc *InMemoryClientConn) CloseResponse() error {
+	cc.pipe.closeClientOnce.Do(func() {
+		close(cc.pipe.clientClosed)
+	})
+	return nil
+}
+
+// InMemoryHandlerConn is the handler side of an in-memory stream created with
+// NewInMemoryStream. It implements StreamingHandlerConn, so it can be passed
+// to a StreamingHandlerFunc. Handlers must call Close when they're done.
+type InMemoryHandlerConn struct {
+	pipe  *inMemoryPipe
+	spec  Spec
+	stats *streamStats
+}
+
+var _ StreamingHandlerConn = (*InMemoryHandlerConn)(nil)
+
+// Spec returns the specification for the RPC.
+func (hc *InMemoryHandlerConn) Spec() Spec {
+	return hc.spec
+}
+
+// Peer returns an empty Peer.
+func (hc *InMemoryHandlerConn) Peer() Peer {
+	return Peer{}
+}
+
+// Stats reports the number of messages exchanged so far. Since messages never
+// leave memory, byte counts are always zero.
+func (hc *InMemoryHandlerConn) Stats() Stats {
+	return hc.stats.Snapshot()
+}
+
+// Receive copies the next message from the client into msg, blocking until
+// one is available. After the client calls CloseRequest, Receive returns an
+// error wrapping io.EOF.
+func (hc *InMemoryHandlerConn) Receive(msg any) error {
+	select {
+	case request := <-hc.pipe.requests:
+		if err := assignMessage(msg, request); err != nil {
+			return err
+		}
+		hc.stats.Received(msg, 0, 0)
+		return nil
+	case <-hc.pipe.requestClosed:
+		return NewError(CodeUnknown, io.EOF)
+	case <-hc.pipe.clientClosed:
+		return errorf(CodeCanceled, "client closed stream")
+	case <-hc.pipe.ctx.Done():
+		return wrapIfContextError(hc.pipe.ctx.Err())
+	}
+}
+
+// RequestHeader returns the headers sent by the client, blocking until
+// they're available.
+func (hc *InMemoryHandlerConn) RequestHeader() http.Header {
+	select {
+	case <-hc.pipe.requestHeaderSent:
+		return hc.pipe.sentRequestHeader
+	case <-hc.pipe.clientClosed:
+	case <-hc.pipe.ctx.Done():
+	}
+	return make(http.Header)
+}
+
+// Send copies the message to the client, blocking until the client receives
+// it. The first call to Send also sends the response headers.
+func (hc *InMemoryHandlerConn) Send(msg any) error {
+	select {
+	case <-hc.pipe.responseClosed:
+		return errorf(CodeInternal, "send on closed stream")
+	default:
+	}
+	hc.pipe.sendResponseHeader()
+	clone, err := cloneMessage(msg)
+	if err != nil {
+		return err
+	}
+	select {
+	case hc.pipe.responses <- clone:
+		hc.stats.Sent(msg, 0, 0)
+		return nil
+	case <-hc.pipe.clientClosed:
+		return errorf(CodeCanceled, "client closed stream")
+	case <-hc.pipe.ctx.Done():
+		return wrapIfContextError(hc.pipe.ctx.Err())
+	}
+}
+
+// ResponseHeader returns the response headers. Headers are sent to the client
+// with the first call to Send or Close, so subsequent mutations have no
+// effect.
+func (hc *InMemoryHandlerConn) ResponseHeader() http.Header {
+	return hc.pipe.responseHeader
+}
+
+// ResponseTrailer returns the response trailers. Trailers are sent to the
+// client when Close is called.
+func (hc *InMemoryHandlerConn) ResponseTrailer() http.Header {
+	return hc.pipe.responseTrailer
+}
+
+// Close ends the stream. If err is nil, the client's Receive returns an error
+// wrapping io.EOF; otherwise, it returns err as an *Error. Headers are sent if
+// they haven't been already, and trailers are sent along with the error.
+// Calls after the first have no effect.
+func (hc *InMemoryHandlerConn) Close(err error) error {
+	hc.pipe.sendResponseHeader()
+	hc.pipe.closeResponseOnce.Do(func() {
+		mergeHeaders(hc.pipe.sentResponseTrailer, hc.pipe.responseTrailer)
+		hc.pipe.responseErr = wrapIfUncoded(err)
+		close(hc.pipe.responseClosed)
+	})
+	return nil
+}
+
+// inMemoryPipe is the state shared by the two sides of an in-memory stream.
+// Messages are handed off over unbuffered channels, and each side's headers
+// and trailers are copied when they're sent, so the two sides never share
+// mutable state.
+type inMemoryPipe struct {
+	ctx context.Context // nolint:containedctx
+
+	requests            chan any
+	requestHeaderSent   chan struct{}
+	sendRequestOnce     sync.Once
+	requestClosed       chan struct{}
+	closeRequestOnce    sync.Once
+	responses           chan any
+	responseHeaderSent  chan struct{}
+	sendResponseOnce    sync.Once
+	responseClosed      chan struct{}
+	closeResponseOnce   sync.Once
+	clientClosed        chan struct{}
+	closeClientOnce     sync.Once
+	requestHeader       http.Header
+	responseHeader      http.Header
+	responseTrailer     http.Header
+	responseErr         error
+	sentRequestHeader   http.Header
+	sentResponseHeader  http.Header
+	sentResponseTrailer http.Header
+}
+
+func (p *inMemoryPipe) sendRequestHeader() {
+	p.sendRequestOnce.Do(func() {
+		mergeHeaders(p.sentRequestHeader, p.requestHeader)
+		close(p.requestHeaderSent)
+	})
+}
+
+func (p *inMemoryPipe) sendResponseHeader() {
+	p.sendResponseOnce.Do(func() {
+		mergeHeaders(p.sentResponseHeader, p.responseHeader)
+		close(p.responseHeaderSent)
+	})
+}
+
+// cloneMessage deep-copies Protobuf messages and shallow-copies anything else,
+// which must be a non-nil pointer.
+func cloneMessage(msg any) (any, error) {
+	if protoMessage, ok := msg.(proto.Message); ok {
+		return proto.Clone(protoMessage), nil
+	}
+	value := reflect.ValueOf(msg)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return nil, errorf(CodeInternal, "in-memory stream: expected non-nil pointer, got %T", msg)
+	}
+	clone := reflect.New(value.Elem().Type())
+	clone.Elem().Set(value.Elem())
+	return clone.Interface(), nil
+}
+
+// assignMessage copies src, which must have been produced by cloneMessage,
+// into dst.
+func assignMessage(dst, src any) error {
+	if dstMessage, ok := dst.(proto.Message); ok {
+		srcMessage, ok := src.(proto.Message)
+		if !ok || dstMessage.ProtoReflect().Descriptor() != srcMessage.ProtoReflect().Descriptor() {
+			return errorf(CodeInternal, "in-memory stream: can't receive %T into %T", src, dst)
+		}
+		proto.Reset(dstMessage)
+		proto.Merge(dstMessage, srcMessage)
+		return nil
+	}
+	dstValue, srcValue := reflect.ValueOf(dst), reflect.ValueOf(src)
+	if dstValue.Kind() != reflect.Ptr || dstValue.IsNil() || dstValue.Type() != srcValue.Type() {
+		return errorf(CodeInternal, "in-memory stream: can't receive %T into %T", src, dst)
+	}
+	dstValue.Elem().Set(srcValue.Elem())
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connect"
+)
+
+func TestInMemoryStream(t *testing.T) {
+	t.Parallel()
+	// The client never touches the network: every streaming RPC is served by
+	// the interceptor.
+	client := pingv1connect.NewPingServiceClient(
+		http.DefaultClient,
+		"http://unreachable.invalid",
+		connect.WithInterceptors(&inMemoryInterceptor{}),
+	)
+	t.Run("client_stream", func(t *testing.T) {
+		t.Parallel()
+		stream := client.Sum(context.Background())
+		stream.RequestHeader().Set(clientHeader, headerValue)
+		for i := int64(1); i <= 3; i++ {
+			assert.Nil(t, stream.Send(&pingv1.SumRequest{Number: i}))
+		}
+		response, err := stream.CloseAndReceive()
+		assert.Nil(t, err)
+		assert.Equal(t, response.Msg.Sum, int64(6))
+		assert.Equal(t, response.Header().Get(handlerHeader), headerValue)
+		assert.Equal(t, response.Trailer().Get(handlerTrailer), headerValue)
+		assert.Equal(t, response.Stats().MessagesSent, int64(3))
+	})
+	t.Run("server_stream", func(t *testing.T) {
+		t.Parallel()
+		stream, err := client.CountUp(
+			context.Background(),
+			connect.NewRequest(&pingv1.CountUpRequest{Number: 3}),
+		)
+		assert.Nil(t, err)
+		var got []int64
+		for stream.Receive() {
+			got = append(got, stream.Msg().Number)
+		}
+		assert.Nil(t, stream.Err())
+		assert.Equal(t, got, []int64{1, 2, 3})
+		assert.Equal(t, stream.ResponseTrailer().Get(handlerTrailer), headerValue)
+		assert.Nil(t, stream.Close())
+	})
+	t.Run("bidi_stream_error", func(t *testing.T) {
+		t.Parallel()
+		stream := client.CumSum(context.Background())
+		assert.Nil(t, stream.Send(&pingv1.CumSumRequest{Number: 1}))
+		response, err := stream.Receive()
+		assert.Nil(t, err)
+		assert.Equal(t, response.Sum, int64(1))
+		assert.Nil(t, stream.Send(&pingv1.CumSumRequest{Number: -1}))
+		_, err = stream.Receive()
+		assert.Equal(t, connect.CodeOf(err), connect.CodeInvalidArgument)
+		// The handler is gone, so sends fail with an error wrapping io.EOF.
+		assert.ErrorIs(t, stream.Send(&pingv1.CumSumRequest{Number: 1}), io.EOF)
+		assert.Equal(t, stream.ResponseTrailer().Get(handlerTrailer), headerValue)
+		assert.Nil(t, stream.CloseRequest())
+		assert.Nil(t, stream.CloseResponse())
+	})
+}
+
+// inMemoryInterceptor serves streaming RPCs from memory.
+type inMemoryInterceptor struct{}
+
+func (i *inMemoryInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return next
+}
+
+func (i *inMemoryInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		client, handler := connect.NewInMemoryStream(ctx, spec)
+		go func() {
+			_ = handler.Close(serveInMemory(handler))
+		}()
+		return client
+	}
+}
+
+func (i *inMemoryInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+func serveInMemory(conn *connect.InMemoryHandlerConn) error {
+	conn.ResponseHeader().Set(handlerHeader, conn.RequestHeader().Get(clientHeader))
+	conn.ResponseTrailer().Set(handlerTrailer, headerValue)
+	switch conn.Spec().Procedure {
+	case "/connect.ping.v1.PingService/Sum":
+		var sum int64
+		for {
+			var request pingv1.SumRequest
+			if err := conn.Receive(&request); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return err
+			}
+			sum += request.Number
+		}
+		return conn.Send(&pingv1.SumResponse{Sum: sum})
+	case "/connect.ping.v1.PingService/CountUp":
+		var request pingv1.CountUpRequest
+		if err := conn.Receive(&request); err != nil {
+			return err
+		}
+		for i := int64(1); i <= request.Number; i++ {
+			if err := conn.Send(&pingv1.CountUpResponse{Number: i}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "/connect.ping.v1.PingService/CumSum":
+		var sum int64
+		for {
+			var request pingv1.CumSumRequest
+			if err := conn.Receive(&request); errors.Is(err, io.EOF) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if request.Number < 0 {
+				return connect.NewError(connect.CodeInvalidArgument, errors.New("negative number"))
+			}
+			sum += request.Number
+			if err := conn.Send(&pingv1.CumSumResponse{Sum: sum}); err != nil {
+				return err
+			}
+		}
+	}
+	return connect.NewError(connect.CodeUnimplemented, errors.New("not served from memory"))
+}
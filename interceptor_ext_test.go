@@ -24,6 +24,7 @@ import (
 	"github.com/bufbuild/connect-go/internal/assert"
 	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
 	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connect"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 func TestOnionOrderingEndToEnd(t *testing.T) {
@@ -241,3 +242,77 @@ func (cc *headerInspectingClientConn) Receive(msg any) error {
 	}
 	return err
 }
+
+// schemaInterceptor records the names of the method descriptors in each Spec.
+type schemaInterceptor struct {
+	names chan string
+}
+
+func (i *schemaInterceptor) record(spec connect.Spec) {
+	name := "none"
+	if descriptor, ok := spec.Schema.(protoreflect.MethodDescriptor); ok {
+		name = string(descriptor.Name())
+	}
+	if spec.IsClient {
+		name = "client " + name
+	} else {
+		name = "handler " + name
+	}
+	i.names <- name
+}
+
+func (i *schemaInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
+		i.record(request.Spec())
+		return next(ctx, request)
+	}
+}
+
+func (i *schemaInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		i.record(spec)
+		return next(ctx, spec)
+	}
+}
+
+func (i *schemaInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		i.record(conn.Spec())
+		return next(ctx, conn)
+	}
+}
+
+func TestSpecSchema(t *testing.T) {
+	t.Parallel()
+	interceptor := &schemaInterceptor{names: make(chan string, 4)}
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(pingServer{}, connect.WithInterceptors(interceptor)))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	client := pingv1connect.NewPingServiceClient(server.Client(), server.URL, connect.WithInterceptors(interceptor))
+
+	_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+	assert.Nil(t, err)
+	assert.Equal(t, <-interceptor.names, "client Ping")
+	assert.Equal(t, <-interceptor.names, "handler Ping")
+
+	stream, err := client.CountUp(context.Background(), connect.NewRequest(&pingv1.CountUpRequest{Number: 1}))
+	assert.Nil(t, err)
+	for stream.Receive() {
+	}
+	assert.Nil(t, stream.Err())
+	assert.Nil(t, stream.Close())
+	assert.Equal(t, <-interceptor.names, "client CountUp")
+	assert.Equal(t, <-interceptor.names, "handler CountUp")
+
+	// Clients constructed without WithSchema have no schema.
+	unschematized := connect.NewClient[pingv1.PingRequest, pingv1.PingResponse](
+		server.Client(),
+		server.URL+pingv1connect.PingServicePingProcedure,
+		connect.WithInterceptors(interceptor),
+	)
+	_, err = unschematized.CallUnary(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+	assert.Nil(t, err)
+	assert.Equal(t, <-interceptor.names, "client none")
+	assert.Equal(t, <-interceptor.names, "handler Ping")
+}
@@ -32,13 +32,80 @@ import (
 // generated with a version of connect newer than the one compiled into your binary. You can fix the
 // problem by either regenerating this code with an older version of connect or updating the connect
 // version compiled into your binary.
-const _ = connect_go.IsAtLeastVersion0_1_0
+const _ = connect_go.IsAtLeastVersion0_3_0
 
 const (
 	// PingServiceName is the fully-qualified name of the PingService service.
 	PingServiceName = "connect.ping.v1.PingService"
 )
 
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// PingServicePingProcedure is the fully-qualified name of the PingService's Ping RPC.
+	PingServicePingProcedure = "/connect.ping.v1.PingService/Ping"
+	// PingServiceFailProcedure is the fully-qualified name of the PingService's Fail RPC.
+	PingServiceFailProcedure = "/connect.ping.v1.PingService/Fail"
+	// PingServiceSumProcedure is the fully-qualified name of the PingService's Sum RPC.
+	PingServiceSumProcedure = "/connect.ping.v1.PingService/Sum"
+	// PingServiceCountUpProcedure is the fully-qualified name of the PingService's CountUp RPC.
+	PingServiceCountUpProcedure = "/connect.ping.v1.PingService/CountUp"
+	// PingServiceCumSumProcedure is the fully-qualified name of the PingService's CumSum RPC.
+	PingServiceCumSumProcedure = "/connect.ping.v1.PingService/CumSum"
+)
+
+// These variables are the protoreflect.Descriptor objects for the RPCs defined in this package.
+var (
+	pingServiceServiceDescriptor       = v1.File_connect_ping_v1_ping_proto.Services().ByName("PingService")
+	pingServicePingMethodDescriptor    = pingServiceServiceDescriptor.Methods().ByName("Ping")
+	pingServiceFailMethodDescriptor    = pingServiceServiceDescriptor.Methods().ByName("Fail")
+	pingServiceSumMethodDescriptor     = pingServiceServiceDescriptor.Methods().ByName("Sum")
+	pingServiceCountUpMethodDescriptor = pingServiceServiceDescriptor.Methods().ByName("CountUp")
+	pingServiceCumSumMethodDescriptor  = pingServiceServiceDescriptor.Methods().ByName("CumSum")
+)
+
+// Register the RPCs defined in this file, so interceptors can look up their schemas with
+// connect.LookupMethod.
+func init() {
+	connect_go.RegisterMethods(
+		&connect_go.MethodInfo{
+			Procedure:        PingServicePingProcedure,
+			StreamType:       connect_go.StreamTypeUnary,
+			IdempotencyLevel: connect_go.IdempotencyUnknown,
+			Descriptor:       pingServicePingMethodDescriptor,
+		},
+		&connect_go.MethodInfo{
+			Procedure:        PingServiceFailProcedure,
+			StreamType:       connect_go.StreamTypeUnary,
+			IdempotencyLevel: connect_go.IdempotencyUnknown,
+			Descriptor:       pingServiceFailMethodDescriptor,
+		},
+		&connect_go.MethodInfo{
+			Procedure:        PingServiceSumProcedure,
+			StreamType:       connect_go.StreamTypeClient,
+			IdempotencyLevel: connect_go.IdempotencyUnknown,
+			Descriptor:       pingServiceSumMethodDescriptor,
+		},
+		&connect_go.MethodInfo{
+			Procedure:        PingServiceCountUpProcedure,
+			StreamType:       connect_go.StreamTypeServer,
+			IdempotencyLevel: connect_go.IdempotencyUnknown,
+			Descriptor:       pingServiceCountUpMethodDescriptor,
+		},
+		&connect_go.MethodInfo{
+			Procedure:        PingServiceCumSumProcedure,
+			StreamType:       connect_go.StreamTypeBidi,
+			IdempotencyLevel: connect_go.IdempotencyUnknown,
+			Descriptor:       pingServiceCumSumMethodDescriptor,
+		},
+	)
+}
+
 // PingServiceClient is a client for the connect.ping.v1.PingService service.
 type PingServiceClient interface {
 	// Ping sends a ping to the server to determine if it's reachable.
@@ -60,33 +127,41 @@ type PingServiceClient interface {
 //
 // The URL supplied here should be the base URL for the Connect or gRPC server (for example,
 // http://api.acme.com or https://acme.com/grpc).
+//
+// The options apply to every method's client. To configure a single method, use
+// connect.WithProcedureOptions.
 func NewPingServiceClient(httpClient connect_go.HTTPClient, baseURL string, opts ...connect_go.ClientOption) PingServiceClient {
 	baseURL = strings.TrimRight(baseURL, "/")
 	return &pingServiceClient{
 		ping: connect_go.NewClient[v1.PingRequest, v1.PingResponse](
 			httpClient,
-			baseURL+"/connect.ping.v1.PingService/Ping",
-			opts...,
+			baseURL+PingServicePingProcedure,
+			connect_go.WithSchema(pingServicePingMethodDescriptor),
+			connect_go.WithClientOptions(opts...),
 		),
 		fail: connect_go.NewClient[v1.FailRequest, v1.FailResponse](
 			httpClient,
-			baseURL+"/connect.ping.v1.PingService/Fail",
-			opts...,
+			baseURL+PingServiceFailProcedure,
+			connect_go.WithSchema(pingServiceFailMethodDescriptor),
+			connect_go.WithClientOptions(opts...),
 		),
 		sum: connect_go.NewClient[v1.SumRequest, v1.SumResponse](
 			httpClient,
-			baseURL+"/connect.ping.v1.PingService/Sum",
-			opts...,
+			baseURL+PingServiceSumProcedure,
+			connect_go.WithSchema(pingServiceSumMethodDescriptor),
+			connect_go.WithClientOptions(opts...),
 		),
 		countUp: connect_go.NewClient[v1.CountUpRequest, v1.CountUpResponse](
 			httpClient,
-			baseURL+"/connect.ping.v1.PingService/CountUp",
-			opts...,
+			baseURL+PingServiceCountUpProcedure,
+			connect_go.WithSchema(pingServiceCountUpMethodDescriptor),
+			connect_go.WithClientOptions(opts...),
 		),
 		cumSum: connect_go.NewClient[v1.CumSumRequest, v1.CumSumResponse](
 			httpClient,
-			baseURL+"/connect.ping.v1.PingService/CumSum",
-			opts...,
+			baseURL+PingServiceCumSumProcedure,
+			connect_go.WithSchema(pingServiceCumSumMethodDescriptor),
+			connect_go.WithClientOptions(opts...),
 		),
 	}
 }
@@ -144,32 +219,40 @@ type PingServiceHandler interface {
 //
 // By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
 // and JSON codecs. They also support gzip compression.
+//
+// The options apply to every method's handler. To configure a single method, use
+// connect.WithProcedureOptions.
 func NewPingServiceHandler(svc PingServiceHandler, opts ...connect_go.HandlerOption) (string, http.Handler) {
-	mux := http.NewServeMux()
-	mux.Handle("/connect.ping.v1.PingService/Ping", connect_go.NewUnaryHandler(
-		"/connect.ping.v1.PingService/Ping",
+	mux := connect_go.NewRouter(opts...)
+	mux.Handle(PingServicePingProcedure, connect_go.NewUnaryHandler(
+		PingServicePingProcedure,
 		svc.Ping,
-		opts...,
+		connect_go.WithSchema(pingServicePingMethodDescriptor),
+		connect_go.WithHandlerOptions(opts...),
 	))
-	mux.Handle("/connect.ping.v1.PingService/Fail", connect_go.NewUnaryHandler(
-		"/connect.ping.v1.PingService/Fail",
+	mux.Handle(PingServiceFailProcedure, connect_go.NewUnaryHandler(
+		PingServiceFailProcedure,
 		svc.Fail,
-		opts...,
+		connect_go.WithSchema(pingServiceFailMethodDescriptor),
+		connect_go.WithHandlerOptions(opts...),
 	))
-	mux.Handle("/connect.ping.v1.PingService/Sum", connect_go.NewClientStreamHandler(
-		"/connect.ping.v1.PingService/Sum",
+	mux.Handle(PingServiceSumProcedure, connect_go.NewClientStreamHandler(
+		PingServiceSumProcedure,
 		svc.Sum,
-		opts...,
+		connect_go.WithSchema(pingServiceSumMethodDescriptor),
+		connect_go.WithHandlerOptions(opts...),
 	))
-	mux.Handle("/connect.ping.v1.PingService/CountUp", connect_go.NewServerStreamHandler(
-		"/connect.ping.v1.PingService/CountUp",
+	mux.Handle(PingServiceCountUpProcedure, connect_go.NewServerStreamHandler(
+		PingServiceCountUpProcedure,
 		svc.CountUp,
-		opts...,
+		connect_go.WithSchema(pingServiceCountUpMethodDescriptor),
+		connect_go.WithHandlerOptions(opts...),
 	))
-	mux.Handle("/connect.ping.v1.PingService/CumSum", connect_go.NewBidiStreamHandler(
-		"/connect.ping.v1.PingService/CumSum",
+	mux.Handle(PingServiceCumSumProcedure, connect_go.NewBidiStreamHandler(
+		PingServiceCumSumProcedure,
 		svc.CumSum,
-		opts...,
+		connect_go.WithSchema(pingServiceCumSumMethodDescriptor),
+		connect_go.WithHandlerOptions(opts...),
 	))
 	return "/connect.ping.v1.PingService/", mux
 }
@@ -0,0 +1,122 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect
+
+import (
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// IdempotencyLevel describes the side effects of an RPC, as declared with the
+// idempotency_level method option in its Protobuf schema.
+type IdempotencyLevel int
+
+const (
+	// IdempotencyUnknown is the default idempotency level. The procedure may
+	// have side effects, so it isn't safe to retry or cache.
+	IdempotencyUnknown IdempotencyLevel = IdempotencyLevel(descriptorpb.MethodOptions_IDEMPOTENCY_UNKNOWN)
+
+	// IdempotencyNoSideEffects is the idempotency level for procedures that
+	// have no side effects, like HTTP GET requests.
+	IdempotencyNoSideEffects IdempotencyLevel = IdempotencyLevel(descriptorpb.MethodOptions_NO_SIDE_EFFECTS)
+
+	// IdempotencyIdempotent is the idempotency level for procedures that may
+	// have side effects, but for which repeated calls have the same effect as
+	// a single call, like HTTP PUT requests.
+	IdempotencyIdempotent IdempotencyLevel = IdempotencyLevel(descriptorpb.MethodOptions_IDEMPOTENT)
+)
+
+// String implements fmt.Stringer.
+func (i IdempotencyLevel) String() string {
+	switch i {
+	case IdempotencyUnknown:
+		return "idempotency_unknown"
+	case IdempotencyNoSideEffects:
+		return "no_side_effects"
+	case IdempotencyIdempotent:
+		return "idempotent"
+	}
+	return fmt.Sprintf("idempotency_level_%d", i)
+}
+
+// MethodInfo describes an RPC defined in a Protobuf schema. Generated code
+// registers a MethodInfo for each of its procedures with RegisterMethods, so
+// interceptors can find the schema for any Spec.Procedure with LookupMethod.
+// The request and response message types are available from the method
+// descriptor's Input and Output methods.
+type MethodInfo struct {
+	Procedure        string // for example, "/acme.foo.v1.FooService/Bar"
+	StreamType       StreamType
+	IdempotencyLevel IdempotencyLevel
+	Descriptor       protoreflect.MethodDescriptor
+}
+
+func (m *MethodInfo) equal(other *MethodInfo) bool {
+	if m.StreamType != other.StreamType || m.IdempotencyLevel != other.IdempotencyLevel {
+		return false
+	}
+	if m.Descriptor == nil || other.Descriptor == nil {
+		return m.Descriptor == other.Descriptor
+	}
+	return m.Descriptor.FullName() == other.Descriptor.FullName()
+}
+
+var methodRegistry = struct {
+	sync.RWMutex
+	methods map[string]*MethodInfo
+}{
+	methods: make(map[string]*MethodInfo),
+}
+
+// RegisterMethods adds methods to the global registry used by LookupMethod.
+// It's called automatically by generated code, so most users won't need to
+// call it directly.
+//
+// Like the Protobuf registry, RegisterMethods panics if a procedure is already
+// registered with a different stream type, idempotency level, or method
+// descriptor: conflicts usually mean that two copies of the same schema were
+// linked into one binary. Registering an identical MethodInfo again is a
+// no-op.
+func RegisterMethods(methods ...*MethodInfo) {
+	methodRegistry.Lock()
+	defer methodRegistry.Unlock()
+	for _, method := range methods {
+		if method == nil {
+			continue
+		}
+		if existing, ok := methodRegistry.methods[method.Procedure]; ok {
+			if !existing.equal(method) {
+				panic("connect: conflicting registration for " + method.Procedure)
+			}
+			continue
+		}
+		methodRegistry.methods[method.Procedure] = method
+	}
+}
+
+// LookupMethod finds the registered MethodInfo for a procedure, like
+// "/acme.foo.v1.FooService/Bar". Procedures may also be given without the
+// leading slash.
+//
+// The returned MethodInfo is shared, so callers must not modify it.
+func LookupMethod(procedure string) (*MethodInfo, bool) {
+	methodRegistry.RLock()
+	defer methodRegistry.RUnlock()
+	method, ok := methodRegistry.methods[extractProtoPath(procedure)]
+	return method, ok
+}
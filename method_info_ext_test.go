@@ -0,0 +1,93 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connect"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestLookupMethod(t *testing.T) {
+	t.Parallel()
+	t.Run("generated", func(t *testing.T) {
+		t.Parallel()
+		method, ok := connect.LookupMethod(pingv1connect.PingServiceCumSumProcedure)
+		assert.True(t, ok)
+		assert.Equal(t, method.Procedure, pingv1connect.PingServiceCumSumProcedure)
+		assert.Equal(t, method.StreamType, connect.StreamTypeBidi)
+		assert.Equal(t, method.IdempotencyLevel, connect.IdempotencyUnknown)
+		assert.Equal(t, method.Descriptor.FullName(), protoreflect.FullName("connect.ping.v1.PingService.CumSum"))
+		assert.Equal(t, method.Descriptor.Input().FullName(), (&pingv1.CumSumRequest{}).ProtoReflect().Descriptor().FullName())
+		// The leading slash is optional.
+		_, ok = connect.LookupMethod("connect.ping.v1.PingService/CumSum")
+		assert.True(t, ok)
+		_, ok = connect.LookupMethod("/connect.ping.v1.PingService/Missing")
+		assert.False(t, ok)
+	})
+	t.Run("interceptor", func(t *testing.T) {
+		t.Parallel()
+		descriptors := make(chan protoreflect.MethodDescriptor, 1)
+		interceptor := connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+			return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
+				if method, ok := connect.LookupMethod(request.Spec().Procedure); ok {
+					descriptors <- method.Descriptor
+				}
+				return next(ctx, request)
+			}
+		})
+		mux := http.NewServeMux()
+		mux.Handle(pingv1connect.NewPingServiceHandler(pingServer{}, connect.WithInterceptors(interceptor)))
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		client := pingv1connect.NewPingServiceClient(server.Client(), server.URL)
+		_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+		assert.Nil(t, err)
+		assert.Equal(t, (<-descriptors).Name(), protoreflect.Name("Ping"))
+	})
+	t.Run("conflict", func(t *testing.T) {
+		t.Parallel()
+		method, ok := connect.LookupMethod(pingv1connect.PingServicePingProcedure)
+		assert.True(t, ok)
+		// Registering an identical method again is allowed...
+		connect.RegisterMethods(&connect.MethodInfo{
+			Procedure:        method.Procedure,
+			StreamType:       method.StreamType,
+			IdempotencyLevel: method.IdempotencyLevel,
+			Descriptor:       method.Descriptor,
+		})
+		registered, ok := connect.LookupMethod(pingv1connect.PingServicePingProcedure)
+		assert.True(t, ok)
+		assert.True(t, registered == method)
+		// ...but conflicting registrations panic.
+		assert.Panics(t, func() {
+			connect.RegisterMethods(&connect.MethodInfo{
+				Procedure:  method.Procedure,
+				StreamType: connect.StreamTypeBidi,
+				Descriptor: method.Descriptor,
+			})
+		})
+		registered, ok = connect.LookupMethod(pingv1connect.PingServicePingProcedure)
+		assert.True(t, ok)
+		assert.True(t, registered == method)
+	})
+}
@@ -0,0 +1,544 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics provides a connect.Interceptor that records the standard
+// RPC metrics exported by grpc-ecosystem's go-grpc-prometheus, so existing
+// dashboards and alerts keep working. Each metric has the same name and
+// labels as its go-grpc-prometheus counterpart. WithProtocolLabel adds a
+// "protocol" label to every metric.
+//
+// To avoid forcing a dependency on any metrics library, the interceptor
+// creates metrics with a minimal Factory. Adapting the Prometheus client
+// takes only a few lines:
+//
+//   type promFactory struct{ registerer prometheus.Registerer }
+//
+//   func (f promFactory) NewCounter(name, help string, labels ...string) metrics.Counter {
+//     vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+//     f.registerer.MustRegister(vec)
+//     return promCounter{vec}
+//   }
+//
+//   type promCounter struct{ vec *prometheus.CounterVec }
+//
+//   func (c promCounter) Inc(values ...string) { c.vec.WithLabelValues(values...).Inc() }
+//
+// Histograms are adapted the same way.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+)
+
+// A Counter is a monotonically increasing counter, partitioned by labels.
+// Implementations must be safe to call concurrently.
+type Counter interface {
+	// Inc increments the counter with the supplied label values, which are in
+	// the same order as the label names passed to Factory.NewCounter.
+	Inc(labelValues ...string)
+}
+
+// A Histogram samples observations into buckets, partitioned by labels.
+// Implementations must be safe to call concurrently.
+type Histogram interface {
+	// Observe adds an observation with the supplied label values, which are in
+	// the same order as the label names passed to Factory.NewHistogram.
+	Observe(value float64, labelValues ...string)
+}
+
+// A Factory creates metrics. NewInterceptor calls each method once per metric,
+// so Factories may register each metric as it's created.
+type Factory interface {
+	NewCounter(name, help string, labelNames ...string) Counter
+	NewHistogram(name, help string, buckets []float64, labelNames ...string) Histogram
+}
+
+// Label names, shared with go-grpc-prometheus where possible.
+const (
+	LabelType     = "grpc_type"
+	LabelService  = "grpc_service"
+	LabelMethod   = "grpc_method"
+	LabelCode     = "grpc_code"
+	LabelProtocol = "protocol"
+)
+
+// DefaultHandlingTimeBuckets are the default buckets for the handling time
+// histograms, in seconds. They match Prometheus's default buckets.
+var DefaultHandlingTimeBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10} // nolint:gochecknoglobals
+
+// DefaultMessageSizeBuckets are the default buckets for the message size
+// histograms, in bytes.
+var DefaultMessageSizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304} // nolint:gochecknoglobals
+
+// An Option configures the interceptor returned by NewInterceptor.
+type Option interface {
+	apply(*config)
+}
+
+// WithHandlingTimeBuckets configures the buckets of the handling time
+// histograms.
+//
+// By default, the interceptor uses DefaultHandlingTimeBuckets.
+func WithHandlingTimeBuckets(buckets []float64) Option {
+	return &handlingTimeBucketsOption{buckets: buckets}
+}
+
+// WithMessageSizeBuckets configures the buckets of the message size
+// histograms.
+//
+// By default, the interceptor uses DefaultMessageSizeBuckets.
+func WithMessageSizeBuckets(buckets []float64) Option {
+	return &messageSizeBucketsOption{buckets: buckets}
+}
+
+// WithProtocolLabel adds a LabelProtocol label to every metric, with the
+// protocol of each RPC as its value.
+//
+// By default, the interceptor uses the same labels as go-grpc-prometheus, so
+// existing dashboards and alerts keep working.
+func WithProtocolLabel() Option {
+	return &protocolLabelOption{}
+}
+
+// NewInterceptor returns a connect.Interceptor that records metrics for both
+// clients and handlers. It creates all its metrics immediately, so create one
+// interceptor per Factory and share it between clients and handlers.
+//
+// For each side ("client" or "server"), the interceptor records:
+//
+//   grpc_<side>_started_total           counter   RPCs started
+//   grpc_<side>_handled_total           counter   RPCs completed, with grpc_code
+//   grpc_<side>_msg_received_total      counter   messages received
+//   grpc_<side>_msg_sent_total          counter   messages sent
+//   grpc_<side>_handling_seconds        histogram RPC latency
+//   grpc_<side>_msg_received_bytes      histogram size of messages received
+//   grpc_<side>_msg_sent_bytes          histogram size of messages sent
+//
+// Message sizes are the number of bytes produced or consumed by the Codec, as
+// reported by connect.StatsFromContext, so they don't depend on the message
+// type. Unary handlers send their response after the interceptor returns, so
+// the size of unary responses is only recorded by clients.
+func NewInterceptor(factory Factory, options ...Option) connect.Interceptor {
+	cfg := &config{
+		handlingTimeBuckets: DefaultHandlingTimeBuckets,
+		messageSizeBuckets:  DefaultMessageSizeBuckets,
+	}
+	for _, opt := range options {
+		opt.apply(cfg)
+	}
+	return &interceptor{
+		client:        newSideMetrics(factory, "client", cfg),
+		server:        newSideMetrics(factory, "server", cfg),
+		protocolLabel: cfg.protocolLabel,
+	}
+}
+
+type config struct {
+	handlingTimeBuckets []float64
+	messageSizeBuckets  []float64
+	protocolLabel       bool
+}
+
+type handlingTimeBucketsOption struct {
+	buckets []float64
+}
+
+func (o *handlingTimeBucketsOption) apply(cfg *config) {
+	cfg.handlingTimeBuckets = o.buckets
+}
+
+type messageSizeBucketsOption struct {
+	buckets []float64
+}
+
+func (o *messageSizeBucketsOption) apply(cfg *config) {
+	cfg.messageSizeBuckets = o.buckets
+}
+
+type protocolLabelOption struct{}
+
+func (o *protocolLabelOption) apply(cfg *config) {
+	cfg.protocolLabel = true
+}
+
+// sideMetrics are the metrics for either clients or handlers.
+type sideMetrics struct {
+	started       Counter
+	handled       Counter
+	received      Counter
+	sent          Counter
+	handlingTime  Histogram
+	receivedBytes Histogram
+	sentBytes     Histogram
+}
+
+func newSideMetrics(factory Factory, side string, cfg *config) *sideMetrics {
+	prefix := "grpc_" + side + "_"
+	labels := []string{LabelType, LabelService, LabelMethod}
+	if cfg.protocolLabel {
+		labels = append(labels, LabelProtocol)
+	}
+	handledLabels := append(labels[:len(labels):len(labels)], LabelCode)
+	return &sideMetrics{
+		started: factory.NewCounter(
+			prefix+"started_total",
+			"Total number of RPCs started on the "+side+".",
+			labels...,
+		),
+		handled: factory.NewCounter(
+			prefix+"handled_total",
+			"Total number of RPCs completed on the "+side+", regardless of success or failure.",
+			handledLabels...,
+		),
+		received: factory.NewCounter(
+			prefix+"msg_received_total",
+			"Total number of RPC messages received on the "+side+".",
+			labels...,
+		),
+		sent: factory.NewCounter(
+			prefix+"msg_sent_total",
+			"Total number of RPC messages sent by the "+side+".",
+			labels...,
+		),
+		handlingTime: factory.NewHistogram(
+			prefix+"handling_seconds",
+			"Histogram of response latency (seconds) of RPCs that completed on the "+side+".",
+			cfg.handlingTimeBuckets,
+			labels...,
+		),
+		receivedBytes: factory.NewHistogram(
+			prefix+"msg_received_bytes",
+			"Histogram of the size (bytes) of RPC messages received on the "+side+".",
+			cfg.messageSizeBuckets,
+			labels...,
+		),
+		sentBytes: factory.NewHistogram(
+			prefix+"msg_sent_bytes",
+			"Histogram of the size (bytes) of RPC messages sent by the "+side+".",
+			cfg.messageSizeBuckets,
+			labels...,
+		),
+	}
+}
+
+// messageReceived records a received message. A negative size means the size
+// isn't known, so it's not observed.
+func (m *sideMetrics) messageReceived(labels []string, size int64) {
+	m.received.Inc(labels...)
+	if size >= 0 {
+		m.receivedBytes.Observe(float64(size), labels...)
+	}
+}
+
+// messageSent records a sent message. A negative size means the size isn't
+// known, so it's not observed.
+func (m *sideMetrics) messageSent(labels []string, size int64) {
+	m.sent.Inc(labels...)
+	if size >= 0 {
+		m.sentBytes.Observe(float64(size), labels...)
+	}
+}
+
+func (m *sideMetrics) finished(labels []string, start time.Time, err error) {
+	m.handled.Inc(append(labels[:len(labels):len(labels)], codeLabel(err))...)
+	m.handlingTime.Observe(time.Since(start).Seconds(), labels...)
+}
+
+type interceptor struct {
+	client        *sideMetrics
+	server        *sideMetrics
+	protocolLabel bool
+}
+
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
+		spec := request.Spec()
+		metrics := i.server
+		if spec.IsClient {
+			metrics = i.client
+		}
+		labels := i.newLabels(spec, request.Peer())
+		start := time.Now()
+		metrics.started.Inc(labels...)
+		if spec.IsClient {
+			response, err := next(ctx, request)
+			stats, ok := connect.StatsFromContext(ctx)
+			metrics.messageSent(labels, unarySize(ok && stats.MessagesSent > 0, stats.UncompressedBytesSent))
+			if err == nil {
+				metrics.messageReceived(labels, unarySize(ok, stats.UncompressedBytesReceived))
+			}
+			metrics.finished(labels, start, err)
+			return response, err
+		}
+		stats, ok := connect.StatsFromContext(ctx)
+		metrics.messageReceived(labels, unarySize(ok, stats.UncompressedBytesReceived))
+		response, err := next(ctx, request)
+		if err == nil {
+			// The response hasn't been sent yet, so its size isn't known.
+			metrics.messageSent(labels, -1)
+		}
+		metrics.finished(labels, start, err)
+		return response, err
+	}
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		start := time.Now()
+		conn := next(ctx, spec)
+		labels := i.newLabels(spec, connect.PeerOf(conn))
+		i.client.started.Inc(labels...)
+		return &clientConn{
+			StreamingClientConn: conn,
+			ctx:                 ctx,
+			metrics:             i.client,
+			labels:              labels,
+			start:               start,
+		}
+	}
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		start := time.Now()
+		labels := i.newLabels(conn.Spec(), connect.PeerOf(conn))
+		i.server.started.Inc(labels...)
+		err := next(ctx, &handlerConn{
+			StreamingHandlerConn: conn,
+			ctx:                  ctx,
+			metrics:              i.server,
+			labels:               labels,
+		})
+		i.server.finished(labels, start, err)
+		return err
+	}
+}
+
+type handlerConn struct {
+	connect.StreamingHandlerConn
+
+	ctx     context.Context // nolint:containedctx
+	metrics *sideMetrics
+	labels  []string
+}
+
+// Peer implements connect.PeerReporter.
+func (hc *handlerConn) Peer() connect.Peer {
+	return connect.PeerOf(hc.StreamingHandlerConn)
+}
+
+func (hc *handlerConn) Receive(msg any) error {
+	before, beforeOK := connect.StatsFromContext(hc.ctx)
+	if err := hc.StreamingHandlerConn.Receive(msg); err != nil {
+		return err
+	}
+	after, afterOK := connect.StatsFromContext(hc.ctx)
+	hc.metrics.messageReceived(hc.labels, streamSize(
+		beforeOK && afterOK,
+		before.UncompressedBytesReceived,
+		after.UncompressedBytesReceived,
+	))
+	return nil
+}
+
+func (hc *handlerConn) Send(msg any) error {
+	before, beforeOK := connect.StatsFromContext(hc.ctx)
+	if err := hc.StreamingHandlerConn.Send(msg); err != nil {
+		return err
+	}
+	after, afterOK := connect.StatsFromContext(hc.ctx)
+	hc.metrics.messageSent(hc.labels, streamSize(
+		beforeOK && afterOK,
+		before.UncompressedBytesSent,
+		after.UncompressedBytesSent,
+	))
+	return nil
+}
+
+type clientConn struct {
+	connect.StreamingClientConn
+
+	ctx     context.Context // nolint:containedctx
+	metrics *sideMetrics
+	labels  []string
+	start   time.Time
+
+	mu       sync.Mutex
+	err      error
+	finished bool
+}
+
+// Peer implements connect.PeerReporter.
+func (cc *clientConn) Peer() connect.Peer {
+	return connect.PeerOf(cc.StreamingClientConn)
+}
+
+func (cc *clientConn) Send(msg any) error {
+	before, beforeOK := connect.StatsFromContext(cc.ctx)
+	if err := cc.StreamingClientConn.Send(msg); err != nil {
+		cc.setErr(err)
+		return err
+	}
+	after, afterOK := connect.StatsFromContext(cc.ctx)
+	cc.metrics.messageSent(cc.labels, streamSize(
+		beforeOK && afterOK,
+		before.UncompressedBytesSent,
+		after.UncompressedBytesSent,
+	))
+	return nil
+}
+
+func (cc *clientConn) Receive(msg any) error {
+	before, beforeOK := connect.StatsFromContext(cc.ctx)
+	if err := cc.StreamingClientConn.Receive(msg); err != nil {
+		cc.setErr(err)
+		return err
+	}
+	after, afterOK := connect.StatsFromContext(cc.ctx)
+	cc.metrics.messageReceived(cc.labels, streamSize(
+		beforeOK && afterOK,
+		before.UncompressedBytesReceived,
+		after.UncompressedBytesReceived,
+	))
+	return nil
+}
+
+func (cc *clientConn) CloseResponse() error {
+	err := cc.StreamingClientConn.CloseResponse()
+	cc.setErr(err)
+	cc.mu.Lock()
+	if cc.finished {
+		cc.mu.Unlock()
+		return err
+	}
+	cc.finished = true
+	callErr := cc.err
+	cc.mu.Unlock()
+	cc.metrics.finished(cc.labels, cc.start, callErr)
+	return err
+}
+
+// setErr records the first error that ends the RPC. Clients signal the end of
+// the response stream with io.EOF, which isn't a failure.
+func (cc *clientConn) setErr(err error) {
+	if err == nil || errors.Is(err, io.EOF) {
+		return
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.err == nil {
+		cc.err = err
+	}
+}
+
+// newLabels returns the type, service, and method labels, followed by the
+// protocol label if it's enabled.
+func (i *interceptor) newLabels(spec connect.Spec, peer connect.Peer) []string {
+	service, method := "unknown", "unknown"
+	procedure := strings.TrimPrefix(spec.Procedure, "/")
+	if slash := strings.LastIndex(procedure, "/"); slash >= 0 {
+		service, method = procedure[:slash], procedure[slash+1:]
+	}
+	labels := []string{streamTypeLabel(spec.StreamType), service, method}
+	if i.protocolLabel {
+		labels = append(labels, peer.Protocol)
+	}
+	return labels
+}
+
+// unarySize returns the size of a unary message, which is the RPC's total
+// uncompressed byte count in that direction, or -1 if it's not known.
+func unarySize(ok bool, total int64) int64 {
+	if !ok {
+		return -1
+	}
+	return total
+}
+
+// streamSize returns the size of a streamed message, which is the growth in
+// the RPC's uncompressed byte count while it was sent or received, or -1 if
+// it's not known. Retrying interceptors may replace the RPC's conn mid-call,
+// which can shrink the count.
+func streamSize(ok bool, before, after int64) int64 {
+	if !ok || after < before {
+		return -1
+	}
+	return after - before
+}
+
+// streamTypeLabel uses the same values as go-grpc-prometheus.
+func streamTypeLabel(streamType connect.StreamType) string {
+	switch streamType {
+	case connect.StreamTypeUnary:
+		return "unary"
+	case connect.StreamTypeClient:
+		return "client_stream"
+	case connect.StreamTypeServer:
+		return "server_stream"
+	case connect.StreamTypeBidi:
+		return "bidi_stream"
+	default:
+		return "unknown"
+	}
+}
+
+// codeLabel uses the same values as grpc-go's codes.Code.String.
+func codeLabel(err error) string {
+	if err == nil {
+		return "OK"
+	}
+	switch connect.CodeOf(err) {
+	case connect.CodeCanceled:
+		return "Canceled"
+	case connect.CodeUnknown:
+		return "Unknown"
+	case connect.CodeInvalidArgument:
+		return "InvalidArgument"
+	case connect.CodeDeadlineExceeded:
+		return "DeadlineExceeded"
+	case connect.CodeNotFound:
+		return "NotFound"
+	case connect.CodeAlreadyExists:
+		return "AlreadyExists"
+	case connect.CodePermissionDenied:
+		return "PermissionDenied"
+	case connect.CodeResourceExhausted:
+		return "ResourceExhausted"
+	case connect.CodeFailedPrecondition:
+		return "FailedPrecondition"
+	case connect.CodeAborted:
+		return "Aborted"
+	case connect.CodeOutOfRange:
+		return "OutOfRange"
+	case connect.CodeUnimplemented:
+		return "Unimplemented"
+	case connect.CodeInternal:
+		return "Internal"
+	case connect.CodeUnavailable:
+		return "Unavailable"
+	case connect.CodeDataLoss:
+		return "DataLoss"
+	case connect.CodeUnauthenticated:
+		return "Unauthenticated"
+	default:
+		return "Unknown"
+	}
+}
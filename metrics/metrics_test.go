@@ -0,0 +1,208 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connect"
+	"github.com/bufbuild/connect-go/metrics"
+	"google.golang.org/protobuf/proto"
+)
+
+type pingServer struct {
+	pingv1connect.UnimplementedPingServiceHandler
+}
+
+func (pingServer) Ping(_ context.Context, request *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+	return connect.NewResponse(&pingv1.PingResponse{Number: request.Msg.Number}), nil
+}
+
+func (pingServer) Fail(context.Context, *connect.Request[pingv1.FailRequest]) (*connect.Response[pingv1.FailResponse], error) {
+	return nil, connect.NewError(connect.CodeResourceExhausted, errors.New("slow down"))
+}
+
+func (pingServer) CountUp(
+	_ context.Context,
+	request *connect.Request[pingv1.CountUpRequest],
+	stream *connect.ServerStream[pingv1.CountUpResponse],
+) error {
+	for i := int64(1); i <= request.Msg.Number; i++ {
+		if err := stream.Send(&pingv1.CountUpResponse{Number: i}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memoryFactory records metrics in memory. Series are keyed by the metric name
+// and the comma-separated label values.
+type memoryFactory struct {
+	mu           sync.Mutex
+	counts       map[string]int
+	observations map[string]int
+	sums         map[string]float64
+}
+
+func newMemoryFactory() *memoryFactory {
+	return &memoryFactory{
+		counts:       make(map[string]int),
+		observations: make(map[string]int),
+		sums:         make(map[string]float64),
+	}
+}
+
+func (f *memoryFactory) NewCounter(name, _ string, _ ...string) metrics.Counter {
+	return &memoryMetric{name: name, factory: f}
+}
+
+func (f *memoryFactory) NewHistogram(name, _ string, _ []float64, _ ...string) metrics.Histogram {
+	return &memoryMetric{name: name, factory: f}
+}
+
+func (f *memoryFactory) count(name string, labels ...string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counts[name+"{"+strings.Join(labels, ",")+"}"]
+}
+
+func (f *memoryFactory) observed(name string, labels ...string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.observations[name+"{"+strings.Join(labels, ",")+"}"]
+}
+
+func (f *memoryFactory) sum(name string, labels ...string) float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sums[name+"{"+strings.Join(labels, ",")+"}"]
+}
+
+type memoryMetric struct {
+	name    string
+	factory *memoryFactory
+}
+
+func (m *memoryMetric) Inc(labelValues ...string) {
+	m.factory.mu.Lock()
+	defer m.factory.mu.Unlock()
+	m.factory.counts[m.name+"{"+strings.Join(labelValues, ",")+"}"]++
+}
+
+func (m *memoryMetric) Observe(value float64, labelValues ...string) {
+	m.factory.mu.Lock()
+	defer m.factory.mu.Unlock()
+	key := m.name + "{" + strings.Join(labelValues, ",") + "}"
+	m.factory.observations[key]++
+	m.factory.sums[key] += value
+}
+
+func newPingClient(t *testing.T, interceptor connect.Interceptor) pingv1connect.PingServiceClient {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		pingServer{},
+		connect.WithInterceptors(interceptor),
+	))
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return pingv1connect.NewPingServiceClient(
+		server.Client(),
+		server.URL,
+		connect.WithGRPC(),
+		connect.WithInterceptors(interceptor),
+	)
+}
+
+func TestInterceptor(t *testing.T) {
+	t.Parallel()
+	factory := newMemoryFactory()
+	client := newPingClient(t, metrics.NewInterceptor(factory))
+	const service = "connect.ping.v1.PingService"
+
+	pingRequest := &pingv1.PingRequest{Number: 1}
+	_, err := client.Ping(context.Background(), connect.NewRequest(pingRequest))
+	assert.Nil(t, err)
+	_, err = client.Fail(context.Background(), connect.NewRequest(&pingv1.FailRequest{}))
+	assert.NotNil(t, err)
+	stream, err := client.CountUp(context.Background(), connect.NewRequest(&pingv1.CountUpRequest{Number: 3}))
+	assert.Nil(t, err)
+	var received int
+	for stream.Receive() {
+		received++
+	}
+	assert.Nil(t, stream.Err())
+	assert.Equal(t, received, 3)
+	assert.Nil(t, stream.Close())
+
+	for _, side := range []string{"client", "server"} {
+		ping := []string{"unary", service, "Ping"}
+		assert.Equal(t, factory.count("grpc_"+side+"_started_total", ping...), 1)
+		assert.Equal(t, factory.count("grpc_"+side+"_handled_total", append(ping, "OK")...), 1)
+		assert.Equal(t, factory.count("grpc_"+side+"_msg_received_total", ping...), 1)
+		assert.Equal(t, factory.count("grpc_"+side+"_msg_sent_total", ping...), 1)
+		assert.Equal(t, factory.observed("grpc_"+side+"_handling_seconds", ping...), 1)
+		assert.Equal(t, factory.observed("grpc_"+side+"_msg_received_bytes", ping...), 1)
+
+		fail := []string{"unary", service, "Fail"}
+		assert.Equal(t, factory.count("grpc_"+side+"_handled_total", append(fail, "ResourceExhausted")...), 1)
+
+		countUp := []string{"server_stream", service, "CountUp"}
+		assert.Equal(t, factory.count("grpc_"+side+"_started_total", countUp...), 1)
+		assert.Equal(t, factory.count("grpc_"+side+"_handled_total", append(countUp, "OK")...), 1)
+	}
+	countUp := []string{"server_stream", service, "CountUp"}
+	assert.Equal(t, factory.count("grpc_server_msg_sent_total", countUp...), 3)
+	assert.Equal(t, factory.count("grpc_client_msg_received_total", countUp...), 3)
+	assert.Equal(t, factory.observed("grpc_client_msg_received_bytes", countUp...), 3)
+	assert.Equal(t, factory.observed("grpc_server_msg_sent_bytes", countUp...), 3)
+
+	// Sizes come from the RPC's stats, so they match the encoded messages.
+	ping := []string{"unary", service, "Ping"}
+	assert.Equal(t, factory.sum("grpc_client_msg_sent_bytes", ping...), float64(proto.Size(pingRequest)))
+	assert.Equal(t, factory.sum("grpc_server_msg_received_bytes", ping...), float64(proto.Size(pingRequest)))
+	// Unary handlers send their response after the interceptor returns.
+	assert.Equal(t, factory.observed("grpc_server_msg_sent_bytes", ping...), 0)
+	var countUpSize int
+	for i := int64(1); i <= 3; i++ {
+		countUpSize += proto.Size(&pingv1.CountUpResponse{Number: i})
+	}
+	assert.Equal(t, factory.sum("grpc_client_msg_received_bytes", countUp...), float64(countUpSize))
+	assert.Equal(t, factory.sum("grpc_server_msg_sent_bytes", countUp...), float64(countUpSize))
+}
+
+func TestInterceptorProtocolLabel(t *testing.T) {
+	t.Parallel()
+	factory := newMemoryFactory()
+	client := newPingClient(t, metrics.NewInterceptor(factory, metrics.WithProtocolLabel()))
+	_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{Number: 1}))
+	assert.Nil(t, err)
+	ping := []string{"unary", "connect.ping.v1.PingService", "Ping", connect.ProtocolGRPC}
+	for _, side := range []string{"client", "server"} {
+		assert.Equal(t, factory.count("grpc_"+side+"_started_total", ping...), 1)
+		assert.Equal(t, factory.count("grpc_"+side+"_handled_total", append(ping, "OK")...), 1)
+	}
+}
@@ -71,7 +71,8 @@ func WithGRPCWeb() ClientOption {
 // binary Protobuf. It uses the standard Protobuf JSON mapping as implemented
 // by google.golang.org/protobuf/encoding/protojson: fields are named using
 // lowerCamelCase, zero values are omitted, missing required fields are errors,
-// enums are emitted as strings, etc.
+// enums are emitted as strings, etc. To customize the mapping, use WithCodec
+// and NewProtoJSONCodec instead.
 func WithProtoJSON() ClientOption {
 	return WithCodec(&protoJSONCodec{})
 }
@@ -144,10 +145,37 @@ func WithHandlerOptions(options ...HandlerOption) HandlerOption {
 // usually necessary to prevent crashes. Instead, it helps servers collect
 // RPC-specific data during panics and send a more detailed error to
 // clients.
+//
+// To also receive the stack trace and the number of streaming messages
+// exchanged before the panic, use WithRecoverPanic.
 func WithRecover(handle func(context.Context, Spec, http.Header, any) error) HandlerOption {
+	return WithRecoverPanic(func(ctx context.Context, recovered *RecoveredPanic) error {
+		return handle(ctx, recovered.Spec, recovered.Header, recovered.Value)
+	})
+}
+
+// WithRecoverPanic is like WithRecover, but the supplied function receives a
+// RecoveredPanic, which also includes the stack trace of the panicking
+// goroutine and, for streaming RPCs, the number of messages received and
+// sent before the panic.
+func WithRecoverPanic(handle func(context.Context, *RecoveredPanic) error) HandlerOption {
 	return WithInterceptors(&recoverHandlerInterceptor{handle: handle})
 }
 
+// WithErrorRedactor configures handlers to pass every outgoing error through
+// the supplied function before it's serialized and sent to the client. The
+// function may rewrite the error's message, drop details, or replace it
+// entirely - for example, it might replace errors without a Code, which would
+// otherwise be sent as CodeUnknown with the full error text, with a generic
+// message. It receives the error returned by the handler (or by the
+// outermost interceptor), so interceptors still observe the original error.
+// If the function returns nil, the original error is sent.
+//
+// By default, handlers send errors to clients unchanged.
+func WithErrorRedactor(redact func(error) error) HandlerOption {
+	return &errorRedactorOption{Redact: redact}
+}
+
 // Option implements both ClientOption and HandlerOption, so it can be applied
 // both client-side and server-side.
 type Option interface {
@@ -163,10 +191,11 @@ type Option interface {
 // google.golang.org/protobuf/proto. Handlers also support JSON by default,
 // using the standard Protobuf JSON mapping. Users with more specialized needs
 // may override the default codecs by registering a new codec under the "proto"
-// or "json" names. When supplying a custom "proto" codec, keep in mind that
-// some unexported, protocol-specific messages are serialized using Protobuf -
-// take care to fall back to the standard Protobuf implementation if
-// necessary.
+// or "json" names; NewProtoBinaryCodec and NewProtoJSONCodec construct
+// configurable versions of the defaults. When supplying a custom "proto"
+// codec, keep in mind that some unexported, protocol-specific messages are
+// serialized using Protobuf - take care to fall back to the standard Protobuf
+// implementation if necessary.
 //
 // Registering a codec with an empty name is a no-op.
 func WithCodec(codec Codec) Option {
@@ -196,6 +225,29 @@ func WithReadMaxBytes(max int) Option {
 	return &readMaxBytesOption{Max: max}
 }
 
+// WithErrorClassifiers configures clients and handlers to map domain errors,
+// like sql.ErrNoRows, to and from *Errors. Handlers classify uncoded errors
+// before sending them to clients, so they're sent with a meaningful Code
+// rather than CodeUnknown. Clients use the classifiers to rebuild domain errors
+// from the *Errors they receive, so errors.Is and errors.As work as they would
+// in-process. Classifiers are tried in order, and the first match wins.
+//
+// Handlers always classify errors that implement Coder, even without this
+// option. Classified errors aren't visible to interceptors, which see the
+// error returned by the handler.
+func WithErrorClassifiers(classifiers ...ErrorClassifier) Option {
+	return &errorClassifiersOption{Classifiers: classifiers}
+}
+
+// WithStatsHandler configures clients and handlers to report transport-level
+// events, like headers arriving or messages being written to the network, to
+// a StatsHandler. Later calls to WithStatsHandler replace earlier ones.
+//
+// By default, no events are reported.
+func WithStatsHandler(handler StatsHandler) Option {
+	return &statsHandlerOption{Handler: handler}
+}
+
 // WithInterceptors configures a client or handler's interceptor stack. Repeated
 // WithInterceptors options are applied in order, so
 //
@@ -252,6 +304,59 @@ func WithOptions(options ...Option) Option {
 	return &optionsOption{options}
 }
 
+// WithSchema provides a parsed representation of the schema for an RPC to a
+// client or handler. The supplied schema is exposed as Spec.Schema, so it's
+// available to every interceptor. Code generated by protoc-gen-connect-go
+// supplies the RPC's protoreflect.MethodDescriptor.
+func WithSchema(schema any) Option {
+	return &schemaOption{Schema: schema}
+}
+
+// WithProcedureOptions composes multiple Options into one, which only applies
+// to the client or handler for the supplied procedure. Procedures are
+// fully-qualified method names, like "/acme.foo.v1.FooService/Bar".
+//
+// Generated constructors pass the same options to the client or handler for
+// every method in a service, so WithProcedureOptions is the easiest way to
+// configure a single method differently. For example, to accept larger
+// messages for one heavy method:
+//
+//   path, handler := foov1connect.NewFooServiceHandler(
+//     svc,
+//     connect.WithReadMaxBytes(1024*1024),
+//     connect.WithProcedureOptions(
+//       "/acme.foo.v1.FooService/Upload",
+//       connect.WithReadMaxBytes(64*1024*1024),
+//     ),
+//   )
+//
+// Options apply in order, so procedure-specific options should come after the
+// options they override.
+func WithProcedureOptions(procedure string, options ...Option) Option {
+	return &procedureOptionsOption{
+		procedure: extractProtoPath(procedure),
+		options:   options,
+	}
+}
+
+// WithProcedureClientOptions is like WithProcedureOptions, but accepts
+// client-only options.
+func WithProcedureClientOptions(procedure string, options ...ClientOption) ClientOption {
+	return &procedureOptionsOption{
+		procedure:     extractProtoPath(procedure),
+		clientOptions: options,
+	}
+}
+
+// WithProcedureHandlerOptions is like WithProcedureOptions, but accepts
+// handler-only options.
+func WithProcedureHandlerOptions(procedure string, options ...HandlerOption) HandlerOption {
+	return &procedureOptionsOption{
+		procedure:      extractProtoPath(procedure),
+		handlerOptions: options,
+	}
+}
+
 type clientOptionsOption struct {
 	options []ClientOption
 }
@@ -325,6 +430,38 @@ func (o *readMaxBytesOption) applyToHandler(config *handlerConfig) {
 	config.ReadMaxBytes = o.Max
 }
 
+type errorClassifiersOption struct {
+	Classifiers []ErrorClassifier
+}
+
+func (o *errorClassifiersOption) applyToClient(config *clientConfig) {
+	config.ErrorClassifiers = append(config.ErrorClassifiers, o.Classifiers...)
+}
+
+func (o *errorClassifiersOption) applyToHandler(config *handlerConfig) {
+	config.ErrorClassifiers = append(config.ErrorClassifiers, o.Classifiers...)
+}
+
+type statsHandlerOption struct {
+	Handler StatsHandler
+}
+
+func (o *statsHandlerOption) applyToClient(config *clientConfig) {
+	config.StatsHandler = o.Handler
+}
+
+func (o *statsHandlerOption) applyToHandler(config *handlerConfig) {
+	config.StatsHandler = o.Handler
+}
+
+type errorRedactorOption struct {
+	Redact func(error) error
+}
+
+func (o *errorRedactorOption) applyToHandler(config *handlerConfig) {
+	config.RedactError = o.Redact
+}
+
 type handlerOptionsOption struct {
 	options []HandlerOption
 }
@@ -368,6 +505,49 @@ func (o *interceptorsOption) chainWith(current Interceptor) Interceptor {
 	return newChain(append([]Interceptor{current}, o.Interceptors...))
 }
 
+type schemaOption struct {
+	Schema any
+}
+
+func (o *schemaOption) applyToClient(config *clientConfig) {
+	config.Schema = o.Schema
+}
+
+func (o *schemaOption) applyToHandler(config *handlerConfig) {
+	config.Schema = o.Schema
+}
+
+type procedureOptionsOption struct {
+	procedure      string
+	options        []Option
+	clientOptions  []ClientOption
+	handlerOptions []HandlerOption
+}
+
+func (o *procedureOptionsOption) applyToClient(config *clientConfig) {
+	if config.Procedure != o.procedure {
+		return
+	}
+	for _, option := range o.options {
+		option.applyToClient(config)
+	}
+	for _, option := range o.clientOptions {
+		option.applyToClient(config)
+	}
+}
+
+func (o *procedureOptionsOption) applyToHandler(config *handlerConfig) {
+	if config.Procedure != o.procedure {
+		return
+	}
+	for _, option := range o.options {
+		option.applyToHandler(config)
+	}
+	for _, option := range o.handlerOptions {
+		option.applyToHandler(config)
+	}
+}
+
 type optionsOption struct {
 	options []Option
 }
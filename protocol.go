@@ -25,6 +25,14 @@ import (
 	"strings"
 )
 
+// The names of the Connect, gRPC, and gRPC-Web protocols (as exposed by
+// Peer.Protocol). Additional protocols may be added in the future.
+const (
+	ProtocolConnect = "connect"
+	ProtocolGRPC    = "grpc"
+	ProtocolGRPCWeb = "grpcweb"
+)
+
 const (
 	headerContentType = "Content-Type"
 	headerUserAgent   = "User-Agent"
@@ -66,6 +74,9 @@ type protocolHandlerParams struct {
 	CompressMinBytes int
 	BufferPool       *bufferPool
 	ReadMaxBytes     int
+	ErrorClassifiers []ErrorClassifier
+	RedactError      func(error) error
+	StatsHandler     StatsHandler
 }
 
 // Handler is the server side of a protocol. HTTP handlers typically support
@@ -100,6 +111,8 @@ type protocolClientParams struct {
 	URL              string
 	BufferPool       *bufferPool
 	ReadMaxBytes     int
+	ErrorClassifiers []ErrorClassifier
+	StatsHandler     StatsHandler
 	// The gRPC family of protocols always needs access to a Protobuf codec to
 	// marshal and unmarshal errors.
 	Protobuf Codec
@@ -108,6 +121,9 @@ type protocolClientParams struct {
 // Client is the client side of a protocol. HTTP clients typically use a single
 // protocol, codec, and compressor to send requests.
 type protocolClient interface {
+	// Peer describes the server for the RPC.
+	Peer() Peer
+
 	// WriteRequestHeader writes any protocol-specific request headers.
 	WriteRequestHeader(StreamType, http.Header)
 
@@ -122,17 +138,26 @@ type protocolClient interface {
 
 // errorTranslatingHandlerConnCloser wraps a handlerConnCloser to ensure that
 // we always return coded errors to users and write coded errors to the
-// network.
+// network. It also reports the header, trailer, and end events for the
+// StatsHandler, if any.
 //
 // It's used in protocol implementations.
 type errorTranslatingHandlerConnCloser struct {
 	handlerConnCloser
 
-	toWire   func(error) error
-	fromWire func(error) error
+	toWire      func(error) error
+	fromWire    func(error) error
+	stats       *streamStats
+	wroteHeader bool
+}
+
+// Peer implements PeerReporter.
+func (hc *errorTranslatingHandlerConnCloser) Peer() Peer {
+	return PeerOf(hc.handlerConnCloser)
 }
 
 func (hc *errorTranslatingHandlerConnCloser) Send(msg any) error {
+	hc.reportHeader()
 	return hc.fromWire(hc.handlerConnCloser.Send(msg))
 }
 
@@ -141,18 +166,42 @@ func (hc *errorTranslatingHandlerConnCloser) Receive(msg any) error {
 }
 
 func (hc *errorTranslatingHandlerConnCloser) Close(err error) error {
-	closeErr := hc.handlerConnCloser.Close(hc.toWire(err))
+	err = hc.toWire(err)
+	hc.reportHeader()
+	hc.stats.OutTrailer(hc.ResponseTrailer())
+	closeErr := hc.handlerConnCloser.Close(err)
+	hc.stats.End(err)
 	return hc.fromWire(closeErr)
 }
 
+// reportHeader reports the response headers the first time they're about to
+// be written.
+func (hc *errorTranslatingHandlerConnCloser) reportHeader() {
+	if hc.wroteHeader {
+		return
+	}
+	hc.wroteHeader = true
+	hc.stats.OutHeader(hc.ResponseHeader())
+}
+
 // errorTranslatingClientConn wraps a StreamingClientConn to make sure that we always
-// return coded errors from clients.
+// return coded errors from clients. It also reports the trailer and end events
+// for the StatsHandler, if any.
 //
 // It's used in protocol implementations.
 type errorTranslatingClientConn struct {
 	StreamingClientConn
 
-	fromWire func(error) error
+	fromWire    func(error) error
+	stats       *streamStats
+	receiveErr  error
+	readTrailer bool
+	reportedEnd bool
+}
+
+// Peer implements PeerReporter.
+func (cc *errorTranslatingClientConn) Peer() Peer {
+	return PeerOf(cc.StreamingClientConn)
 }
 
 func (cc *errorTranslatingClientConn) Send(msg any) error {
@@ -160,7 +209,16 @@ func (cc *errorTranslatingClientConn) Send(msg any) error {
 }
 
 func (cc *errorTranslatingClientConn) Receive(msg any) error {
-	return cc.fromWire(cc.StreamingClientConn.Receive(msg))
+	err := cc.fromWire(cc.StreamingClientConn.Receive(msg))
+	if err != nil && !cc.readTrailer {
+		// The response is complete, so trailers are available.
+		cc.readTrailer = true
+		if !errors.Is(err, io.EOF) {
+			cc.receiveErr = err
+		}
+		cc.stats.InTrailer(cc.ResponseTrailer())
+	}
+	return err
 }
 
 func (cc *errorTranslatingClientConn) CloseRequest() error {
@@ -168,26 +226,89 @@ func (cc *errorTranslatingClientConn) CloseRequest() error {
 }
 
 func (cc *errorTranslatingClientConn) CloseResponse() error {
-	return cc.fromWire(cc.StreamingClientConn.CloseResponse())
+	err := cc.fromWire(cc.StreamingClientConn.CloseResponse())
+	if !cc.reportedEnd {
+		cc.reportedEnd = true
+		endErr := cc.receiveErr
+		if endErr == nil {
+			endErr = err
+		}
+		cc.stats.End(endErr)
+	}
+	return err
 }
 
 // wrapHandlerConnWithCodedErrors ensures that we (1) automatically code
-// context-related errors correctly when writing them to the network, and (2)
-// return *Errors from all exported APIs.
-func wrapHandlerConnWithCodedErrors(conn handlerConnCloser) handlerConnCloser {
+// context-related errors and errors recognized by the user-supplied
+// classifiers correctly when writing them to the network, (2) give the
+// user-supplied redaction function, if any, a chance to rewrite errors before
+// they're written, and (3) return *Errors from all exported APIs.
+func wrapHandlerConnWithCodedErrors(
+	conn handlerConnCloser,
+	stats *streamStats,
+	classifiers []ErrorClassifier,
+	redact func(error) error,
+) handlerConnCloser {
+	toWire := func(err error) error {
+		err = classifyError(wrapIfContextError(err), classifiers)
+		if err == nil || redact == nil {
+			return err
+		}
+		if redacted := redact(err); redacted != nil {
+			return redacted
+		}
+		return err
+	}
+	stats.Begin(conn.Spec(), PeerOf(conn))
+	stats.InHeader(conn.RequestHeader())
 	return &errorTranslatingHandlerConnCloser{
 		handlerConnCloser: conn,
-		toWire:            wrapIfContextError,
+		toWire:            toWire,
 		fromWire:          wrapIfUncoded,
+		stats:             stats,
 	}
 }
 
 // wrapClientConnWithCodedErrors ensures that we always return *Errors from
-// public APIs.
-func wrapClientConnWithCodedErrors(conn StreamingClientConn) StreamingClientConn {
+// public APIs, and that those *Errors match the domain errors rebuilt by the
+// user-supplied classifiers.
+func wrapClientConnWithCodedErrors(
+	conn StreamingClientConn,
+	stats *streamStats,
+	classifiers []ErrorClassifier,
+) StreamingClientConn {
+	fromWire := wrapIfUncoded
+	if len(classifiers) > 0 {
+		fromWire = func(err error) error {
+			return unclassifyError(wrapIfUncoded(err), classifiers)
+		}
+	}
+	stats.Begin(conn.Spec(), PeerOf(conn))
 	return &errorTranslatingClientConn{
 		StreamingClientConn: conn,
-		fromWire:            wrapIfUncoded,
+		fromWire:            fromWire,
+		stats:               stats,
+	}
+}
+
+// newPeerFromURL creates a Peer describing a server, given the server's URL.
+func newPeerFromURL(rawURL, protocol string) Peer {
+	peer := Peer{Protocol: protocol, HTTPMethod: http.MethodPost}
+	if parsed, err := url.Parse(rawURL); err == nil {
+		peer.Addr = parsed.Host
+		peer.URL = parsed
+	}
+	return peer
+}
+
+// newPeerFromRequest creates a Peer describing a client, given the client's
+// *http.Request.
+func newPeerFromRequest(request *http.Request, protocol string) Peer {
+	return Peer{
+		Addr:       request.RemoteAddr,
+		Protocol:   protocol,
+		HTTPMethod: request.Method,
+		URL:        request.URL,
 	}
 }
 
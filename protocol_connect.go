@@ -69,7 +69,10 @@ func (*protocolConnect) NewClient(params *protocolClientParams) (protocolClient,
 	if err := validateRequestURL(params.URL); err != nil {
 		return nil, err
 	}
-	return &connectClient{protocolClientParams: *params}, nil
+	return &connectClient{
+		protocolClientParams: *params,
+		peer:                 newPeerFromURL(params.URL, ProtocolConnect),
+	}, nil
 }
 
 type connectHandler struct {
@@ -149,10 +152,14 @@ func (h *connectHandler) NewConn(
 	)
 	codec := h.Codecs.Get(codecName) // handler.go guarantees this is not nil
 
+	peer := newPeerFromRequest(request, ProtocolConnect)
+	stats := newStreamStats(request.Context(), h.StatsHandler, false /* client */)
 	var conn handlerConnCloser
 	if h.Spec.StreamType == StreamTypeUnary {
 		conn = &connectUnaryHandlerConn{
 			spec:           h.Spec,
+			peer:           peer,
+			stats:          stats,
 			request:        request,
 			responseWriter: responseWriter,
 			marshaler: connectUnaryMarshaler{
@@ -163,6 +170,7 @@ func (h *connectHandler) NewConn(
 				compressionPool:  h.CompressionPools.Get(responseCompression),
 				bufferPool:       h.BufferPool,
 				header:           responseWriter.Header(),
+				stats:            stats,
 			},
 			unmarshaler: connectUnaryUnmarshaler{
 				reader:          request.Body,
@@ -170,12 +178,15 @@ func (h *connectHandler) NewConn(
 				compressionPool: h.CompressionPools.Get(requestCompression),
 				bufferPool:      h.BufferPool,
 				readMaxBytes:    h.ReadMaxBytes,
+				stats:           stats,
 			},
 			responseTrailer: make(http.Header),
 		}
 	} else {
 		conn = &connectStreamingHandlerConn{
 			spec:           h.Spec,
+			peer:           peer,
+			stats:          stats,
 			request:        request,
 			responseWriter: responseWriter,
 			marshaler: connectStreamingMarshaler{
@@ -185,6 +196,7 @@ func (h *connectHandler) NewConn(
 					compressMinBytes: h.CompressMinBytes,
 					compressionPool:  h.CompressionPools.Get(responseCompression),
 					bufferPool:       h.BufferPool,
+					stats:            stats,
 				},
 			},
 			unmarshaler: connectStreamingUnmarshaler{
@@ -194,12 +206,13 @@ func (h *connectHandler) NewConn(
 					compressionPool: h.CompressionPools.Get(requestCompression),
 					bufferPool:      h.BufferPool,
 					readMaxBytes:    h.ReadMaxBytes,
+					stats:           stats,
 				},
 			},
 			responseTrailer: make(http.Header),
 		}
 	}
-	conn = wrapHandlerConnWithCodedErrors(conn)
+	conn = wrapHandlerConnWithCodedErrors(conn, stats, h.ErrorClassifiers, h.RedactError)
 	// We can't return failed as-is: a nil *Error is non-nil when returned as an
 	// error interface.
 	if failed != nil {
@@ -212,6 +225,12 @@ func (h *connectHandler) NewConn(
 
 type connectClient struct {
 	protocolClientParams
+
+	peer Peer
+}
+
+func (c *connectClient) Peer() Peer {
+	return c.peer
 }
 
 func (c *connectClient) WriteRequestHeader(streamType StreamType, header http.Header) {
@@ -255,11 +274,14 @@ func (c *connectClient) NewConn(
 			} // else effectively unbounded
 		}
 	}
-	duplexCall := newDuplexHTTPCall(ctx, c.HTTPClient, c.URL, spec, header)
+	stats := newStreamStats(ctx, c.StatsHandler, true /* client */)
+	duplexCall := newDuplexHTTPCall(ctx, c.HTTPClient, c.URL, spec, header, stats)
 	var conn StreamingClientConn
 	if spec.StreamType == StreamTypeUnary {
 		unaryConn := &connectUnaryClientConn{
 			spec:             spec,
+			peer:             c.peer,
+			stats:            stats,
 			duplexCall:       duplexCall,
 			compressionPools: c.CompressionPools,
 			bufferPool:       c.BufferPool,
@@ -271,12 +293,14 @@ func (c *connectClient) NewConn(
 				compressionPool:  c.CompressionPools.Get(c.CompressionName),
 				bufferPool:       c.BufferPool,
 				header:           duplexCall.Header(),
+				stats:            stats,
 			},
 			unmarshaler: connectUnaryUnmarshaler{
 				reader:       duplexCall,
 				codec:        c.Codec,
 				bufferPool:   c.BufferPool,
 				readMaxBytes: c.ReadMaxBytes,
+				stats:        stats,
 			},
 			responseHeader:  make(http.Header),
 			responseTrailer: make(http.Header),
@@ -286,6 +310,8 @@ func (c *connectClient) NewConn(
 	} else {
 		streamingConn := &connectStreamingClientConn{
 			spec:             spec,
+			peer:             c.peer,
+			stats:            stats,
 			duplexCall:       duplexCall,
 			compressionPools: c.CompressionPools,
 			bufferPool:       c.BufferPool,
@@ -297,6 +323,7 @@ func (c *connectClient) NewConn(
 					compressMinBytes: c.CompressMinBytes,
 					compressionPool:  c.CompressionPools.Get(c.CompressionName),
 					bufferPool:       c.BufferPool,
+					stats:            stats,
 				},
 			},
 			unmarshaler: connectStreamingUnmarshaler{
@@ -305,6 +332,7 @@ func (c *connectClient) NewConn(
 					codec:        c.Codec,
 					bufferPool:   c.BufferPool,
 					readMaxBytes: c.ReadMaxBytes,
+					stats:        stats,
 				},
 			},
 			responseHeader:  make(http.Header),
@@ -313,11 +341,13 @@ func (c *connectClient) NewConn(
 		conn = streamingConn
 		duplexCall.SetValidateResponse(streamingConn.validateResponse)
 	}
-	return wrapClientConnWithCodedErrors(conn)
+	return wrapClientConnWithCodedErrors(conn, stats, c.ErrorClassifiers)
 }
 
 type connectUnaryClientConn struct {
 	spec             Spec
+	peer             Peer
+	stats            *streamStats
 	duplexCall       *duplexHTTPCall
 	compressionPools readOnlyCompressionPools
 	bufferPool       *bufferPool
@@ -331,6 +361,10 @@ func (cc *connectUnaryClientConn) Spec() Spec {
 	return cc.spec
 }
 
+func (cc *connectUnaryClientConn) Peer() Peer {
+	return cc.peer.clone()
+}
+
 func (cc *connectUnaryClientConn) Send(msg any) error {
 	if err := cc.marshaler.Marshal(msg); err != nil {
 		return err
@@ -413,6 +447,8 @@ func (cc *connectUnaryClientConn) validateResponse(response *http.Response) *Err
 
 type connectStreamingClientConn struct {
 	spec             Spec
+	peer             Peer
+	stats            *streamStats
 	duplexCall       *duplexHTTPCall
 	compressionPools readOnlyCompressionPools
 	bufferPool       *bufferPool
@@ -427,6 +463,10 @@ func (cc *connectStreamingClientConn) Spec() Spec {
 	return cc.spec
 }
 
+func (cc *connectStreamingClientConn) Peer() Peer {
+	return cc.peer.clone()
+}
+
 func (cc *connectStreamingClientConn) Send(msg any) error {
 	if err := cc.marshaler.Marshal(msg); err != nil {
 		return err
@@ -504,6 +544,8 @@ func (cc *connectStreamingClientConn) validateResponse(response *http.Response)
 
 type connectUnaryHandlerConn struct {
 	spec            Spec
+	peer            Peer
+	stats           *streamStats
 	request         *http.Request
 	responseWriter  http.ResponseWriter
 	marshaler       connectUnaryMarshaler
@@ -516,6 +558,10 @@ func (hc *connectUnaryHandlerConn) Spec() Spec {
 	return hc.spec
 }
 
+func (hc *connectUnaryHandlerConn) Peer() Peer {
+	return hc.peer.clone()
+}
+
 func (hc *connectUnaryHandlerConn) Receive(msg any) error {
 	if err := hc.unmarshaler.Unmarshal(msg); err != nil {
 		return err
@@ -586,6 +632,8 @@ func (hc *connectUnaryHandlerConn) writeResponseHeader(err error) {
 
 type connectStreamingHandlerConn struct {
 	spec            Spec
+	peer            Peer
+	stats           *streamStats
 	request         *http.Request
 	responseWriter  http.ResponseWriter
 	marshaler       connectStreamingMarshaler
@@ -597,6 +645,10 @@ func (hc *connectStreamingHandlerConn) Spec() Spec {
 	return hc.spec
 }
 
+func (hc *connectStreamingHandlerConn) Peer() Peer {
+	return hc.peer.clone()
+}
+
 func (hc *connectStreamingHandlerConn) Receive(msg any) error {
 	if err := hc.unmarshaler.Unmarshal(msg); err != nil {
 		// Clients may not send end-of-stream metadata, so we don't need to handle
@@ -717,18 +769,18 @@ type connectUnaryMarshaler struct {
 	compressionPool  *compressionPool
 	bufferPool       *bufferPool
 	header           http.Header
+	stats            *streamStats
 }
 
 func (m *connectUnaryMarshaler) Marshal(message any) *Error {
-	data, err := m.codec.Marshal(message)
+	uncompressed, err := marshalToBuffer(m.codec, m.bufferPool, message)
 	if err != nil {
 		return errorf(CodeInternal, "marshal message: %w", err)
 	}
-	// Can't avoid allocating the slice, but we can reuse it.
-	uncompressed := bytes.NewBuffer(data)
 	defer m.bufferPool.Put(uncompressed)
+	data := uncompressed.Bytes()
 	if len(data) < m.compressMinBytes || m.compressionPool == nil {
-		return m.write(data)
+		return m.write(message, data, len(data))
 	}
 	compressed := m.bufferPool.Get()
 	defer m.bufferPool.Put(compressed)
@@ -736,16 +788,17 @@ func (m *connectUnaryMarshaler) Marshal(message any) *Error {
 		return err
 	}
 	m.header.Set(connectUnaryHeaderCompression, m.compressionName)
-	return m.write(compressed.Bytes())
+	return m.write(message, compressed.Bytes(), len(data))
 }
 
-func (m *connectUnaryMarshaler) write(data []byte) *Error {
+func (m *connectUnaryMarshaler) write(message any, data []byte, uncompressedBytes int) *Error {
 	if _, err := m.writer.Write(data); err != nil {
 		if connectErr, ok := asError(err); ok {
 			return connectErr
 		}
 		return errorf(CodeUnknown, "write message: %w", err)
 	}
+	m.stats.Sent(message, len(data), uncompressedBytes)
 	return nil
 }
 
@@ -756,6 +809,7 @@ type connectUnaryUnmarshaler struct {
 	bufferPool      *bufferPool
 	alreadyRead     bool
 	readMaxBytes    int
+	stats           *streamStats
 }
 
 func (u *connectUnaryUnmarshaler) Unmarshal(message any) *Error {
@@ -789,6 +843,7 @@ func (u *connectUnaryUnmarshaler) UnmarshalFunc(message any, unmarshal func([]by
 		}
 		return errorf(CodeInvalidArgument, "message size %d is larger than configured max %d", bytesRead+discardedBytes, u.readMaxBytes)
 	}
+	wireBytes := data.Len()
 	if data.Len() > 0 && u.compressionPool != nil {
 		decompressed := u.bufferPool.Get()
 		defer u.bufferPool.Put(decompressed)
@@ -800,6 +855,7 @@ func (u *connectUnaryUnmarshaler) UnmarshalFunc(message any, unmarshal func([]by
 	if err := unmarshal(data.Bytes(), message); err != nil {
 		return errorf(CodeInvalidArgument, "unmarshal into %T: %w", message, err)
 	}
+	u.stats.Received(message, wireBytes, data.Len())
 	return nil
 }
 
@@ -105,6 +105,7 @@ func (g *protocolGRPC) NewClient(params *protocolClientParams) (protocolClient,
 	return &grpcClient{
 		protocolClientParams: *params,
 		web:                  g.web,
+		peer:                 newPeerFromURL(params.URL, grpcProtocolName(g.web)),
 	}, nil
 }
 
@@ -161,8 +162,11 @@ func (g *grpcHandler) NewConn(
 
 	codecName := grpcCodecFromContentType(g.web, request.Header.Get(headerContentType))
 	codec := g.Codecs.Get(codecName) // handler.go guarantees this is not nil
+	stats := newStreamStats(request.Context(), g.StatsHandler, false /* client */)
 	conn := wrapHandlerConnWithCodedErrors(&grpcHandlerConn{
 		spec:       g.Spec,
+		peer:       newPeerFromRequest(request, grpcProtocolName(g.web)),
+		stats:      stats,
 		web:        g.web,
 		bufferPool: g.BufferPool,
 		protobuf:   g.Codecs.Protobuf(), // for errors
@@ -173,6 +177,7 @@ func (g *grpcHandler) NewConn(
 				codec:            codec,
 				compressMinBytes: g.CompressMinBytes,
 				bufferPool:       g.BufferPool,
+				stats:            stats,
 			},
 		},
 		responseWriter:  responseWriter,
@@ -186,10 +191,11 @@ func (g *grpcHandler) NewConn(
 				compressionPool: g.CompressionPools.Get(requestCompression),
 				bufferPool:      g.BufferPool,
 				readMaxBytes:    g.ReadMaxBytes,
+				stats:           stats,
 			},
 			web: g.web,
 		},
-	})
+	}, stats, g.ErrorClassifiers, g.RedactError)
 	if failed != nil {
 		// Negotiation failed, so we can't establish a stream.
 		_ = conn.Close(failed)
@@ -198,10 +204,25 @@ func (g *grpcHandler) NewConn(
 	return conn, true
 }
 
+// WriteTrailersOnly sends an error without any response messages. gRPC calls
+// this a "trailers-only" response: the status goes in the HTTP headers, and
+// there's no body or HTTP trailers.
+func (g *grpcHandler) WriteTrailersOnly(responseWriter http.ResponseWriter, request *http.Request, err error) {
+	header := responseWriter.Header()
+	header[headerContentType] = []string{request.Header.Get(headerContentType)}
+	grpcErrorToTrailer(g.BufferPool, header, g.Codecs.Protobuf(), err)
+	responseWriter.WriteHeader(http.StatusOK)
+}
+
 type grpcClient struct {
 	protocolClientParams
 
-	web bool
+	web  bool
+	peer Peer
+}
+
+func (g *grpcClient) Peer() Peer {
+	return g.peer
 }
 
 func (g *grpcClient) WriteRequestHeader(_ StreamType, header http.Header) {
@@ -238,15 +259,19 @@ func (g *grpcClient) NewConn(
 			header[grpcHeaderTimeout] = []string{encodedDeadline}
 		}
 	}
+	stats := newStreamStats(ctx, g.StatsHandler, true /* client */)
 	duplexCall := newDuplexHTTPCall(
 		ctx,
 		g.HTTPClient,
 		g.URL,
 		spec,
 		header,
+		stats,
 	)
 	conn := &grpcClientConn{
 		spec:             spec,
+		peer:             g.peer,
+		stats:            stats,
 		duplexCall:       duplexCall,
 		compressionPools: g.CompressionPools,
 		bufferPool:       g.BufferPool,
@@ -258,6 +283,7 @@ func (g *grpcClient) NewConn(
 				codec:            g.Codec,
 				compressMinBytes: g.CompressMinBytes,
 				bufferPool:       g.BufferPool,
+				stats:            stats,
 			},
 		},
 		unmarshaler: grpcUnmarshaler{
@@ -266,6 +292,7 @@ func (g *grpcClient) NewConn(
 				codec:        g.Codec,
 				bufferPool:   g.BufferPool,
 				readMaxBytes: g.ReadMaxBytes,
+				stats:        stats,
 			},
 		},
 		responseHeader:  make(http.Header),
@@ -284,12 +311,14 @@ func (g *grpcClient) NewConn(
 			return call.ResponseTrailer()
 		}
 	}
-	return wrapClientConnWithCodedErrors(conn)
+	return wrapClientConnWithCodedErrors(conn, stats, g.ErrorClassifiers)
 }
 
 // grpcClientConn works for both gRPC and gRPC-Web.
 type grpcClientConn struct {
 	spec             Spec
+	peer             Peer
+	stats            *streamStats
 	duplexCall       *duplexHTTPCall
 	compressionPools readOnlyCompressionPools
 	bufferPool       *bufferPool
@@ -305,6 +334,10 @@ func (cc *grpcClientConn) Spec() Spec {
 	return cc.spec
 }
 
+func (cc *grpcClientConn) Peer() Peer {
+	return cc.peer.clone()
+}
+
 func (cc *grpcClientConn) Send(msg any) error {
 	if err := cc.marshaler.Marshal(msg); err != nil {
 		return err
@@ -391,6 +424,8 @@ func (cc *grpcClientConn) validateResponse(response *http.Response) *Error {
 
 type grpcHandlerConn struct {
 	spec            Spec
+	peer            Peer
+	stats           *streamStats
 	web             bool
 	bufferPool      *bufferPool
 	protobuf        Codec // for errors
@@ -407,6 +442,10 @@ func (hc *grpcHandlerConn) Spec() Spec {
 	return hc.spec
 }
 
+func (hc *grpcHandlerConn) Peer() Peer {
+	return hc.peer.clone()
+}
+
 func (hc *grpcHandlerConn) Receive(msg any) error {
 	if err := hc.unmarshaler.Unmarshal(msg); err != nil {
 		return err // already coded
@@ -723,6 +762,13 @@ func grpcUserAgent() string {
 	return fmt.Sprintf("grpc-go-connect/%s (%s)", Version, runtime.Version())
 }
 
+func grpcProtocolName(web bool) string {
+	if web {
+		return ProtocolGRPCWeb
+	}
+	return ProtocolGRPC
+}
+
 func grpcCodecFromContentType(web bool, contentType string) string {
 	if (!web && contentType == grpcContentTypeDefault) || (web && contentType == grpcWebContentTypeDefault) {
 		// implicitly protobuf
@@ -17,8 +17,43 @@ package connect
 import (
 	"context"
 	"net/http"
+	"runtime/debug"
+	"sync/atomic"
 )
 
+// A RecoveredPanic describes a panic recovered by an interceptor installed
+// with WithRecoverPanic or NewClientRecoverInterceptor.
+type RecoveredPanic struct {
+	// Spec describes the RPC that panicked.
+	Spec Spec
+	// Header is the request header. Handler interceptors see the headers sent
+	// by the client, and client interceptors see the headers sent so far.
+	Header http.Header
+	// Value is the value passed to panic. It may be nil.
+	Value any
+	// Stack is the stack trace of the panicking goroutine, formatted as by
+	// runtime/debug.Stack.
+	Stack []byte
+	// MessagesReceived and MessagesSent count the streaming messages received
+	// and sent before the panic. They're always zero for unary RPCs.
+	MessagesReceived int
+	MessagesSent     int
+}
+
+// NewClientRecoverInterceptor returns an Interceptor that recovers from
+// panics in the client-side interceptors and HTTP transports wrapped by it.
+// The supplied function receives the context and a description of the panic,
+// and must return an error to return to the caller. If it returns nil, the
+// caller gets a CodeInternal error instead. It must be safe to call
+// concurrently.
+//
+// Interceptors added before this one aren't protected, so it's usually the
+// first interceptor in the chain. As with WithRecoverPanic, panics with
+// http.ErrAbortHandler are propagated.
+func NewClientRecoverInterceptor(handle func(context.Context, *RecoveredPanic) error) Interceptor {
+	return &recoverClientInterceptor{handle: handle}
+}
+
 // recoverHandlerInterceptor lets handlers trap panics, perform side effects
 // (like emitting logs or metrics), and present a friendlier error message to
 // clients.
@@ -35,7 +70,7 @@ import (
 type recoverHandlerInterceptor struct {
 	Interceptor
 
-	handle func(context.Context, Spec, http.Header, any) error
+	handle func(context.Context, *RecoveredPanic) error
 }
 
 func (i *recoverHandlerInterceptor) WrapUnary(next UnaryFunc) UnaryFunc {
@@ -51,7 +86,12 @@ func (i *recoverHandlerInterceptor) WrapUnary(next UnaryFunc) UnaryFunc {
 				if r == http.ErrAbortHandler { // nolint:errorlint,goerr113
 					panic(r) // nolint:forbidigo
 				}
-				retErr = i.handle(ctx, req.Spec(), req.Header(), r)
+				retErr = i.handle(ctx, &RecoveredPanic{
+					Spec:   req.Spec(),
+					Header: req.Header(),
+					Value:  r,
+					Stack:  debug.Stack(),
+				})
 			}
 		}()
 		res, err := next(ctx, req)
@@ -62,6 +102,7 @@ func (i *recoverHandlerInterceptor) WrapUnary(next UnaryFunc) UnaryFunc {
 
 func (i *recoverHandlerInterceptor) WrapStreamingHandler(next StreamingHandlerFunc) StreamingHandlerFunc {
 	return func(ctx context.Context, conn StreamingHandlerConn) (retErr error) { // nolint:nonamedreturns
+		counter := &messageCountingHandlerConn{StreamingHandlerConn: conn}
 		panicked := true
 		defer func() {
 			if panicked {
@@ -70,11 +111,215 @@ func (i *recoverHandlerInterceptor) WrapStreamingHandler(next StreamingHandlerFu
 				if r == http.ErrAbortHandler { // nolint:errorlint,goerr113
 					panic(r) // nolint:forbidigo
 				}
-				retErr = i.handle(ctx, Spec{}, nil, r)
+				retErr = i.handle(ctx, &RecoveredPanic{
+					Spec:             conn.Spec(),
+					Header:           conn.RequestHeader(),
+					Value:            r,
+					Stack:            debug.Stack(),
+					MessagesReceived: int(atomic.LoadInt64(&counter.received)),
+					MessagesSent:     int(atomic.LoadInt64(&counter.sent)),
+				})
 			}
 		}()
-		err := next(ctx, conn)
+		err := next(ctx, counter)
 		panicked = false
 		return err
 	}
 }
+
+// recoverClientInterceptor is the client-side counterpart of
+// recoverHandlerInterceptor. It uses the same recovery strategy.
+type recoverClientInterceptor struct {
+	handle func(context.Context, *RecoveredPanic) error
+}
+
+func (i *recoverClientInterceptor) WrapUnary(next UnaryFunc) UnaryFunc {
+	return func(ctx context.Context, req AnyRequest) (_ AnyResponse, retErr error) { // nolint:nonamedreturns
+		if !req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		panicked := true
+		defer func() {
+			if panicked {
+				retErr = i.recovered(ctx, req.Spec(), req.Header(), nil, recover())
+			}
+		}()
+		res, err := next(ctx, req)
+		panicked = false
+		return res, err
+	}
+}
+
+func (i *recoverClientInterceptor) WrapStreamingClient(next StreamingClientFunc) StreamingClientFunc {
+	return func(ctx context.Context, spec Spec) (conn StreamingClientConn) { // nolint:nonamedreturns
+		panicked := true
+		defer func() {
+			if panicked {
+				err := i.recovered(ctx, spec, nil, nil, recover())
+				conn = &panickedClientConn{spec: spec, err: err}
+			}
+		}()
+		conn = &recoverClientConn{
+			StreamingClientConn: next(ctx, spec),
+			ctx:                 ctx,
+			interceptor:         i,
+		}
+		panicked = false
+		return conn
+	}
+}
+
+func (i *recoverClientInterceptor) WrapStreamingHandler(next StreamingHandlerFunc) StreamingHandlerFunc {
+	return next
+}
+
+func (i *recoverClientInterceptor) recovered(
+	ctx context.Context,
+	spec Spec,
+	header http.Header,
+	counter *recoverClientConn,
+	r any,
+) error {
+	// net/http checks for ErrAbortHandler with ==, so we should too.
+	if r == http.ErrAbortHandler { // nolint:errorlint,goerr113
+		panic(r) // nolint:forbidigo
+	}
+	recovered := &RecoveredPanic{
+		Spec:   spec,
+		Header: header,
+		Value:  r,
+		Stack:  debug.Stack(),
+	}
+	if counter != nil {
+		recovered.MessagesReceived = int(atomic.LoadInt64(&counter.received))
+		recovered.MessagesSent = int(atomic.LoadInt64(&counter.sent))
+	}
+	if err := i.handle(ctx, recovered); err != nil {
+		return err
+	}
+	// Callers must not mistake a panic for success.
+	return errorf(CodeInternal, "panic: %v", r)
+}
+
+// messageCountingHandlerConn counts the messages sent and received on a
+// stream. Send and Receive may be called concurrently, so the counts are
+// updated atomically.
+type messageCountingHandlerConn struct {
+	StreamingHandlerConn
+
+	received int64
+	sent     int64
+}
+
+// Peer implements PeerReporter.
+func (hc *messageCountingHandlerConn) Peer() Peer {
+	return PeerOf(hc.StreamingHandlerConn)
+}
+
+func (hc *messageCountingHandlerConn) Receive(msg any) error {
+	if err := hc.StreamingHandlerConn.Receive(msg); err != nil {
+		return err
+	}
+	atomic.AddInt64(&hc.received, 1)
+	return nil
+}
+
+func (hc *messageCountingHandlerConn) Send(msg any) error {
+	if err := hc.StreamingHandlerConn.Send(msg); err != nil {
+		return err
+	}
+	atomic.AddInt64(&hc.sent, 1)
+	return nil
+}
+
+// recoverClientConn recovers from panics in the methods of the wrapped
+// StreamingClientConn, converting them to errors.
+type recoverClientConn struct {
+	StreamingClientConn
+
+	ctx         context.Context // nolint:containedctx
+	interceptor *recoverClientInterceptor
+	received    int64
+	sent        int64
+}
+
+// Peer implements PeerReporter.
+func (cc *recoverClientConn) Peer() Peer {
+	return PeerOf(cc.StreamingClientConn)
+}
+
+func (cc *recoverClientConn) Send(msg any) (retErr error) { // nolint:nonamedreturns
+	panicked := true
+	defer func() {
+		if panicked {
+			retErr = cc.recovered(recover())
+		}
+	}()
+	err := cc.StreamingClientConn.Send(msg)
+	panicked = false
+	if err == nil {
+		atomic.AddInt64(&cc.sent, 1)
+	}
+	return err
+}
+
+func (cc *recoverClientConn) Receive(msg any) (retErr error) { // nolint:nonamedreturns
+	panicked := true
+	defer func() {
+		if panicked {
+			retErr = cc.recovered(recover())
+		}
+	}()
+	err := cc.StreamingClientConn.Receive(msg)
+	panicked = false
+	if err == nil {
+		atomic.AddInt64(&cc.received, 1)
+	}
+	return err
+}
+
+func (cc *recoverClientConn) CloseRequest() (retErr error) { // nolint:nonamedreturns
+	panicked := true
+	defer func() {
+		if panicked {
+			retErr = cc.recovered(recover())
+		}
+	}()
+	err := cc.StreamingClientConn.CloseRequest()
+	panicked = false
+	return err
+}
+
+func (cc *recoverClientConn) CloseResponse() (retErr error) { // nolint:nonamedreturns
+	panicked := true
+	defer func() {
+		if panicked {
+			retErr = cc.recovered(recover())
+		}
+	}()
+	err := cc.StreamingClientConn.CloseResponse()
+	panicked = false
+	return err
+}
+
+func (cc *recoverClientConn) recovered(r any) error {
+	return cc.interceptor.recovered(cc.ctx, cc.Spec(), cc.RequestHeader(), cc, r)
+}
+
+// panickedClientConn is returned when constructing a StreamingClientConn
+// panics. All its I/O methods return the error produced by the recover
+// interceptor.
+type panickedClientConn struct {
+	spec Spec
+	err  error
+}
+
+func (cc *panickedClientConn) Spec() Spec                   { return cc.spec }
+func (cc *panickedClientConn) Peer() Peer                   { return Peer{} }
+func (cc *panickedClientConn) Send(any) error               { return cc.err }
+func (cc *panickedClientConn) RequestHeader() http.Header   { return make(http.Header) }
+func (cc *panickedClientConn) CloseRequest() error          { return cc.err }
+func (cc *panickedClientConn) Receive(any) error            { return cc.err }
+func (cc *panickedClientConn) ResponseHeader() http.Header  { return make(http.Header) }
+func (cc *panickedClientConn) ResponseTrailer() http.Header { return make(http.Header) }
+func (cc *panickedClientConn) CloseResponse() error         { return cc.err }
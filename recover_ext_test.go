@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/bufbuild/connect-go"
@@ -106,3 +107,157 @@ func TestWithRecover(t *testing.T) {
 	assert.Nil(t, err)
 	assertNotHandled(drainStream(stream))
 }
+
+func TestWithRecoverPanic(t *testing.T) {
+	t.Parallel()
+	reports := make(chan *connect.RecoveredPanic, 1)
+	handle := func(_ context.Context, recovered *connect.RecoveredPanic) error {
+		reports <- recovered
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("panic: %v", recovered.Value))
+	}
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		&panicPingServer{panicWith: "oops"},
+		connect.WithRecoverPanic(handle),
+	))
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	client := pingv1connect.NewPingServiceClient(server.Client(), server.URL)
+
+	request := connect.NewRequest(&pingv1.CountUpRequest{})
+	request.Header().Set(clientHeader, headerValue)
+	stream, err := client.CountUp(context.Background(), request)
+	assert.Nil(t, err)
+	assert.True(t, stream.Receive())
+	assert.False(t, stream.Receive())
+	assert.Equal(t, connect.CodeOf(stream.Err()), connect.CodeInternal)
+	assert.Nil(t, stream.Close())
+
+	recovered := <-reports
+	assert.Equal(t, recovered.Spec.Procedure, "/connect.ping.v1.PingService/CountUp")
+	assert.Equal(t, recovered.Spec.StreamType, connect.StreamTypeServer)
+	assert.Equal(t, recovered.Header.Get(clientHeader), headerValue)
+	assert.Equal(t, recovered.Value, any("oops"))
+	assert.True(t, strings.Contains(string(recovered.Stack), "CountUp"))
+	assert.Equal(t, recovered.MessagesReceived, 1)
+	assert.Equal(t, recovered.MessagesSent, 1)
+}
+
+func TestClientRecoverInterceptor(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(pingServer{}))
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	reports := make(chan *connect.RecoveredPanic, 2)
+	recoverInterceptor := connect.NewClientRecoverInterceptor(
+		func(_ context.Context, recovered *connect.RecoveredPanic) error {
+			reports <- recovered
+			return connect.NewError(connect.CodeInternal, fmt.Errorf("panic: %v", recovered.Value))
+		},
+	)
+	client := pingv1connect.NewPingServiceClient(
+		server.Client(),
+		server.URL,
+		connect.WithInterceptors(recoverInterceptor, panicClientInterceptor{}),
+	)
+
+	request := connect.NewRequest(&pingv1.PingRequest{})
+	request.Header().Set(clientHeader, headerValue)
+	_, err := client.Ping(context.Background(), request)
+	assert.Equal(t, connect.CodeOf(err), connect.CodeInternal)
+	recovered := <-reports
+	assert.Equal(t, recovered.Spec.Procedure, "/connect.ping.v1.PingService/Ping")
+	assert.True(t, recovered.Spec.IsClient)
+	assert.Equal(t, recovered.Header.Get(clientHeader), headerValue)
+	assert.Equal(t, recovered.Value, any("unary"))
+
+	stream := client.Sum(context.Background())
+	assert.Nil(t, stream.Send(&pingv1.SumRequest{Number: 1}))
+	err = stream.Send(&pingv1.SumRequest{Number: 2})
+	assert.Equal(t, connect.CodeOf(err), connect.CodeInternal)
+	_, _ = stream.CloseAndReceive()
+	recovered = <-reports
+	assert.Equal(t, recovered.Spec.Procedure, "/connect.ping.v1.PingService/Sum")
+	assert.Equal(t, recovered.Value, any("send"))
+	assert.Equal(t, recovered.MessagesSent, 1)
+	assert.NotZero(t, len(recovered.Stack))
+}
+
+func TestClientRecoverInterceptorNilError(t *testing.T) {
+	t.Parallel()
+	recoverInterceptor := connect.NewClientRecoverInterceptor(
+		func(context.Context, *connect.RecoveredPanic) error { return nil },
+	)
+	client := pingv1connect.NewPingServiceClient(
+		http.DefaultClient,
+		"http://invalid.test", // every call panics before sending a request
+		connect.WithInterceptors(recoverInterceptor, panicConstructClientInterceptor{}),
+	)
+	_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+	assert.Equal(t, connect.CodeOf(err), connect.CodeInternal)
+
+	stream := client.Sum(context.Background())
+	err = stream.Send(&pingv1.SumRequest{Number: 1})
+	assert.Equal(t, connect.CodeOf(err), connect.CodeInternal)
+	_, err = stream.CloseAndReceive()
+	assert.Equal(t, connect.CodeOf(err), connect.CodeInternal)
+}
+
+// panicConstructClientInterceptor panics in unary calls and while
+// constructing streams.
+type panicConstructClientInterceptor struct{}
+
+func (panicConstructClientInterceptor) WrapUnary(connect.UnaryFunc) connect.UnaryFunc {
+	return func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		panic("unary") // nolint:forbidigo
+	}
+}
+
+func (panicConstructClientInterceptor) WrapStreamingClient(connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(context.Context, connect.Spec) connect.StreamingClientConn {
+		panic("construct") // nolint:forbidigo
+	}
+}
+
+func (panicConstructClientInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+// panicClientInterceptor panics in unary calls and on the second message sent
+// on a stream.
+type panicClientInterceptor struct{}
+
+func (panicClientInterceptor) WrapUnary(connect.UnaryFunc) connect.UnaryFunc {
+	return func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		panic("unary") // nolint:forbidigo
+	}
+}
+
+func (panicClientInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		return &panicClientConn{StreamingClientConn: next(ctx, spec)}
+	}
+}
+
+func (panicClientInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+type panicClientConn struct {
+	connect.StreamingClientConn
+
+	sent int
+}
+
+func (cc *panicClientConn) Send(msg any) error {
+	cc.sent++
+	if cc.sent > 1 {
+		panic("send") // nolint:forbidigo
+	}
+	return cc.StreamingClientConn.Send(msg)
+}
@@ -0,0 +1,186 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Router is an http.Handler that dispatches RPCs by their exact procedure
+// path. Unlike http.ServeMux, it doesn't do any pattern matching, and it
+// answers calls to unknown procedures with a CodeUnimplemented error in the
+// client's RPC protocol rather than a plain-text 404.
+//
+// Routers may be modified while they're serving requests, so services can be
+// added and removed at runtime. Generated service constructors return a Router
+// mounted on the service's path prefix, which may be registered directly on
+// another Router:
+//
+//   router := connect.NewRouter()
+//   router.Handle(pingv1connect.NewPingServiceHandler(&pingServer{}))
+type Router struct {
+	notFound http.Handler
+
+	mu       sync.RWMutex
+	handlers map[string]http.Handler // exact procedure paths
+	services map[string]http.Handler // service prefixes, ending in "/"
+}
+
+// NewRouter constructs an empty Router. Calls to unknown procedures are
+// answered by a NotFoundHandler built with the supplied options; the options
+// don't affect the registered handlers.
+func NewRouter(options ...HandlerOption) *Router {
+	return &Router{
+		notFound: NotFoundHandler(options...),
+		handlers: make(map[string]http.Handler),
+		services: make(map[string]http.Handler),
+	}
+}
+
+// Handle registers the handler for a path. Paths ending in a slash, like
+// "/acme.foo.v1.FooService/", register a handler for every procedure in a
+// service. All other paths must exactly match a procedure, like
+// "/acme.foo.v1.FooService/Bar". Procedures take precedence over services.
+//
+// Registering a path again replaces the earlier handler. Handle panics if the
+// path is empty or the handler is nil.
+func (r *Router) Handle(path string, handler http.Handler) {
+	if path == "" {
+		panic("connect: empty path")
+	}
+	if handler == nil {
+		panic("connect: nil handler for " + path)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if strings.HasSuffix(path, "/") {
+		r.services[path] = handler
+		return
+	}
+	r.handlers[path] = handler
+}
+
+// Remove unregisters the handler for a path, which must match the path passed
+// to Handle. It reports whether a handler was removed. Requests already in
+// flight aren't affected.
+func (r *Router) Remove(path string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	registered := r.handlers
+	if strings.HasSuffix(path, "/") {
+		registered = r.services
+	}
+	if _, ok := registered[path]; !ok {
+		return false
+	}
+	delete(registered, path)
+	return true
+}
+
+// ServeHTTP implements http.Handler.
+func (r *Router) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	if handler := r.lookup(request.URL.Path); handler != nil {
+		handler.ServeHTTP(responseWriter, request)
+		return
+	}
+	r.notFound.ServeHTTP(responseWriter, request)
+}
+
+func (r *Router) lookup(path string) http.Handler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if handler, ok := r.handlers[path]; ok {
+		return handler
+	}
+	if len(r.services) == 0 {
+		return nil
+	}
+	if slash := strings.LastIndexByte(path, '/'); slash >= 0 {
+		return r.services[path[:slash+1]]
+	}
+	return nil
+}
+
+// NotFoundHandler returns an http.Handler that responds to every RPC with a
+// CodeUnimplemented error, encoded for the client's protocol. Like Handler, it
+// detects the protocol from the request's Content-Type. gRPC and gRPC-Web
+// errors are sent as trailers-only responses, with the status in the HTTP
+// headers. Requests that don't look like RPCs get a plain-text 404.
+//
+// It's useful as the fallback handler for http.ServeMux and other routers:
+//
+//   mux := http.NewServeMux()
+//   mux.Handle(pingv1connect.NewPingServiceHandler(&pingServer{}))
+//   mux.Handle("/", connect.NotFoundHandler())
+//
+// The options configure the codecs, compressors, and protocols to support;
+// interceptors are ignored.
+func NotFoundHandler(options ...HandlerOption) http.Handler {
+	config := newHandlerConfig("", options)
+	return &notFoundHandler{
+		unaryHandlers: config.newProtocolHandlers(StreamTypeUnary),
+		// Bidi handlers would reject HTTP/1.1 requests, and the error is the
+		// same for every kind of stream.
+		streamHandlers: config.newProtocolHandlers(StreamTypeServer),
+	}
+}
+
+// trailersOnlyWriter is implemented by protocol handlers that can send an
+// error without establishing a stream.
+type trailersOnlyWriter interface {
+	WriteTrailersOnly(http.ResponseWriter, *http.Request, error)
+}
+
+type notFoundHandler struct {
+	unaryHandlers  []protocolHandler
+	streamHandlers []protocolHandler
+}
+
+func (h *notFoundHandler) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.NotFound(responseWriter, request)
+		return
+	}
+	contentType := request.Header.Get("Content-Type")
+	protocolHandler := findProtocolHandler(h.unaryHandlers, contentType)
+	if protocolHandler == nil {
+		protocolHandler = findProtocolHandler(h.streamHandlers, contentType)
+	}
+	if protocolHandler == nil {
+		http.NotFound(responseWriter, request)
+		return
+	}
+	err := errorf(CodeUnimplemented, "%s is not implemented", request.URL.Path)
+	if writer, ok := protocolHandler.(trailersOnlyWriter); ok {
+		writer.WriteTrailersOnly(responseWriter, request, err)
+		return
+	}
+	connCloser, ok := protocolHandler.NewConn(responseWriter, request)
+	if !ok {
+		return
+	}
+	_ = connCloser.Close(err)
+}
+
+func findProtocolHandler(handlers []protocolHandler, contentType string) protocolHandler {
+	for _, handler := range handlers {
+		if _, ok := handler.ContentTypes()[contentType]; ok {
+			return handler
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connect"
+)
+
+func TestRouter(t *testing.T) {
+	t.Parallel()
+	router := connect.NewRouter()
+	servicePath, serviceHandler := pingv1connect.NewPingServiceHandler(successPingServer{})
+	router.Handle(servicePath, serviceHandler)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	client := pingv1connect.NewPingServiceClient(server.Client(), server.URL)
+	grpcClient := pingv1connect.NewPingServiceClient(server.Client(), server.URL, connect.WithGRPC())
+	ctx := context.Background()
+	request := connect.NewRequest(&pingv1.PingRequest{Number: 42})
+
+	t.Run("service", func(t *testing.T) {
+		response, err := client.Ping(ctx, request)
+		assert.Nil(t, err)
+		assert.Equal(t, response.Msg.Number, 0)
+	})
+	t.Run("procedure_overrides_service", func(t *testing.T) {
+		router.Handle(pingv1connect.PingServicePingProcedure, connect.NewUnaryHandler(
+			pingv1connect.PingServicePingProcedure,
+			func(context.Context, *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+				return connect.NewResponse(&pingv1.PingResponse{Number: 1}), nil
+			},
+		))
+		response, err := client.Ping(ctx, request)
+		assert.Nil(t, err)
+		assert.Equal(t, response.Msg.Number, 1)
+		assert.True(t, router.Remove(pingv1connect.PingServicePingProcedure))
+		assert.False(t, router.Remove(pingv1connect.PingServicePingProcedure))
+	})
+	t.Run("unknown_connect", func(t *testing.T) {
+		response, err := server.Client().Post(
+			server.URL+"/connect.ping.v1.UnknownService/Ping",
+			"application/json",
+			strings.NewReader("{}"),
+		)
+		assert.Nil(t, err)
+		defer response.Body.Close()
+		assert.Equal(t, response.StatusCode, http.StatusNotFound)
+		assert.Equal(t, response.Header.Get("Content-Type"), "application/json")
+		var wire struct {
+			Code, Message string
+		}
+		assert.Nil(t, json.NewDecoder(response.Body).Decode(&wire))
+		assert.Equal(t, wire.Code, connect.CodeUnimplemented.String())
+		assert.Equal(t, wire.Message, "/connect.ping.v1.UnknownService/Ping is not implemented")
+	})
+	t.Run("unknown_grpc", func(t *testing.T) {
+		response, err := server.Client().Post(
+			server.URL+"/connect.ping.v1.UnknownService/Ping",
+			"application/grpc",
+			strings.NewReader(""),
+		)
+		assert.Nil(t, err)
+		defer response.Body.Close()
+		_, err = io.Copy(io.Discard, response.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, response.StatusCode, http.StatusOK)
+		assert.Equal(t, response.Header.Get("Content-Type"), "application/grpc")
+		assert.Equal(t, response.Header.Get("Grpc-Status"), "12")
+		assert.Equal(t, response.Header.Get("Grpc-Message"), "/connect.ping.v1.UnknownService/Ping is not implemented")
+		assert.Equal(t, len(response.Trailer), 0)
+	})
+	t.Run("not_rpc", func(t *testing.T) {
+		response, err := server.Client().Get(server.URL + "/index.html")
+		assert.Nil(t, err)
+		defer response.Body.Close()
+		assert.Equal(t, response.StatusCode, http.StatusNotFound)
+	})
+	t.Run("remove_service", func(t *testing.T) {
+		assert.True(t, router.Remove(servicePath))
+		_, err := client.Ping(ctx, request)
+		assert.Equal(t, connect.CodeOf(err), connect.CodeUnimplemented)
+		_, err = grpcClient.Ping(ctx, request)
+		assert.Equal(t, connect.CodeOf(err), connect.CodeUnimplemented)
+	})
+}
+
+func TestNotFoundHandler(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle("/", connect.NotFoundHandler())
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+	for _, option := range []connect.ClientOption{
+		connect.WithClientOptions(),
+		connect.WithProtoJSON(),
+		connect.WithGRPC(),
+		connect.WithGRPCWeb(),
+	} {
+		client := pingv1connect.NewPingServiceClient(server.Client(), server.URL, option)
+		_, err := client.Ping(ctx, connect.NewRequest(&pingv1.PingRequest{}))
+		assert.Equal(t, connect.CodeOf(err), connect.CodeUnimplemented)
+		assert.Equal(t, err.Error(), "unimplemented: /connect.ping.v1.PingService/Ping is not implemented")
+
+		countUp, err := client.CountUp(ctx, connect.NewRequest(&pingv1.CountUpRequest{}))
+		assert.Nil(t, err)
+		assert.False(t, countUp.Receive())
+		assert.Equal(t, connect.CodeOf(countUp.Err()), connect.CodeUnimplemented)
+		assert.Nil(t, countUp.Close())
+
+		cumSum := client.CumSum(ctx)
+		_ = cumSum.Send(&pingv1.CumSumRequest{})
+		_ = cumSum.CloseRequest()
+		_, err = cumSum.Receive()
+		assert.Equal(t, connect.CodeOf(err), connect.CodeUnimplemented)
+		assert.Nil(t, cumSum.CloseResponse())
+	}
+}
@@ -0,0 +1,384 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats counts the messages and bytes exchanged during an RPC. Only messages
+// are counted: headers, trailers, and errors aren't included.
+type Stats struct {
+	MessagesSent     int64
+	MessagesReceived int64
+	// BytesSent and BytesReceived are the number of bytes on the wire, after
+	// compression and including any per-message framing.
+	BytesSent     int64
+	BytesReceived int64
+	// UncompressedBytesSent and UncompressedBytesReceived are the number of
+	// bytes produced and consumed by the Codec.
+	UncompressedBytesSent     int64
+	UncompressedBytesReceived int64
+}
+
+// StatsFromContext reports the messages and bytes exchanged so far by the RPC
+// that the context belongs to. Clients and handlers attach the RPC's Stats to
+// the context before calling any interceptors, so interceptors can read them
+// no matter how the conns they're passed are wrapped:
+//
+//   func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+//     return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+//       err := next(ctx, conn)
+//       stats, _ := connect.StatsFromContext(ctx)
+//       // use stats.MessagesSent, stats.BytesSent, and so on
+//       return err
+//     }
+//   }
+//
+// It returns false if the context doesn't belong to an RPC, or if the RPC's
+// conn hasn't been created yet. If an interceptor creates more than one conn
+// for the same RPC, as retrying interceptors do, the Stats describe the most
+// recent one. It's safe to call concurrently with all other methods.
+func StatsFromContext(ctx context.Context) (Stats, bool) {
+	return statsSourceFromContext(ctx).Stats()
+}
+
+type statsContextKey struct{}
+
+// statsSource is attached to the context of each RPC before any interceptors
+// run. When the RPC's conn is created, newStreamStats registers the conn's
+// streamStats with the nearest statsSource in its context.
+type statsSource struct {
+	mu    sync.Mutex
+	stats *streamStats
+}
+
+// withStatsSource attaches a new statsSource to the context.
+func withStatsSource(ctx context.Context) (context.Context, *statsSource) {
+	source := &statsSource{}
+	return context.WithValue(ctx, statsContextKey{}, source), source
+}
+
+// statsSourceFromContext returns the context's statsSource, or nil if it
+// doesn't have one.
+func statsSourceFromContext(ctx context.Context) *statsSource {
+	source, _ := ctx.Value(statsContextKey{}).(*statsSource)
+	return source
+}
+
+func (s *statsSource) set(stats *streamStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = stats
+}
+
+// Stats reports the registered conn's stats. A nil *statsSource is valid and
+// reports the zero value.
+func (s *statsSource) Stats() (Stats, bool) {
+	if s == nil {
+		return Stats{}, false
+	}
+	s.mu.Lock()
+	stats := s.stats
+	s.mu.Unlock()
+	if stats == nil {
+		return Stats{}, false
+	}
+	return stats.Snapshot(), true
+}
+
+// A StatsHandler observes transport-level events, like response headers
+// arriving or individual messages being written to the network. It's similar
+// to grpc-go's stats.Handler. Unlike interceptors, StatsHandlers can't modify
+// the RPC: they're intended to measure latency and throughput. For example,
+// the time between a client's StatsOutHeader and StatsInHeader events is the
+// time to first byte, which includes any time spent queueing before the
+// handler runs.
+//
+// Each RPC produces a StatsBegin event, followed by header, payload, and
+// trailer events as they occur, and finally a StatsEnd event. HandleStats is
+// called synchronously on the hot path, so it should be fast. It must be safe
+// to call concurrently.
+type StatsHandler interface {
+	HandleStats(context.Context, StatsEvent)
+}
+
+// A StatsEvent is one of StatsBegin, StatsInHeader, StatsOutHeader,
+// StatsInPayload, StatsOutPayload, StatsInTrailer, StatsOutTrailer, or
+// StatsEnd.
+type StatsEvent interface {
+	// IsClient reports whether the event was produced by a client.
+	IsClient() bool
+
+	isStatsEvent()
+}
+
+// StatsBegin is reported when an RPC starts. For clients, that's when the
+// stream is created. For handlers, that's after the request headers have been
+// parsed.
+type StatsBegin struct {
+	Client    bool
+	Spec      Spec
+	Peer      Peer
+	BeginTime time.Time
+}
+
+// StatsOutHeader is reported just before headers are written to the network.
+type StatsOutHeader struct {
+	Client bool
+	Header http.Header
+	Time   time.Time
+}
+
+// StatsInHeader is reported when headers are received. For clients, this
+// marks the arrival of the first byte of the response.
+type StatsInHeader struct {
+	Client bool
+	Header http.Header
+	Time   time.Time
+}
+
+// StatsOutPayload is reported after each message is written to the network.
+type StatsOutPayload struct {
+	Client bool
+	// Message is the message before marshaling.
+	Message any
+	// Length is the size of the marshaled message, and WireLength is the
+	// number of bytes written to the network, after compression and including
+	// any per-message framing.
+	Length     int
+	WireLength int
+	SentTime   time.Time
+}
+
+// StatsInPayload is reported after each message is read from the network and
+// unmarshaled.
+type StatsInPayload struct {
+	Client bool
+	// Message is the unmarshaled message.
+	Message any
+	// Length is the size of the marshaled message, and WireLength is the
+	// number of bytes read from the network, before decompression and
+	// including any per-message framing.
+	Length     int
+	WireLength int
+	RecvTime   time.Time
+}
+
+// StatsOutTrailer is reported just before trailers are written to the network.
+// Only handlers send trailers.
+type StatsOutTrailer struct {
+	Client  bool
+	Trailer http.Header
+	Time    time.Time
+}
+
+// StatsInTrailer is reported when the end of the response is reached and
+// trailers are available. Only clients receive trailers.
+type StatsInTrailer struct {
+	Client  bool
+	Trailer http.Header
+	Time    time.Time
+}
+
+// StatsEnd is reported when an RPC finishes. For clients, that's when the
+// response is closed. For handlers, that's after the handler returns and the
+// response is complete.
+type StatsEnd struct {
+	Client    bool
+	BeginTime time.Time
+	EndTime   time.Time
+	// Error is the error returned to the caller (for clients) or sent to the
+	// client (for handlers). It's nil for successful RPCs.
+	Error error
+	// Stats are the totals for the whole RPC.
+	Stats Stats
+}
+
+func (s *StatsBegin) IsClient() bool      { return s.Client }
+func (s *StatsOutHeader) IsClient() bool  { return s.Client }
+func (s *StatsInHeader) IsClient() bool   { return s.Client }
+func (s *StatsOutPayload) IsClient() bool { return s.Client }
+func (s *StatsInPayload) IsClient() bool  { return s.Client }
+func (s *StatsOutTrailer) IsClient() bool { return s.Client }
+func (s *StatsInTrailer) IsClient() bool  { return s.Client }
+func (s *StatsEnd) IsClient() bool        { return s.Client }
+
+func (*StatsBegin) isStatsEvent()      {}
+func (*StatsOutHeader) isStatsEvent()  {}
+func (*StatsInHeader) isStatsEvent()   {}
+func (*StatsOutPayload) isStatsEvent() {}
+func (*StatsInPayload) isStatsEvent()  {}
+func (*StatsOutTrailer) isStatsEvent() {}
+func (*StatsInTrailer) isStatsEvent()  {}
+func (*StatsEnd) isStatsEvent()        {}
+
+// streamStats accumulates Stats for a single stream and reports events to the
+// user-supplied StatsHandler, if any. Messages may be sent and received
+// concurrently, and Stats may be read at any time, so the counters are
+// updated atomically. A nil *streamStats is valid and does nothing.
+type streamStats struct {
+	messagesSent              int64
+	messagesReceived          int64
+	bytesSent                 int64
+	bytesReceived             int64
+	uncompressedBytesSent     int64
+	uncompressedBytesReceived int64
+
+	ctx       context.Context // nolint:containedctx
+	handler   StatsHandler
+	client    bool
+	beginTime time.Time
+}
+
+// newStreamStats constructs a streamStats and registers it with the context's
+// statsSource, if any.
+func newStreamStats(ctx context.Context, handler StatsHandler, client bool) *streamStats {
+	stats := &streamStats{
+		ctx:     ctx,
+		handler: handler,
+		client:  client,
+	}
+	if source := statsSourceFromContext(ctx); source != nil {
+		source.set(stats)
+	}
+	return stats
+}
+
+func (s *streamStats) Begin(spec Spec, peer Peer) {
+	if s == nil || s.handler == nil {
+		return
+	}
+	s.beginTime = time.Now()
+	s.handler.HandleStats(s.ctx, &StatsBegin{
+		Client:    s.client,
+		Spec:      spec,
+		Peer:      peer,
+		BeginTime: s.beginTime,
+	})
+}
+
+func (s *streamStats) OutHeader(header http.Header) {
+	if s == nil || s.handler == nil {
+		return
+	}
+	s.handler.HandleStats(s.ctx, &StatsOutHeader{
+		Client: s.client,
+		Header: header,
+		Time:   time.Now(),
+	})
+}
+
+func (s *streamStats) InHeader(header http.Header) {
+	if s == nil || s.handler == nil {
+		return
+	}
+	s.handler.HandleStats(s.ctx, &StatsInHeader{
+		Client: s.client,
+		Header: header,
+		Time:   time.Now(),
+	})
+}
+
+func (s *streamStats) Sent(message any, wireBytes, uncompressedBytes int) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.messagesSent, 1)
+	atomic.AddInt64(&s.bytesSent, int64(wireBytes))
+	atomic.AddInt64(&s.uncompressedBytesSent, int64(uncompressedBytes))
+	if s.handler == nil {
+		return
+	}
+	s.handler.HandleStats(s.ctx, &StatsOutPayload{
+		Client:     s.client,
+		Message:    message,
+		Length:     uncompressedBytes,
+		WireLength: wireBytes,
+		SentTime:   time.Now(),
+	})
+}
+
+func (s *streamStats) Received(message any, wireBytes, uncompressedBytes int) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.messagesReceived, 1)
+	atomic.AddInt64(&s.bytesReceived, int64(wireBytes))
+	atomic.AddInt64(&s.uncompressedBytesReceived, int64(uncompressedBytes))
+	if s.handler == nil {
+		return
+	}
+	s.handler.HandleStats(s.ctx, &StatsInPayload{
+		Client:     s.client,
+		Message:    message,
+		Length:     uncompressedBytes,
+		WireLength: wireBytes,
+		RecvTime:   time.Now(),
+	})
+}
+
+func (s *streamStats) OutTrailer(trailer http.Header) {
+	if s == nil || s.handler == nil {
+		return
+	}
+	s.handler.HandleStats(s.ctx, &StatsOutTrailer{
+		Client:  s.client,
+		Trailer: trailer,
+		Time:    time.Now(),
+	})
+}
+
+func (s *streamStats) InTrailer(trailer http.Header) {
+	if s == nil || s.handler == nil {
+		return
+	}
+	s.handler.HandleStats(s.ctx, &StatsInTrailer{
+		Client:  s.client,
+		Trailer: trailer,
+		Time:    time.Now(),
+	})
+}
+
+func (s *streamStats) End(err error) {
+	if s == nil || s.handler == nil {
+		return
+	}
+	s.handler.HandleStats(s.ctx, &StatsEnd{
+		Client:    s.client,
+		BeginTime: s.beginTime,
+		EndTime:   time.Now(),
+		Error:     err,
+		Stats:     s.Snapshot(),
+	})
+}
+
+func (s *streamStats) Snapshot() Stats {
+	if s == nil {
+		return Stats{}
+	}
+	return Stats{
+		MessagesSent:              atomic.LoadInt64(&s.messagesSent),
+		MessagesReceived:          atomic.LoadInt64(&s.messagesReceived),
+		BytesSent:                 atomic.LoadInt64(&s.bytesSent),
+		BytesReceived:             atomic.LoadInt64(&s.bytesReceived),
+		UncompressedBytesSent:     atomic.LoadInt64(&s.uncompressedBytesSent),
+		UncompressedBytesReceived: atomic.LoadInt64(&s.uncompressedBytesReceived),
+	}
+}
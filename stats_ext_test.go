@@ -0,0 +1,277 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connect"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+	handlerStats := make(chan connect.Stats, 1)
+	// Wrapping conns in another interceptor shouldn't hide their stats.
+	// NewStreamInterceptor's wrappers don't expose Stats themselves.
+	wrapper := connect.NewStreamInterceptor(connect.OnSend(func(context.Context, connect.Spec, any) error {
+		return nil
+	}))
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		pingServer{},
+		connect.WithInterceptors(wrapper, &statsInterceptor{handlerStats: handlerStats}),
+		connect.WithCompressMinBytes(1),
+	))
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	testProtocol := func(t *testing.T, opts ...connect.ClientOption) {
+		t.Helper()
+		client := pingv1connect.NewPingServiceClient(
+			server.Client(),
+			server.URL,
+			append(opts, connect.WithSendGzip(), connect.WithCompressMinBytes(1), connect.WithInterceptors(wrapper))...,
+		)
+		request := &pingv1.PingRequest{Number: 42, Text: strings.Repeat("compressible ", 100)}
+		response, err := client.Ping(context.Background(), connect.NewRequest(request))
+		assert.Nil(t, err)
+		stats := response.Stats()
+		assert.Equal(t, stats.MessagesSent, int64(1))
+		assert.Equal(t, stats.MessagesReceived, int64(1))
+		assert.Equal(t, stats.UncompressedBytesSent, int64(proto.Size(request)))
+		assert.Equal(t, stats.UncompressedBytesReceived, int64(proto.Size(response.Msg)))
+		assert.True(t, stats.BytesSent < stats.UncompressedBytesSent)
+		assert.True(t, stats.BytesReceived < stats.UncompressedBytesReceived)
+
+		stream, err := client.CountUp(context.Background(), connect.NewRequest(&pingv1.CountUpRequest{Number: 3}))
+		assert.Nil(t, err)
+		for stream.Receive() {
+			assert.Equal(t, stream.Stats().MessagesReceived, stream.Msg().Number)
+		}
+		assert.Nil(t, stream.Err())
+		assert.Nil(t, stream.Close())
+		clientStats := stream.Stats()
+		assert.Equal(t, clientStats.MessagesSent, int64(1))
+		assert.Equal(t, clientStats.MessagesReceived, int64(3))
+		serverStats := <-handlerStats
+		assert.Equal(t, serverStats, connect.Stats{
+			MessagesSent:              clientStats.MessagesReceived,
+			MessagesReceived:          clientStats.MessagesSent,
+			BytesSent:                 clientStats.BytesReceived,
+			BytesReceived:             clientStats.BytesSent,
+			UncompressedBytesSent:     clientStats.UncompressedBytesReceived,
+			UncompressedBytesReceived: clientStats.UncompressedBytesSent,
+		})
+	}
+	t.Run("connect", func(t *testing.T) {
+		testProtocol(t)
+	})
+	t.Run("grpc", func(t *testing.T) {
+		testProtocol(t, connect.WithGRPC())
+	})
+	t.Run("grpcweb", func(t *testing.T) {
+		testProtocol(t, connect.WithGRPCWeb())
+	})
+}
+
+func TestStatsFromContextFailedCall(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(pingServer{}))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	clientStats := make(chan connect.Stats, 1)
+	interceptor := connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
+			_, ok := connect.StatsFromContext(ctx)
+			assert.False(t, ok) // no conn yet
+			response, err := next(ctx, request)
+			stats, ok := connect.StatsFromContext(ctx)
+			assert.True(t, ok)
+			clientStats <- stats
+			return response, err
+		}
+	})
+	client := pingv1connect.NewPingServiceClient(server.Client(), server.URL, connect.WithInterceptors(interceptor))
+	request := &pingv1.FailRequest{Code: int32(connect.CodeResourceExhausted)}
+	_, err := client.Fail(context.Background(), connect.NewRequest(request))
+	assert.Equal(t, connect.CodeOf(err), connect.CodeResourceExhausted)
+	// Failed calls don't return a Response, but their stats are still
+	// available from the context.
+	stats := <-clientStats
+	assert.Equal(t, stats.MessagesSent, int64(1))
+	assert.Equal(t, stats.UncompressedBytesSent, int64(proto.Size(request)))
+	assert.Equal(t, stats.MessagesReceived, int64(0))
+	_, ok := connect.StatsFromContext(context.Background())
+	assert.False(t, ok)
+}
+
+func TestStatsHandler(t *testing.T) {
+	t.Parallel()
+	handlerEvents := &recordingStatsHandler{done: make(chan struct{}, 1)}
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		pingServer{},
+		connect.WithStatsHandler(handlerEvents),
+	))
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	testProtocol := func(t *testing.T, opts ...connect.ClientOption) {
+		t.Helper()
+		clientEvents := &recordingStatsHandler{}
+		client := pingv1connect.NewPingServiceClient(
+			server.Client(),
+			server.URL,
+			append(opts, connect.WithStatsHandler(clientEvents))...,
+		)
+		request := connect.NewRequest(&pingv1.PingRequest{Number: 42})
+		request.Header().Set(clientHeader, headerValue)
+		response, err := client.Ping(context.Background(), request)
+		assert.Nil(t, err)
+		<-handlerEvents.done
+
+		events := clientEvents.Events()
+		assert.Equal(t, countEvents[*connect.StatsOutHeader](events), 1)
+		assert.Equal(t, countEvents[*connect.StatsInHeader](events), 1)
+		assert.Equal(t, countEvents[*connect.StatsOutPayload](events), 1)
+		assert.Equal(t, countEvents[*connect.StatsInPayload](events), 1)
+		assert.Equal(t, countEvents[*connect.StatsInTrailer](events), 1)
+		if assert.Equal(t, len(events), 7) {
+			begin, ok := events[0].(*connect.StatsBegin)
+			assert.True(t, ok)
+			assert.True(t, begin.IsClient())
+			assert.Equal(t, begin.Spec.Procedure, "/connect.ping.v1.PingService/Ping")
+			end, ok := events[6].(*connect.StatsEnd)
+			assert.True(t, ok)
+			assert.Nil(t, end.Error)
+			assert.Equal(t, end.Stats, response.Stats())
+			assert.False(t, end.EndTime.Before(end.BeginTime))
+		}
+
+		events = handlerEvents.Events()
+		handlerEvents.Reset()
+		if assert.Equal(t, len(events), 7) {
+			_, ok := events[0].(*connect.StatsBegin)
+			assert.True(t, ok)
+			assert.False(t, events[0].IsClient())
+			inHeader, ok := events[1].(*connect.StatsInHeader)
+			assert.True(t, ok)
+			assert.Equal(t, inHeader.Header.Get(clientHeader), headerValue)
+			inPayload, ok := events[2].(*connect.StatsInPayload)
+			assert.True(t, ok)
+			assert.Equal(t, inPayload.Message.(*pingv1.PingRequest).Number, int64(42)) // nolint:forcetypeassert
+			_, ok = events[3].(*connect.StatsOutHeader)
+			assert.True(t, ok)
+			outPayload, ok := events[4].(*connect.StatsOutPayload)
+			assert.True(t, ok)
+			assert.Equal(t, outPayload.Length, proto.Size(response.Msg))
+			_, ok = events[5].(*connect.StatsOutTrailer)
+			assert.True(t, ok)
+			end, ok := events[6].(*connect.StatsEnd)
+			assert.True(t, ok)
+			assert.Nil(t, end.Error)
+			assert.Equal(t, end.Stats.MessagesSent, int64(1))
+			assert.Equal(t, end.Stats.MessagesReceived, int64(1))
+		}
+	}
+	// The handler's recorder is shared, so these subtests can't run in
+	// parallel.
+	t.Run("connect", func(t *testing.T) {
+		testProtocol(t)
+	})
+	t.Run("grpc", func(t *testing.T) {
+		testProtocol(t, connect.WithGRPC())
+	})
+	t.Run("grpcweb", func(t *testing.T) {
+		testProtocol(t, connect.WithGRPCWeb())
+	})
+}
+
+type recordingStatsHandler struct {
+	mu     sync.Mutex
+	events []connect.StatsEvent
+	done   chan struct{}
+}
+
+func (h *recordingStatsHandler) HandleStats(_ context.Context, event connect.StatsEvent) {
+	h.mu.Lock()
+	h.events = append(h.events, event)
+	h.mu.Unlock()
+	if _, ok := event.(*connect.StatsEnd); ok && h.done != nil {
+		h.done <- struct{}{}
+	}
+}
+
+func (h *recordingStatsHandler) Events() []connect.StatsEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]connect.StatsEvent(nil), h.events...)
+}
+
+func (h *recordingStatsHandler) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = nil
+}
+
+func countEvents[T connect.StatsEvent](events []connect.StatsEvent) int {
+	var count int
+	for _, event := range events {
+		if _, ok := event.(T); ok {
+			count++
+		}
+	}
+	return count
+}
+
+// statsInterceptor reports the Stats of each streaming handler.
+type statsInterceptor struct {
+	handlerStats chan<- connect.Stats
+}
+
+func (i *statsInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return next
+}
+
+func (i *statsInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *statsInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		err := next(ctx, conn)
+		stats, ok := connect.StatsFromContext(ctx)
+		if !ok {
+			return errors.New("no stats in context")
+		}
+		i.handlerStats <- stats
+		return err
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// A StreamHook is a per-message callback for the Interceptor returned by
+// NewStreamInterceptor. Construct StreamHooks with OnSend, OnReceive,
+// OnHeader, and OnClose.
+type StreamHook interface {
+	applyToStreamInterceptor(*streamInterceptor)
+}
+
+// OnSend registers a hook called with each message before it's sent. If the
+// hook returns an error, the message isn't sent and the error is returned
+// from Send.
+func OnSend(hook func(ctx context.Context, spec Spec, message any) error) StreamHook {
+	return &streamHookOption{onSend: hook}
+}
+
+// OnReceive registers a hook called with each message after it's received and
+// unmarshaled. If the hook returns an error, it's returned from Receive.
+func OnReceive(hook func(ctx context.Context, spec Spec, message any) error) StreamHook {
+	return &streamHookOption{onReceive: hook}
+}
+
+// OnHeader registers a hook called once with the headers sent by the other
+// party. Handlers call the hook with the request headers before the handler
+// runs. Clients call the hook with the response headers the first time
+// Receive returns.
+func OnHeader(hook func(ctx context.Context, spec Spec, header http.Header)) StreamHook {
+	return &streamHookOption{onHeader: hook}
+}
+
+// OnClose registers a hook called once when the stream ends. Handlers call the
+// hook with the error returned by the handler. Clients call the hook from
+// CloseResponse, with the first error other than io.EOF returned from Receive
+// or the error returned from CloseResponse.
+func OnClose(hook func(ctx context.Context, spec Spec, err error)) StreamHook {
+	return &streamHookOption{onClose: hook}
+}
+
+// NewStreamInterceptor returns an Interceptor that calls the supplied hooks
+// for each message in streaming RPCs, so simple streaming interceptors don't
+// need to wrap StreamingClientConn or StreamingHandlerConn by hand. It has no
+// effect on unary RPCs. Hooks of the same kind are called in the order
+// they're supplied, and interceptors compose just like any other Interceptor
+// passed to WithInterceptors.
+//
+// The wrappers preserve the concurrency guarantees documented on
+// StreamingClientConn and StreamingHandlerConn. In bidirectional streams,
+// OnSend may be called concurrently with OnReceive, OnHeader, and OnClose, so
+// hooks that share state must synchronize access to it.
+func NewStreamInterceptor(hooks ...StreamHook) Interceptor {
+	interceptor := &streamInterceptor{}
+	for _, hook := range hooks {
+		hook.applyToStreamInterceptor(interceptor)
+	}
+	return interceptor
+}
+
+type streamInterceptor struct {
+	onSend    []func(context.Context, Spec, any) error
+	onReceive []func(context.Context, Spec, any) error
+	onHeader  []func(context.Context, Spec, http.Header)
+	onClose   []func(context.Context, Spec, error)
+}
+
+func (i *streamInterceptor) WrapUnary(next UnaryFunc) UnaryFunc {
+	return next
+}
+
+func (i *streamInterceptor) WrapStreamingClient(next StreamingClientFunc) StreamingClientFunc {
+	return func(ctx context.Context, spec Spec) StreamingClientConn {
+		return &streamHookClientConn{
+			StreamingClientConn: next(ctx, spec),
+			ctx:                 ctx,
+			interceptor:         i,
+		}
+	}
+}
+
+func (i *streamInterceptor) WrapStreamingHandler(next StreamingHandlerFunc) StreamingHandlerFunc {
+	return func(ctx context.Context, conn StreamingHandlerConn) error {
+		spec := conn.Spec()
+		i.header(ctx, spec, conn.RequestHeader())
+		err := next(ctx, &streamHookHandlerConn{
+			StreamingHandlerConn: conn,
+			ctx:                  ctx,
+			interceptor:          i,
+		})
+		i.close(ctx, spec, err)
+		return err
+	}
+}
+
+func (i *streamInterceptor) send(ctx context.Context, spec Spec, message any) error {
+	for _, hook := range i.onSend {
+		if err := hook(ctx, spec, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *streamInterceptor) receive(ctx context.Context, spec Spec, message any) error {
+	for _, hook := range i.onReceive {
+		if err := hook(ctx, spec, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *streamInterceptor) header(ctx context.Context, spec Spec, header http.Header) {
+	for _, hook := range i.onHeader {
+		hook(ctx, spec, header)
+	}
+}
+
+func (i *streamInterceptor) close(ctx context.Context, spec Spec, err error) {
+	for _, hook := range i.onClose {
+		hook(ctx, spec, err)
+	}
+}
+
+// streamHookClientConn calls the interceptor's hooks. Send-side and
+// receive-side state are kept separate, since Send may be called concurrently
+// with Receive and CloseResponse.
+type streamHookClientConn struct {
+	StreamingClientConn
+
+	ctx         context.Context // nolint:containedctx
+	interceptor *streamInterceptor
+
+	// Receive-side state.
+	receivedHeader bool
+	receiveErr     error
+	closed         bool
+}
+
+// Peer implements PeerReporter.
+func (cc *streamHookClientConn) Peer() Peer {
+	return PeerOf(cc.StreamingClientConn)
+}
+
+func (cc *streamHookClientConn) Send(msg any) error {
+	if err := cc.interceptor.send(cc.ctx, cc.Spec(), msg); err != nil {
+		return err
+	}
+	return cc.StreamingClientConn.Send(msg)
+}
+
+func (cc *streamHookClientConn) Receive(msg any) error {
+	err := cc.StreamingClientConn.Receive(msg)
+	if !cc.receivedHeader {
+		cc.receivedHeader = true
+		cc.interceptor.header(cc.ctx, cc.Spec(), cc.ResponseHeader())
+	}
+	if err != nil {
+		if cc.receiveErr == nil && !errors.Is(err, io.EOF) {
+			cc.receiveErr = err
+		}
+		return err
+	}
+	return cc.interceptor.receive(cc.ctx, cc.Spec(), msg)
+}
+
+func (cc *streamHookClientConn) CloseResponse() error {
+	err := cc.StreamingClientConn.CloseResponse()
+	if !cc.closed {
+		cc.closed = true
+		closeErr := cc.receiveErr
+		if closeErr == nil {
+			closeErr = err
+		}
+		cc.interceptor.close(cc.ctx, cc.Spec(), closeErr)
+	}
+	return err
+}
+
+type streamHookHandlerConn struct {
+	StreamingHandlerConn
+
+	ctx         context.Context // nolint:containedctx
+	interceptor *streamInterceptor
+}
+
+// Peer implements PeerReporter.
+func (hc *streamHookHandlerConn) Peer() Peer {
+	return PeerOf(hc.StreamingHandlerConn)
+}
+
+func (hc *streamHookHandlerConn) Send(msg any) error {
+	if err := hc.interceptor.send(hc.ctx, hc.Spec(), msg); err != nil {
+		return err
+	}
+	return hc.StreamingHandlerConn.Send(msg)
+}
+
+func (hc *streamHookHandlerConn) Receive(msg any) error {
+	if err := hc.StreamingHandlerConn.Receive(msg); err != nil {
+		return err
+	}
+	return hc.interceptor.receive(hc.ctx, hc.Spec(), msg)
+}
+
+type streamHookOption struct {
+	onSend    func(context.Context, Spec, any) error
+	onReceive func(context.Context, Spec, any) error
+	onHeader  func(context.Context, Spec, http.Header)
+	onClose   func(context.Context, Spec, error)
+}
+
+func (o *streamHookOption) applyToStreamInterceptor(interceptor *streamInterceptor) {
+	if o.onSend != nil {
+		interceptor.onSend = append(interceptor.onSend, o.onSend)
+	}
+	if o.onReceive != nil {
+		interceptor.onReceive = append(interceptor.onReceive, o.onReceive)
+	}
+	if o.onHeader != nil {
+		interceptor.onHeader = append(interceptor.onHeader, o.onHeader)
+	}
+	if o.onClose != nil {
+		interceptor.onClose = append(interceptor.onClose, o.onClose)
+	}
+}
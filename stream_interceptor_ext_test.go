@@ -0,0 +1,144 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connect"
+)
+
+func TestStreamInterceptor(t *testing.T) {
+	t.Parallel()
+	var handlerLog hookLog
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		pingServer{},
+		connect.WithInterceptors(
+			handlerLog.interceptor("outer"),
+			handlerLog.interceptor("inner"),
+		),
+	))
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	t.Run("order", func(t *testing.T) {
+		var clientLog hookLog
+		client := pingv1connect.NewPingServiceClient(
+			server.Client(),
+			server.URL,
+			connect.WithInterceptors(
+				clientLog.interceptor("outer"),
+				clientLog.interceptor("inner"),
+			),
+		)
+		stream := client.CumSum(context.Background())
+		assert.Nil(t, stream.Send(&pingv1.CumSumRequest{Number: 1}))
+		_, err := stream.Receive()
+		assert.Nil(t, err)
+		assert.Nil(t, stream.CloseRequest())
+		_, err = stream.Receive()
+		assert.NotNil(t, err)
+		assert.Nil(t, stream.CloseResponse())
+		assert.Equal(t, clientLog.Entries(), []string{
+			"outer send",
+			"inner send",
+			"inner header",
+			"inner receive",
+			"outer header",
+			"outer receive",
+			"inner close <nil>",
+			"outer close <nil>",
+		})
+		assert.Equal(t, handlerLog.Entries(), []string{
+			"outer header",
+			"inner header",
+			"outer receive",
+			"inner receive",
+			"inner send",
+			"outer send",
+			"inner close <nil>",
+			"outer close <nil>",
+		})
+	})
+	t.Run("reject", func(t *testing.T) {
+		errRejected := errors.New("rejected")
+		client := pingv1connect.NewPingServiceClient(
+			server.Client(),
+			server.URL,
+			connect.WithInterceptors(connect.NewStreamInterceptor(
+				connect.OnSend(func(_ context.Context, _ connect.Spec, message any) error {
+					if request, ok := message.(*pingv1.CumSumRequest); ok && request.Number < 0 {
+						return connect.NewError(connect.CodeInvalidArgument, errRejected)
+					}
+					return nil
+				}),
+			)),
+		)
+		stream := client.CumSum(context.Background())
+		err := stream.Send(&pingv1.CumSumRequest{Number: -1})
+		assert.ErrorIs(t, err, errRejected)
+		assert.Nil(t, stream.CloseRequest())
+		assert.Nil(t, stream.CloseResponse())
+	})
+}
+
+// hookLog records the hooks called by stream interceptors.
+type hookLog struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *hookLog) interceptor(name string) connect.Interceptor {
+	return connect.NewStreamInterceptor(
+		connect.OnSend(func(_ context.Context, _ connect.Spec, _ any) error {
+			l.add(name + " send")
+			return nil
+		}),
+		connect.OnReceive(func(_ context.Context, _ connect.Spec, _ any) error {
+			l.add(name + " receive")
+			return nil
+		}),
+		connect.OnHeader(func(_ context.Context, _ connect.Spec, _ http.Header) {
+			l.add(name + " header")
+		}),
+		connect.OnClose(func(_ context.Context, _ connect.Spec, err error) {
+			l.add(fmt.Sprintf("%s close %v", name, err))
+		}),
+	)
+}
+
+func (l *hookLog) add(entry string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+func (l *hookLog) Entries() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.entries...)
+}
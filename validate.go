@@ -0,0 +1,203 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// A Validator checks messages against a set of constraints, like the field
+// constraints declared with protovalidate annotations.
+type Validator interface {
+	// Validate returns a nil error if the message is valid. Otherwise, it
+	// returns an error summarizing the problem and, optionally, a Protobuf
+	// message describing each violation in detail (for example, protovalidate's
+	// buf.validate.Violations). The violations are sent to the client as an
+	// error detail.
+	//
+	// If the returned error can be cast to an *Error, its code, message, and
+	// metadata are used as-is. If the violations can't be marshaled, the
+	// interceptor returns a CodeInternal error instead.
+	Validate(message any) (violations proto.Message, err error)
+}
+
+// ValidatorFunc is a simple Validator implementation.
+type ValidatorFunc func(any) (proto.Message, error)
+
+// Validate implements Validator by calling the function.
+func (f ValidatorFunc) Validate(message any) (proto.Message, error) { return f(message) }
+
+// A ValidateOption configures the interceptor returned by
+// NewValidateInterceptor.
+type ValidateOption interface {
+	applyToValidate(*validateInterceptor)
+}
+
+// WithValidateResponses configures the validation interceptor to also check
+// response messages. Invalid responses are reported with CodeInternal, since
+// they indicate a bug in the server.
+//
+// By default, only request messages are validated.
+func WithValidateResponses() ValidateOption {
+	return &validateResponsesOption{}
+}
+
+// NewValidateInterceptor returns an Interceptor that validates every message
+// in unary and streaming RPCs. In handlers, it checks each request message as
+// it's received and returns CodeInvalidArgument errors for invalid messages.
+// In clients, it checks each request message before sending it, so invalid
+// requests fail without a network round trip.
+//
+// When the Validator reports structured violations, they're attached to the
+// returned *Error using AddDetail.
+func NewValidateInterceptor(validator Validator, options ...ValidateOption) Interceptor {
+	interceptor := &validateInterceptor{validator: validator}
+	for _, opt := range options {
+		opt.applyToValidate(interceptor)
+	}
+	return interceptor
+}
+
+type validateInterceptor struct {
+	validator         Validator
+	validateResponses bool
+}
+
+func (i *validateInterceptor) WrapUnary(next UnaryFunc) UnaryFunc {
+	return func(ctx context.Context, request AnyRequest) (AnyResponse, error) {
+		if err := i.validate(request.Any(), CodeInvalidArgument); err != nil {
+			return nil, err
+		}
+		response, err := next(ctx, request)
+		if err != nil || !i.validateResponses {
+			return response, err
+		}
+		if err := i.validate(response.Any(), CodeInternal); err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+}
+
+func (i *validateInterceptor) WrapStreamingClient(next StreamingClientFunc) StreamingClientFunc {
+	return func(ctx context.Context, spec Spec) StreamingClientConn {
+		return &validateClientConn{
+			StreamingClientConn: next(ctx, spec),
+			interceptor:         i,
+		}
+	}
+}
+
+func (i *validateInterceptor) WrapStreamingHandler(next StreamingHandlerFunc) StreamingHandlerFunc {
+	return func(ctx context.Context, conn StreamingHandlerConn) error {
+		return next(ctx, &validateHandlerConn{
+			StreamingHandlerConn: conn,
+			interceptor:          i,
+		})
+	}
+}
+
+// validate checks the message and converts any violations to an *Error with
+// the supplied code.
+func (i *validateInterceptor) validate(message any, code Code) error {
+	violations, err := i.validator.Validate(message)
+	if err == nil {
+		return nil
+	}
+	if violations == nil {
+		if connectErr, ok := asError(err); ok {
+			return connectErr
+		}
+		return NewError(code, err)
+	}
+	detail, anyErr := anypb.New(violations)
+	if anyErr != nil {
+		return errorf(CodeInternal, "marshal validation violations: %w", anyErr)
+	}
+	connectErr, ok := asError(err)
+	if ok {
+		// Validators may return shared or sentinel errors, so we attach the
+		// violations to a copy.
+		connectErr = connectErr.clone()
+	} else {
+		connectErr = NewError(code, err)
+	}
+	connectErr.AddDetail(detail)
+	return connectErr
+}
+
+type validateClientConn struct {
+	StreamingClientConn
+
+	interceptor *validateInterceptor
+}
+
+// Peer implements PeerReporter.
+func (cc *validateClientConn) Peer() Peer {
+	return PeerOf(cc.StreamingClientConn)
+}
+
+func (cc *validateClientConn) Send(msg any) error {
+	if err := cc.interceptor.validate(msg, CodeInvalidArgument); err != nil {
+		return err
+	}
+	return cc.StreamingClientConn.Send(msg)
+}
+
+func (cc *validateClientConn) Receive(msg any) error {
+	if err := cc.StreamingClientConn.Receive(msg); err != nil {
+		return err
+	}
+	if !cc.interceptor.validateResponses {
+		return nil
+	}
+	return cc.interceptor.validate(msg, CodeInternal)
+}
+
+type validateHandlerConn struct {
+	StreamingHandlerConn
+
+	interceptor *validateInterceptor
+}
+
+// Peer implements PeerReporter.
+func (hc *validateHandlerConn) Peer() Peer {
+	return PeerOf(hc.StreamingHandlerConn)
+}
+
+func (hc *validateHandlerConn) Receive(msg any) error {
+	if err := hc.StreamingHandlerConn.Receive(msg); err != nil {
+		return err
+	}
+	return hc.interceptor.validate(msg, CodeInvalidArgument)
+}
+
+func (hc *validateHandlerConn) Send(msg any) error {
+	if hc.interceptor.validateResponses {
+		if err := hc.interceptor.validate(msg, CodeInternal); err != nil {
+			return err
+		}
+	}
+	return hc.StreamingHandlerConn.Send(msg)
+}
+
+type validateResponsesOption struct{}
+
+func (o *validateResponsesOption) applyToValidate(interceptor *validateInterceptor) {
+	interceptor.validateResponses = true
+}
@@ -0,0 +1,165 @@
+// Copyright 2021-2022 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/bufbuild/connect-go/internal/assert"
+	pingv1 "github.com/bufbuild/connect-go/internal/gen/connect/ping/v1"
+	"github.com/bufbuild/connect-go/internal/gen/connect/ping/v1/pingv1connect"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const invalidText = "invalid response"
+
+func negativeNumberViolation() *wrapperspb.StringValue {
+	return wrapperspb.String("number must be non-negative")
+}
+
+// negativeNumberValidator rejects request messages with negative numbers and
+// response messages with invalid text.
+func negativeNumberValidator(message any) (proto.Message, error) {
+	switch typed := message.(type) {
+	case *pingv1.PingRequest:
+		if typed.Number < 0 {
+			return negativeNumberViolation(), errors.New("invalid PingRequest")
+		}
+	case *pingv1.SumRequest:
+		if typed.Number < 0 {
+			return negativeNumberViolation(), errors.New("invalid SumRequest")
+		}
+	case *pingv1.PingResponse:
+		if typed.Text == invalidText {
+			return nil, errors.New("invalid PingResponse")
+		}
+	}
+	return nil, nil // nolint:nilnil
+}
+
+func TestValidateInterceptor(t *testing.T) {
+	t.Parallel()
+	interceptor := connect.NewValidateInterceptor(
+		connect.ValidatorFunc(negativeNumberValidator),
+		connect.WithValidateResponses(),
+	)
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		pingServer{},
+		connect.WithInterceptors(interceptor),
+	))
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	assertViolation := func(tb testing.TB, err error, code connect.Code) {
+		tb.Helper()
+		var connectErr *connect.Error
+		if !assert.True(tb, errors.As(err, &connectErr)) {
+			return
+		}
+		assert.Equal(tb, connectErr.Code(), code)
+		if !assert.Equal(tb, len(connectErr.Details()), 1) {
+			return
+		}
+		violation, ok := connect.AsErrorDetail[wrapperspb.StringValue](err)
+		assert.True(tb, ok)
+		assert.True(tb, proto.Equal(violation, negativeNumberViolation()))
+	}
+
+	t.Run("handler", func(t *testing.T) {
+		t.Parallel()
+		client := pingv1connect.NewPingServiceClient(server.Client(), server.URL, connect.WithGRPC())
+		request := connect.NewRequest(&pingv1.PingRequest{Number: -1})
+		request.Header().Set(clientHeader, headerValue)
+		_, err := client.Ping(context.Background(), request)
+		assertViolation(t, err, connect.CodeInvalidArgument)
+
+		stream := client.Sum(context.Background())
+		stream.RequestHeader().Set(clientHeader, headerValue)
+		assert.Nil(t, stream.Send(&pingv1.SumRequest{Number: 1}))
+		_ = stream.Send(&pingv1.SumRequest{Number: -1})
+		_, err = stream.CloseAndReceive()
+		assertViolation(t, err, connect.CodeInvalidArgument)
+	})
+	t.Run("handler_response", func(t *testing.T) {
+		t.Parallel()
+		client := pingv1connect.NewPingServiceClient(server.Client(), server.URL)
+		// The server echoes the request text, which is valid in the request but
+		// not in the response.
+		request := connect.NewRequest(&pingv1.PingRequest{Text: invalidText})
+		request.Header().Set(clientHeader, headerValue)
+		_, err := client.Ping(context.Background(), request)
+		assert.NotNil(t, err)
+		assert.Equal(t, connect.CodeOf(err), connect.CodeInternal)
+	})
+	t.Run("client", func(t *testing.T) {
+		t.Parallel()
+		client := pingv1connect.NewPingServiceClient(
+			server.Client(),
+			server.URL,
+			connect.WithInterceptors(interceptor),
+		)
+		_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{Number: -1}))
+		assertViolation(t, err, connect.CodeInvalidArgument)
+
+		stream := client.Sum(context.Background())
+		err = stream.Send(&pingv1.SumRequest{Number: -1})
+		assertViolation(t, err, connect.CodeInvalidArgument)
+		_, _ = stream.CloseAndReceive()
+	})
+}
+
+func TestValidateInterceptorErrors(t *testing.T) {
+	t.Parallel()
+	sentinel := connect.NewError(connect.CodeFailedPrecondition, errors.New("invalid"))
+	validateRequest := func(validator connect.ValidatorFunc) error {
+		client := pingv1connect.NewPingServiceClient(
+			http.DefaultClient,
+			"http://invalid.test", // validation fails before any request is sent
+			connect.WithInterceptors(connect.NewValidateInterceptor(validator)),
+		)
+		_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+		return err
+	}
+	t.Run("shared_error", func(t *testing.T) {
+		t.Parallel()
+		for i := 0; i < 2; i++ {
+			err := validateRequest(func(any) (proto.Message, error) {
+				return negativeNumberViolation(), sentinel
+			})
+			assert.Equal(t, connect.CodeOf(err), connect.CodeFailedPrecondition)
+			var connectErr *connect.Error
+			assert.True(t, errors.As(err, &connectErr))
+			assert.Equal(t, len(connectErr.Details()), 1)
+		}
+		assert.Equal(t, len(sentinel.Details()), 0)
+	})
+	t.Run("unmarshalable_violations", func(t *testing.T) {
+		t.Parallel()
+		err := validateRequest(func(any) (proto.Message, error) {
+			return wrapperspb.String("invalid UTF-8: \xff"), errors.New("invalid")
+		})
+		assert.Equal(t, connect.CodeOf(err), connect.CodeInternal)
+		assert.True(t, strings.Contains(err.Error(), "marshal validation violations"))
+	})
+}